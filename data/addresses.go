@@ -0,0 +1,253 @@
+package data
+
+import "strings"
+
+// State is a US state, or the District of Columbia, by it's USPS code and name
+type State struct {
+	Code string
+	Name string
+}
+
+// City is a city in a US state, along with the first three digits of the ZIP codes
+// which serve it. These are a sample of the larger cities in each state, rather than
+// a complete list, but every ZIP code built from them belongs to the right state.
+type City struct {
+	Name      string
+	State     string
+	ZipPrefix string
+}
+
+// States is every US state, along with the District of Columbia
+var States = []State{
+	{"AL", "Alabama"},
+	{"AK", "Alaska"},
+	{"AZ", "Arizona"},
+	{"AR", "Arkansas"},
+	{"CA", "California"},
+	{"CO", "Colorado"},
+	{"CT", "Connecticut"},
+	{"DE", "Delaware"},
+	{"DC", "District of Columbia"},
+	{"FL", "Florida"},
+	{"GA", "Georgia"},
+	{"HI", "Hawaii"},
+	{"ID", "Idaho"},
+	{"IL", "Illinois"},
+	{"IN", "Indiana"},
+	{"IA", "Iowa"},
+	{"KS", "Kansas"},
+	{"KY", "Kentucky"},
+	{"LA", "Louisiana"},
+	{"ME", "Maine"},
+	{"MD", "Maryland"},
+	{"MA", "Massachusetts"},
+	{"MI", "Michigan"},
+	{"MN", "Minnesota"},
+	{"MS", "Mississippi"},
+	{"MO", "Missouri"},
+	{"MT", "Montana"},
+	{"NE", "Nebraska"},
+	{"NV", "Nevada"},
+	{"NH", "New Hampshire"},
+	{"NJ", "New Jersey"},
+	{"NM", "New Mexico"},
+	{"NY", "New York"},
+	{"NC", "North Carolina"},
+	{"ND", "North Dakota"},
+	{"OH", "Ohio"},
+	{"OK", "Oklahoma"},
+	{"OR", "Oregon"},
+	{"PA", "Pennsylvania"},
+	{"RI", "Rhode Island"},
+	{"SC", "South Carolina"},
+	{"SD", "South Dakota"},
+	{"TN", "Tennessee"},
+	{"TX", "Texas"},
+	{"UT", "Utah"},
+	{"VT", "Vermont"},
+	{"VA", "Virginia"},
+	{"WA", "Washington"},
+	{"WV", "West Virginia"},
+	{"WI", "Wisconsin"},
+	{"WY", "Wyoming"},
+}
+
+// Cities is a sample of the larger cities in each of States
+var Cities = []City{
+	{"Birmingham", "AL", "352"},
+	{"Montgomery", "AL", "361"},
+	{"Anchorage", "AK", "995"},
+	{"Fairbanks", "AK", "997"},
+	{"Phoenix", "AZ", "850"},
+	{"Tucson", "AZ", "857"},
+	{"Little Rock", "AR", "722"},
+	{"Fayetteville", "AR", "727"},
+	{"Los Angeles", "CA", "900"},
+	{"San Francisco", "CA", "941"},
+	{"San Diego", "CA", "921"},
+	{"Sacramento", "CA", "958"},
+	{"Denver", "CO", "802"},
+	{"Colorado Springs", "CO", "809"},
+	{"Hartford", "CT", "061"},
+	{"New Haven", "CT", "065"},
+	{"Wilmington", "DE", "198"},
+	{"Dover", "DE", "199"},
+	{"Washington", "DC", "200"},
+	{"Miami", "FL", "331"},
+	{"Orlando", "FL", "328"},
+	{"Tampa", "FL", "336"},
+	{"Atlanta", "GA", "303"},
+	{"Savannah", "GA", "314"},
+	{"Honolulu", "HI", "968"},
+	{"Hilo", "HI", "967"},
+	{"Boise", "ID", "837"},
+	{"Idaho Falls", "ID", "834"},
+	{"Chicago", "IL", "606"},
+	{"Springfield", "IL", "627"},
+	{"Indianapolis", "IN", "462"},
+	{"Fort Wayne", "IN", "468"},
+	{"Des Moines", "IA", "503"},
+	{"Cedar Rapids", "IA", "524"},
+	{"Wichita", "KS", "672"},
+	{"Topeka", "KS", "666"},
+	{"Louisville", "KY", "402"},
+	{"Lexington", "KY", "405"},
+	{"New Orleans", "LA", "701"},
+	{"Baton Rouge", "LA", "708"},
+	{"Portland", "ME", "041"},
+	{"Bangor", "ME", "044"},
+	{"Baltimore", "MD", "212"},
+	{"Annapolis", "MD", "214"},
+	{"Boston", "MA", "021"},
+	{"Worcester", "MA", "016"},
+	{"Detroit", "MI", "482"},
+	{"Grand Rapids", "MI", "495"},
+	{"Minneapolis", "MN", "554"},
+	{"Saint Paul", "MN", "551"},
+	{"Jackson", "MS", "392"},
+	{"Gulfport", "MS", "395"},
+	{"Kansas City", "MO", "641"},
+	{"Saint Louis", "MO", "631"},
+	{"Billings", "MT", "591"},
+	{"Missoula", "MT", "598"},
+	{"Omaha", "NE", "681"},
+	{"Lincoln", "NE", "685"},
+	{"Las Vegas", "NV", "891"},
+	{"Reno", "NV", "895"},
+	{"Manchester", "NH", "031"},
+	{"Concord", "NH", "033"},
+	{"Newark", "NJ", "071"},
+	{"Trenton", "NJ", "086"},
+	{"Albuquerque", "NM", "871"},
+	{"Santa Fe", "NM", "875"},
+	{"New York", "NY", "100"},
+	{"Buffalo", "NY", "142"},
+	{"Albany", "NY", "122"},
+	{"Charlotte", "NC", "282"},
+	{"Raleigh", "NC", "276"},
+	{"Fargo", "ND", "581"},
+	{"Bismarck", "ND", "585"},
+	{"Columbus", "OH", "432"},
+	{"Cleveland", "OH", "441"},
+	{"Cincinnati", "OH", "452"},
+	{"Oklahoma City", "OK", "731"},
+	{"Tulsa", "OK", "741"},
+	{"Portland", "OR", "972"},
+	{"Eugene", "OR", "974"},
+	{"Philadelphia", "PA", "191"},
+	{"Pittsburgh", "PA", "152"},
+	{"Providence", "RI", "029"},
+	{"Warwick", "RI", "028"},
+	{"Columbia", "SC", "292"},
+	{"Charleston", "SC", "294"},
+	{"Sioux Falls", "SD", "571"},
+	{"Rapid City", "SD", "577"},
+	{"Nashville", "TN", "372"},
+	{"Memphis", "TN", "381"},
+	{"Houston", "TX", "770"},
+	{"Dallas", "TX", "752"},
+	{"Austin", "TX", "787"},
+	{"San Antonio", "TX", "782"},
+	{"Salt Lake City", "UT", "841"},
+	{"Provo", "UT", "846"},
+	{"Burlington", "VT", "054"},
+	{"Montpelier", "VT", "056"},
+	{"Richmond", "VA", "232"},
+	{"Norfolk", "VA", "235"},
+	{"Seattle", "WA", "981"},
+	{"Spokane", "WA", "992"},
+	{"Charleston", "WV", "253"},
+	{"Morgantown", "WV", "265"},
+	{"Milwaukee", "WI", "532"},
+	{"Madison", "WI", "537"},
+	{"Cheyenne", "WY", "820"},
+	{"Casper", "WY", "826"},
+}
+
+// StreetNames are common names of streets, for building street addresses
+var StreetNames = []string{
+	"Main",
+	"Oak",
+	"Maple",
+	"Pine",
+	"Cedar",
+	"Elm",
+	"Washington",
+	"Lake",
+	"Hill",
+	"Park",
+	"Walnut",
+	"Spring",
+	"Church",
+	"Highland",
+	"Sunset",
+	"Ridge",
+	"Jefferson",
+	"Lincoln",
+	"Madison",
+	"Franklin",
+	"Chestnut",
+	"Willow",
+	"River",
+	"Mill",
+	"Meadow",
+	"Forest",
+	"Jackson",
+	"Adams",
+	"Center",
+	"Union",
+}
+
+// StreetSuffixes are the USPS abbreviations of common street types
+var StreetSuffixes = []string{
+	"St",
+	"Ave",
+	"Blvd",
+	"Rd",
+	"Ln",
+	"Dr",
+	"Ct",
+	"Way",
+	"Pl",
+}
+
+// FindState returns the State with the given code or name, ignoring case
+func FindState(s string) (State, bool) {
+	for _, st := range States {
+		if strings.EqualFold(st.Code, s) || strings.EqualFold(st.Name, s) {
+			return st, true
+		}
+	}
+	return State{}, false
+}
+
+// CitiesIn returns every one of Cities in the state with the given code
+func CitiesIn(state string) []City {
+	cities := make([]City, 0)
+	for _, c := range Cities {
+		if c.State == state {
+			cities = append(cities, c)
+		}
+	}
+	return cities
+}
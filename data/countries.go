@@ -268,3 +268,769 @@ var CountryCodes = []string{
 	"UK",
 	"UN",
 }
+
+// CountryPopulations holds the approximate population of each country in CountryCodes,
+// in thousands, as estimated for 2020. Codes which are missing either have no permanent
+// population of their own, such as AQ, or are reserved for a region which is already
+// counted under another code, such as UK (GB) and EU.
+var CountryPopulations = map[string]int{
+	"AD": 77,
+	"AE": 9890,
+	"AF": 38928,
+	"AG": 98,
+	"AI": 15,
+	"AL": 2878,
+	"AM": 2963,
+	"AO": 32866,
+	"AR": 45196,
+	"AS": 55,
+	"AT": 9006,
+	"AU": 25500,
+	"AW": 107,
+	"AX": 30,
+	"AZ": 10139,
+	"BA": 3281,
+	"BB": 287,
+	"BD": 164689,
+	"BE": 11590,
+	"BF": 20903,
+	"BG": 6948,
+	"BH": 1702,
+	"BI": 11891,
+	"BJ": 12123,
+	"BL": 10,
+	"BM": 62,
+	"BN": 437,
+	"BO": 11673,
+	"BQ": 26,
+	"BR": 212559,
+	"BS": 393,
+	"BT": 772,
+	"BW": 2352,
+	"BY": 9449,
+	"BZ": 398,
+	"CA": 37742,
+	"CC": 1,
+	"CD": 89561,
+	"CF": 4830,
+	"CG": 5518,
+	"CH": 8655,
+	"CI": 26378,
+	"CK": 18,
+	"CL": 19116,
+	"CM": 26546,
+	"CN": 1439324,
+	"CO": 50883,
+	"CR": 5094,
+	"CU": 11327,
+	"CV": 556,
+	"CW": 164,
+	"CX": 2,
+	"CY": 1207,
+	"CZ": 10709,
+	"DE": 83784,
+	"DJ": 988,
+	"DK": 5792,
+	"DM": 72,
+	"DO": 10848,
+	"DZ": 43851,
+	"EC": 17643,
+	"EE": 1327,
+	"EG": 102334,
+	"EH": 597,
+	"ER": 3546,
+	"ES": 46755,
+	"ET": 114964,
+	"FI": 5541,
+	"FJ": 896,
+	"FK": 3,
+	"FM": 115,
+	"FO": 49,
+	"FR": 65274,
+	"GA": 2226,
+	"GB": 67886,
+	"GD": 113,
+	"GE": 3989,
+	"GF": 299,
+	"GG": 63,
+	"GH": 31073,
+	"GI": 34,
+	"GL": 57,
+	"GM": 2417,
+	"GN": 13133,
+	"GP": 400,
+	"GQ": 1403,
+	"GR": 10423,
+	"GT": 17916,
+	"GU": 169,
+	"GW": 1968,
+	"GY": 787,
+	"HK": 7497,
+	"HN": 9905,
+	"HR": 4105,
+	"HT": 11403,
+	"HU": 9660,
+	"ID": 273524,
+	"IE": 4938,
+	"IL": 8656,
+	"IM": 85,
+	"IN": 1380004,
+	"IO": 3,
+	"IQ": 40223,
+	"IR": 83993,
+	"IS": 341,
+	"IT": 60462,
+	"JE": 101,
+	"JM": 2961,
+	"JO": 10203,
+	"JP": 126476,
+	"KE": 53771,
+	"KG": 6524,
+	"KH": 16719,
+	"KI": 119,
+	"KM": 870,
+	"KN": 53,
+	"KP": 25779,
+	"KR": 51269,
+	"KW": 4271,
+	"KY": 66,
+	"KZ": 18777,
+	"LA": 7276,
+	"LB": 6825,
+	"LC": 184,
+	"LI": 38,
+	"LK": 21413,
+	"LR": 5058,
+	"LS": 2142,
+	"LT": 2722,
+	"LU": 626,
+	"LV": 1886,
+	"LY": 6871,
+	"MA": 36911,
+	"MC": 39,
+	"MD": 4034,
+	"ME": 628,
+	"MF": 39,
+	"MG": 27691,
+	"MH": 59,
+	"MK": 2083,
+	"ML": 20251,
+	"MM": 54410,
+	"MN": 3278,
+	"MO": 649,
+	"MP": 58,
+	"MQ": 375,
+	"MR": 4650,
+	"MS": 5,
+	"MT": 442,
+	"MU": 1272,
+	"MV": 541,
+	"MW": 19130,
+	"MX": 128933,
+	"MY": 32366,
+	"MZ": 31255,
+	"NA": 2541,
+	"NC": 285,
+	"NE": 24207,
+	"NF": 2,
+	"NG": 206140,
+	"NI": 6625,
+	"NL": 17135,
+	"NO": 5421,
+	"NP": 29137,
+	"NR": 11,
+	"NU": 2,
+	"NZ": 4822,
+	"OM": 5107,
+	"PA": 4315,
+	"PE": 32972,
+	"PF": 281,
+	"PG": 8947,
+	"PH": 109581,
+	"PK": 220892,
+	"PL": 37847,
+	"PM": 6,
+	"PR": 2861,
+	"PS": 5101,
+	"PT": 10197,
+	"PW": 18,
+	"PY": 7133,
+	"QA": 2881,
+	"RE": 895,
+	"RO": 19238,
+	"RS": 8737,
+	"RU": 145934,
+	"RW": 12952,
+	"SA": 34814,
+	"SB": 687,
+	"SC": 98,
+	"SD": 43849,
+	"SE": 10099,
+	"SG": 5850,
+	"SH": 6,
+	"SI": 2079,
+	"SJ": 3,
+	"SK": 5460,
+	"SL": 7977,
+	"SM": 34,
+	"SN": 16744,
+	"SO": 15893,
+	"SR": 587,
+	"SS": 11194,
+	"ST": 219,
+	"SV": 6486,
+	"SX": 43,
+	"SY": 17501,
+	"SZ": 1160,
+	"TC": 39,
+	"TD": 16426,
+	"TG": 8279,
+	"TH": 69800,
+	"TJ": 9538,
+	"TK": 1,
+	"TL": 1318,
+	"TM": 6031,
+	"TN": 11819,
+	"TO": 106,
+	"TR": 84339,
+	"TT": 1399,
+	"TV": 12,
+	"TW": 23817,
+	"TZ": 59734,
+	"UA": 43734,
+	"UG": 45741,
+	"US": 331003,
+	"UY": 3474,
+	"UZ": 33469,
+	"VA": 1,
+	"VC": 111,
+	"VE": 28436,
+	"VG": 30,
+	"VI": 104,
+	"VN": 97339,
+	"VU": 307,
+	"WF": 11,
+	"WS": 198,
+	"YE": 29826,
+	"YT": 273,
+	"ZA": 59309,
+	"ZM": 18384,
+	"ZW": 14863,
+	"AC": 1,
+}
+
+// CountryCapitals holds the capital city of each country in CountryCodes. Codes which are
+// missing have no capital of their own, either because they have no permanent population,
+// such as AQ, or because they are reserved for a region rather than a country, such as EU.
+// Countries whose seat of government differs from their official capital, such as NL and
+// BO, list the official capital.
+var CountryCapitals = map[string]string{
+	"AD": "Andorra la Vella",
+	"AE": "Abu Dhabi",
+	"AF": "Kabul",
+	"AG": "Saint John's",
+	"AI": "The Valley",
+	"AL": "Tirana",
+	"AM": "Yerevan",
+	"AO": "Luanda",
+	"AR": "Buenos Aires",
+	"AS": "Pago Pago",
+	"AT": "Vienna",
+	"AU": "Canberra",
+	"AW": "Oranjestad",
+	"AX": "Mariehamn",
+	"AZ": "Baku",
+	"BA": "Sarajevo",
+	"BB": "Bridgetown",
+	"BD": "Dhaka",
+	"BE": "Brussels",
+	"BF": "Ouagadougou",
+	"BG": "Sofia",
+	"BH": "Manama",
+	"BI": "Gitega",
+	"BJ": "Porto-Novo",
+	"BL": "Gustavia",
+	"BM": "Hamilton",
+	"BN": "Bandar Seri Begawan",
+	"BO": "Sucre",
+	"BQ": "Kralendijk",
+	"BR": "Brasilia",
+	"BS": "Nassau",
+	"BT": "Thimphu",
+	"BW": "Gaborone",
+	"BY": "Minsk",
+	"BZ": "Belmopan",
+	"CA": "Ottawa",
+	"CC": "West Island",
+	"CD": "Kinshasa",
+	"CF": "Bangui",
+	"CG": "Brazzaville",
+	"CH": "Bern",
+	"CI": "Yamoussoukro",
+	"CK": "Avarua",
+	"CL": "Santiago",
+	"CM": "Yaounde",
+	"CN": "Beijing",
+	"CO": "Bogota",
+	"CR": "San Jose",
+	"CU": "Havana",
+	"CV": "Praia",
+	"CW": "Willemstad",
+	"CX": "Flying Fish Cove",
+	"CY": "Nicosia",
+	"CZ": "Prague",
+	"DE": "Berlin",
+	"DJ": "Djibouti",
+	"DK": "Copenhagen",
+	"DM": "Roseau",
+	"DO": "Santo Domingo",
+	"DZ": "Algiers",
+	"EC": "Quito",
+	"EE": "Tallinn",
+	"EG": "Cairo",
+	"EH": "Laayoune",
+	"ER": "Asmara",
+	"ES": "Madrid",
+	"ET": "Addis Ababa",
+	"FI": "Helsinki",
+	"FJ": "Suva",
+	"FK": "Stanley",
+	"FM": "Palikir",
+	"FO": "Torshavn",
+	"FR": "Paris",
+	"GA": "Libreville",
+	"GB": "London",
+	"GD": "Saint George's",
+	"GE": "Tbilisi",
+	"GF": "Cayenne",
+	"GG": "Saint Peter Port",
+	"GH": "Accra",
+	"GI": "Gibraltar",
+	"GL": "Nuuk",
+	"GM": "Banjul",
+	"GN": "Conakry",
+	"GP": "Basse-Terre",
+	"GQ": "Malabo",
+	"GR": "Athens",
+	"GS": "King Edward Point",
+	"GT": "Guatemala City",
+	"GU": "Hagatna",
+	"GW": "Bissau",
+	"GY": "Georgetown",
+	"HK": "Hong Kong",
+	"HN": "Tegucigalpa",
+	"HR": "Zagreb",
+	"HT": "Port-au-Prince",
+	"HU": "Budapest",
+	"ID": "Jakarta",
+	"IE": "Dublin",
+	"IL": "Jerusalem",
+	"IM": "Douglas",
+	"IN": "New Delhi",
+	"IO": "Diego Garcia",
+	"IQ": "Baghdad",
+	"IR": "Tehran",
+	"IS": "Reykjavik",
+	"IT": "Rome",
+	"JE": "Saint Helier",
+	"JM": "Kingston",
+	"JO": "Amman",
+	"JP": "Tokyo",
+	"KE": "Nairobi",
+	"KG": "Bishkek",
+	"KH": "Phnom Penh",
+	"KI": "Tarawa",
+	"KM": "Moroni",
+	"KN": "Basseterre",
+	"KP": "Pyongyang",
+	"KR": "Seoul",
+	"KW": "Kuwait City",
+	"KY": "George Town",
+	"KZ": "Astana",
+	"LA": "Vientiane",
+	"LB": "Beirut",
+	"LC": "Castries",
+	"LI": "Vaduz",
+	"LK": "Sri Jayawardenepura Kotte",
+	"LR": "Monrovia",
+	"LS": "Maseru",
+	"LT": "Vilnius",
+	"LU": "Luxembourg",
+	"LV": "Riga",
+	"LY": "Tripoli",
+	"MA": "Rabat",
+	"MC": "Monaco",
+	"MD": "Chisinau",
+	"ME": "Podgorica",
+	"MF": "Marigot",
+	"MG": "Antananarivo",
+	"MH": "Majuro",
+	"MK": "Skopje",
+	"ML": "Bamako",
+	"MM": "Naypyidaw",
+	"MN": "Ulaanbaatar",
+	"MO": "Macau",
+	"MP": "Saipan",
+	"MQ": "Fort-de-France",
+	"MR": "Nouakchott",
+	"MS": "Brades",
+	"MT": "Valletta",
+	"MU": "Port Louis",
+	"MV": "Male",
+	"MW": "Lilongwe",
+	"MX": "Mexico City",
+	"MY": "Kuala Lumpur",
+	"MZ": "Maputo",
+	"NA": "Windhoek",
+	"NC": "Noumea",
+	"NE": "Niamey",
+	"NF": "Kingston",
+	"NG": "Abuja",
+	"NI": "Managua",
+	"NL": "Amsterdam",
+	"NO": "Oslo",
+	"NP": "Kathmandu",
+	"NR": "Yaren",
+	"NU": "Alofi",
+	"NZ": "Wellington",
+	"OM": "Muscat",
+	"PA": "Panama City",
+	"PE": "Lima",
+	"PF": "Papeete",
+	"PG": "Port Moresby",
+	"PH": "Manila",
+	"PK": "Islamabad",
+	"PL": "Warsaw",
+	"PM": "Saint-Pierre",
+	"PN": "Adamstown",
+	"PR": "San Juan",
+	"PS": "Ramallah",
+	"PT": "Lisbon",
+	"PW": "Ngerulmud",
+	"PY": "Asuncion",
+	"QA": "Doha",
+	"RE": "Saint-Denis",
+	"RO": "Bucharest",
+	"RS": "Belgrade",
+	"RU": "Moscow",
+	"RW": "Kigali",
+	"SA": "Riyadh",
+	"SB": "Honiara",
+	"SC": "Victoria",
+	"SD": "Khartoum",
+	"SE": "Stockholm",
+	"SG": "Singapore",
+	"SH": "Jamestown",
+	"SI": "Ljubljana",
+	"SJ": "Longyearbyen",
+	"SK": "Bratislava",
+	"SL": "Freetown",
+	"SM": "San Marino",
+	"SN": "Dakar",
+	"SO": "Mogadishu",
+	"SR": "Paramaribo",
+	"SS": "Juba",
+	"ST": "Sao Tome",
+	"SV": "San Salvador",
+	"SX": "Philipsburg",
+	"SY": "Damascus",
+	"SZ": "Mbabane",
+	"TC": "Cockburn Town",
+	"TD": "N'Djamena",
+	"TF": "Port-aux-Francais",
+	"TG": "Lome",
+	"TH": "Bangkok",
+	"TJ": "Dushanbe",
+	"TK": "Nukunonu",
+	"TL": "Dili",
+	"TM": "Ashgabat",
+	"TN": "Tunis",
+	"TO": "Nuku'alofa",
+	"TR": "Ankara",
+	"TT": "Port of Spain",
+	"TV": "Funafuti",
+	"TW": "Taipei",
+	"TZ": "Dodoma",
+	"UA": "Kyiv",
+	"UG": "Kampala",
+	"US": "Washington, D.C.",
+	"UY": "Montevideo",
+	"UZ": "Tashkent",
+	"VA": "Vatican City",
+	"VC": "Kingstown",
+	"VE": "Caracas",
+	"VG": "Road Town",
+	"VI": "Charlotte Amalie",
+	"VN": "Hanoi",
+	"VU": "Port Vila",
+	"WF": "Mata-Utu",
+	"WS": "Apia",
+	"YE": "Sanaa",
+	"YT": "Mamoudzou",
+	"ZA": "Pretoria",
+	"ZM": "Lusaka",
+	"ZW": "Harare",
+	"AC": "Georgetown",
+	"TA": "Edinburgh of the Seven Seas",
+	"UK": "London",
+}
+
+// CountryCallingCodes holds the calling code of each country in CountryCodes, without
+// the +, as used by {phone}. The countries of the North American Numbering Plan, such
+// as JM, share the code 1, and tell themselves apart by area code. Codes which are
+// missing have no calling code of their own, such as AQ and EU.
+var CountryCallingCodes = map[string]string{
+	"AD": "376",
+	"AE": "971",
+	"AF": "93",
+	"AG": "1",
+	"AI": "1",
+	"AL": "355",
+	"AM": "374",
+	"AO": "244",
+	"AR": "54",
+	"AS": "1",
+	"AT": "43",
+	"AU": "61",
+	"AW": "297",
+	"AX": "358",
+	"AZ": "994",
+	"BA": "387",
+	"BB": "1",
+	"BD": "880",
+	"BE": "32",
+	"BF": "226",
+	"BG": "359",
+	"BH": "973",
+	"BI": "257",
+	"BJ": "229",
+	"BL": "590",
+	"BM": "1",
+	"BN": "673",
+	"BO": "591",
+	"BQ": "599",
+	"BR": "55",
+	"BS": "1",
+	"BT": "975",
+	"BW": "267",
+	"BY": "375",
+	"BZ": "501",
+	"CA": "1",
+	"CC": "61",
+	"CD": "243",
+	"CF": "236",
+	"CG": "242",
+	"CH": "41",
+	"CI": "225",
+	"CK": "682",
+	"CL": "56",
+	"CM": "237",
+	"CN": "86",
+	"CO": "57",
+	"CR": "506",
+	"CU": "53",
+	"CV": "238",
+	"CW": "599",
+	"CX": "61",
+	"CY": "357",
+	"CZ": "420",
+	"DE": "49",
+	"DJ": "253",
+	"DK": "45",
+	"DM": "1",
+	"DO": "1",
+	"DZ": "213",
+	"EC": "593",
+	"EE": "372",
+	"EG": "20",
+	"EH": "212",
+	"ER": "291",
+	"ES": "34",
+	"ET": "251",
+	"FI": "358",
+	"FJ": "679",
+	"FK": "500",
+	"FM": "691",
+	"FO": "298",
+	"FR": "33",
+	"GA": "241",
+	"GB": "44",
+	"GD": "1",
+	"GE": "995",
+	"GF": "594",
+	"GG": "44",
+	"GH": "233",
+	"GI": "350",
+	"GL": "299",
+	"GM": "220",
+	"GN": "224",
+	"GP": "590",
+	"GQ": "240",
+	"GR": "30",
+	"GS": "500",
+	"GT": "502",
+	"GU": "1",
+	"GW": "245",
+	"GY": "592",
+	"HK": "852",
+	"HN": "504",
+	"HR": "385",
+	"HT": "509",
+	"HU": "36",
+	"ID": "62",
+	"IE": "353",
+	"IL": "972",
+	"IM": "44",
+	"IN": "91",
+	"IO": "246",
+	"IQ": "964",
+	"IR": "98",
+	"IS": "354",
+	"IT": "39",
+	"JE": "44",
+	"JM": "1",
+	"JO": "962",
+	"JP": "81",
+	"KE": "254",
+	"KG": "996",
+	"KH": "855",
+	"KI": "686",
+	"KM": "269",
+	"KN": "1",
+	"KP": "850",
+	"KR": "82",
+	"KW": "965",
+	"KY": "1",
+	"KZ": "7",
+	"LA": "856",
+	"LB": "961",
+	"LC": "1",
+	"LI": "423",
+	"LK": "94",
+	"LR": "231",
+	"LS": "266",
+	"LT": "370",
+	"LU": "352",
+	"LV": "371",
+	"LY": "218",
+	"MA": "212",
+	"MC": "377",
+	"MD": "373",
+	"ME": "382",
+	"MF": "590",
+	"MG": "261",
+	"MH": "692",
+	"MK": "389",
+	"ML": "223",
+	"MM": "95",
+	"MN": "976",
+	"MO": "853",
+	"MP": "1",
+	"MQ": "596",
+	"MR": "222",
+	"MS": "1",
+	"MT": "356",
+	"MU": "230",
+	"MV": "960",
+	"MW": "265",
+	"MX": "52",
+	"MY": "60",
+	"MZ": "258",
+	"NA": "264",
+	"NC": "687",
+	"NE": "227",
+	"NF": "672",
+	"NG": "234",
+	"NI": "505",
+	"NL": "31",
+	"NO": "47",
+	"NP": "977",
+	"NR": "674",
+	"NU": "683",
+	"NZ": "64",
+	"OM": "968",
+	"PA": "507",
+	"PE": "51",
+	"PF": "689",
+	"PG": "675",
+	"PH": "63",
+	"PK": "92",
+	"PL": "48",
+	"PM": "508",
+	"PN": "64",
+	"PR": "1",
+	"PS": "970",
+	"PT": "351",
+	"PW": "680",
+	"PY": "595",
+	"QA": "974",
+	"RE": "262",
+	"RO": "40",
+	"RS": "381",
+	"RU": "7",
+	"RW": "250",
+	"SA": "966",
+	"SB": "677",
+	"SC": "248",
+	"SD": "249",
+	"SE": "46",
+	"SG": "65",
+	"SH": "290",
+	"SI": "386",
+	"SJ": "47",
+	"SK": "421",
+	"SL": "232",
+	"SM": "378",
+	"SN": "221",
+	"SO": "252",
+	"SR": "597",
+	"SS": "211",
+	"ST": "239",
+	"SV": "503",
+	"SX": "1",
+	"SY": "963",
+	"SZ": "268",
+	"TC": "1",
+	"TD": "235",
+	"TF": "262",
+	"TG": "228",
+	"TH": "66",
+	"TJ": "992",
+	"TK": "690",
+	"TL": "670",
+	"TM": "993",
+	"TN": "216",
+	"TO": "676",
+	"TR": "90",
+	"TT": "1",
+	"TV": "688",
+	"TW": "886",
+	"TZ": "255",
+	"UA": "380",
+	"UG": "256",
+	"UM": "1",
+	"US": "1",
+	"UY": "598",
+	"UZ": "998",
+	"VA": "39",
+	"VC": "1",
+	"VE": "58",
+	"VG": "1",
+	"VI": "1",
+	"VN": "84",
+	"VU": "678",
+	"WF": "681",
+	"WS": "685",
+	"YE": "967",
+	"YT": "262",
+	"ZA": "27",
+	"ZM": "260",
+	"ZW": "263",
+	"AC": "247",
+	"DG": "246",
+	"EA": "34",
+	"FX": "33",
+	"IC": "34",
+	"TA": "290",
+	"UK": "44",
+}
@@ -0,0 +1,64 @@
+package data
+
+// Currency is an ISO 4217 currency, along with the conventions for writing out an
+// amount of it
+type Currency struct {
+	// Code is the ISO 4217 alphabetic code
+	Code string
+	// Symbol is written before an amount of the currency
+	Symbol string
+	// Decimals is the number of digits after the decimal point, per it's minor unit
+	Decimals int
+}
+
+// Currencies is a list of commonly traded currencies, gathered from here:
+// https://en.wikipedia.org/wiki/ISO_4217#Active_codes
+// If you'd like to see a currency added, please open a PR.
+var Currencies = []Currency{
+	{"AED", "د.إ", 2},
+	{"ARS", "$", 2},
+	{"AUD", "$", 2},
+	{"BHD", ".د.ب", 3},
+	{"BRL", "R$", 2},
+	{"CAD", "$", 2},
+	{"CHF", "CHF", 2},
+	{"CLP", "$", 0},
+	{"CNY", "¥", 2},
+	{"CZK", "Kč", 2},
+	{"DKK", "kr", 2},
+	{"EUR", "€", 2},
+	{"GBP", "£", 2},
+	{"HKD", "$", 2},
+	{"HUF", "Ft", 2},
+	{"IDR", "Rp", 2},
+	{"ILS", "₪", 2},
+	{"INR", "₹", 2},
+	{"ISK", "kr", 0},
+	{"JPY", "¥", 0},
+	{"KRW", "₩", 0},
+	{"KWD", "د.ك", 3},
+	{"MDL", "L", 2},
+	{"MXN", "$", 2},
+	{"NOK", "kr", 2},
+	{"NZD", "$", 2},
+	{"PLN", "zł", 2},
+	{"RON", "lei", 2},
+	{"RUB", "₽", 2},
+	{"SEK", "kr", 2},
+	{"SGD", "$", 2},
+	{"THB", "฿", 2},
+	{"TRY", "₺", 2},
+	{"UAH", "₴", 2},
+	{"USD", "$", 2},
+	{"ZAR", "R", 2},
+}
+
+// FindCurrency returns the currency with the given code, if it is known
+func FindCurrency(code string) (Currency, bool) {
+	for _, c := range Currencies {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return Currency{}, false
+}
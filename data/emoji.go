@@ -0,0 +1,30 @@
+package data
+
+// EmojiByCategory holds the emoji {emoji} picks from, keyed by category. Some are made
+// up of several code points, joined with a zero width joiner, or given a skin tone or
+// variation selector, and are always written out whole.
+var EmojiByCategory = map[string][]string{
+	"faces": {"😀", "😃", "😄", "😁", "😆", "😅", "🤣", "😂", "🙂", "😉",
+		"😊", "😇", "🥰", "😍", "🤩", "😘", "😋", "😛", "🤔", "🤨",
+		"😐", "😑", "😶", "🙄", "😏", "😬", "😌", "😴", "🤯", "🥳",
+		"😎", "🤓", "😕", "😟", "😮", "😱", "😭", "😤", "😡", "🥶",
+		"😶‍🌫️", "😮‍💨", "😵‍💫"},
+	"animals": {"🐶", "🐱", "🐭", "🐹", "🐰", "🦊", "🐻", "🐼", "🐨", "🐯",
+		"🦁", "🐮", "🐷", "🐸", "🐵", "🐔", "🐧", "🐦", "🦆", "🦉",
+		"🐺", "🐴", "🦄", "🐝", "🦋", "🐢", "🐍", "🐙", "🐬", "🐳",
+		"🐻‍❄️", "🐕‍🦺", "🐈‍⬛", "🐦‍⬛"},
+	"food": {"🍏", "🍎", "🍐", "🍊", "🍋", "🍌", "🍉", "🍇", "🍓", "🍒",
+		"🍑", "🥭", "🍍", "🥥", "🥝", "🍅", "🥑", "🥦", "🌽", "🥕",
+		"🍞", "🧀", "🥚", "🥓", "🍔", "🍟", "🍕", "🌮", "🍣", "🍩",
+		"🍪", "🎂", "☕", "🍵"},
+	"hands": {"👍", "👎", "👌", "✌️", "🤞", "🤟", "🤘", "👋", "👏", "🙌",
+		"🙏", "💪", "👍🏻", "👍🏽", "👍🏿", "👋🏼", "👋🏾", "👏🏻", "👏🏿", "🙏🏽",
+		"✌🏼", "💪🏾"},
+	"people": {"👶", "🧒", "👦", "👧", "🧑", "👩", "👨", "🧓", "👵", "👴",
+		"👩‍💻", "👨‍💻", "🧑‍💻", "👩‍🚀", "🧑‍🚀", "👨‍🍳", "👩‍🔬", "🧑‍🎨", "👩🏽‍🏫", "👨🏿‍🚒",
+		"👨‍👩‍👧", "👩‍👩‍👦", "🧑‍🤝‍🧑", "🏃‍♀️", "🤷‍♂️"},
+	"hearts": {"❤️", "🧡", "💛", "💚", "💙", "💜", "🖤", "🤍", "🤎", "💔",
+		"❣️", "💕", "💖", "💘", "❤️‍🔥", "❤️‍🩹"},
+	"flags": {"🇺🇸", "🇬🇧", "🇨🇦", "🇩🇪", "🇫🇷", "🇯🇵", "🇧🇷", "🇮🇳", "🇲🇩", "🇰🇪",
+		"🏳️", "🏴", "🏁", "🏳️‍🌈", "🏴‍☠️"},
+}
@@ -0,0 +1,86 @@
+package data
+
+// LocaleNames are the first and last names commonly given in the places where a
+// locale's language is spoken, spelled as they would be written there. Unlike the
+// spellings of FirstNames and LastNames, which translate one name into many languages,
+// these are the names people there actually have.
+type LocaleNames struct {
+	First []string
+	Last  []string
+}
+
+// NamesByLocale holds the names for each supported locale, keyed by it's ISO 639-1
+// language code. Please contribute more locales, or more names for existing ones!
+var NamesByLocale = map[string]LocaleNames{
+	"de": LocaleNames{
+		First: []string{"Lukas", "Leon", "Finn", "Jonas", "Paul", "Felix", "Maximilian", "Elias", "Ben", "Jürgen",
+			"Mia", "Emma", "Hannah", "Sophie", "Lena", "Lea", "Marie", "Anna", "Katharina", "Jutta"},
+		Last: []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann",
+			"Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf", "Schröder", "Neumann", "Schwarz", "Zimmermann"},
+	},
+	"es": LocaleNames{
+		First: []string{"Hugo", "Mateo", "Martín", "Lucas", "Leo", "Daniel", "Alejandro", "Pablo", "Manuel", "Álvaro",
+			"Lucía", "Sofía", "Martina", "María", "Julia", "Paula", "Valeria", "Emma", "Daniela", "Carmen"},
+		Last: []string{"García", "Rodríguez", "González", "Fernández", "López", "Martínez", "Sánchez", "Pérez", "Gómez", "Martín",
+			"Jiménez", "Ruiz", "Hernández", "Díaz", "Moreno", "Muñoz", "Álvarez", "Romero", "Alonso", "Gutiérrez"},
+	},
+	"fr": LocaleNames{
+		First: []string{"Gabriel", "Léo", "Raphaël", "Louis", "Lucas", "Jules", "Hugo", "Arthur", "Théo", "Noé",
+			"Jade", "Louise", "Emma", "Alice", "Chloé", "Léa", "Manon", "Inès", "Camille", "Zoé"},
+		Last: []string{"Martin", "Bernard", "Thomas", "Petit", "Robert", "Richard", "Durand", "Dubois", "Moreau", "Laurent",
+			"Simon", "Michel", "Lefèvre", "Leroy", "Roux", "David", "Bertrand", "Morel", "Fournier", "Girard"},
+	},
+	"it": LocaleNames{
+		First: []string{"Leonardo", "Francesco", "Alessandro", "Lorenzo", "Mattia", "Andrea", "Gabriele", "Riccardo", "Tommaso", "Niccolò",
+			"Sofia", "Giulia", "Aurora", "Alice", "Ginevra", "Emma", "Giorgia", "Greta", "Beatrice", "Chiara"},
+		Last: []string{"Rossi", "Russo", "Ferrari", "Esposito", "Bianchi", "Romano", "Colombo", "Ricci", "Marino", "Greco",
+			"Bruno", "Gallo", "Conti", "De Luca", "Mancini", "Costa", "Giordano", "Rizzo", "Lombardi", "Moretti"},
+	},
+	"ja": LocaleNames{
+		First: []string{"蓮", "陽翔", "湊", "大和", "悠真", "樹", "翔", "健太", "大輔", "浩",
+			"陽葵", "凛", "結菜", "葵", "結衣", "美咲", "さくら", "愛子", "陽子", "花子"},
+		Last: []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村", "小林", "加藤",
+			"吉田", "山田", "佐々木", "山口", "松本", "井上", "木村", "林", "斎藤", "清水"},
+	},
+	"nl": LocaleNames{
+		First: []string{"Noah", "Daan", "Lucas", "Sem", "Levi", "Bram", "Luuk", "Thijs", "Jesse", "Sven",
+			"Emma", "Julia", "Tess", "Sophie", "Zoë", "Fenna", "Sanne", "Lotte", "Anouk", "Femke"},
+		Last: []string{"de Jong", "Jansen", "de Vries", "van den Berg", "van Dijk", "Bakker", "Janssen", "Visser", "Smit", "Meijer",
+			"de Boer", "Mulder", "de Groot", "Bos", "Vos", "Peters", "Hendriks", "van Leeuwen", "Dekker", "Brouwer"},
+	},
+	"pt": LocaleNames{
+		First: []string{"Miguel", "Arthur", "Gael", "Heitor", "Davi", "Gabriel", "Bernardo", "João", "Pedro", "Tomás",
+			"Helena", "Alice", "Laura", "Maria", "Beatriz", "Leonor", "Matilde", "Sofia", "Mariana", "Inês"},
+		Last: []string{"Silva", "Santos", "Ferreira", "Pereira", "Oliveira", "Costa", "Rodrigues", "Martins", "Jesus", "Sousa",
+			"Fernandes", "Gonçalves", "Gomes", "Lopes", "Marques", "Alves", "Almeida", "Ribeiro", "Pinto", "Carvalho"},
+	},
+}
+
+// CountryLocales maps the codes of countries, as generated by {country}, to the locale
+// of the names most commonly found there
+var CountryLocales = map[string]string{
+	"AT": "de",
+	"CH": "de",
+	"DE": "de",
+	"LI": "de",
+	"AR": "es",
+	"CL": "es",
+	"CO": "es",
+	"ES": "es",
+	"MX": "es",
+	"PE": "es",
+	"VE": "es",
+	"BE": "fr",
+	"FR": "fr",
+	"LU": "fr",
+	"MC": "fr",
+	"IT": "it",
+	"SM": "it",
+	"JP": "ja",
+	"NL": "nl",
+	"SR": "nl",
+	"AO": "pt",
+	"BR": "pt",
+	"MZ": "pt",
+	"PT": "pt",
+}
@@ -0,0 +1,69 @@
+package data
+
+// MimeTypes is a list of common MIME types, as registered with IANA here:
+// https://www.iana.org/assignments/media-types/media-types.xhtml
+// If you'd like to see a type added, please open a PR.
+var MimeTypes = []string{
+	// Application
+	"application/gzip",
+	"application/java-archive",
+	"application/javascript",
+	"application/json",
+	"application/ld+json",
+	"application/msword",
+	"application/octet-stream",
+	"application/pdf",
+	"application/rtf",
+	"application/sql",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/x-7z-compressed",
+	"application/x-bzip2",
+	"application/x-tar",
+	"application/xhtml+xml",
+	"application/xml",
+	"application/zip",
+	// Audio
+	"audio/aac",
+	"audio/flac",
+	"audio/midi",
+	"audio/mp4",
+	"audio/mpeg",
+	"audio/ogg",
+	"audio/wav",
+	"audio/webm",
+	// Font
+	"font/otf",
+	"font/ttf",
+	"font/woff",
+	"font/woff2",
+	// Image
+	"image/avif",
+	"image/bmp",
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"image/svg+xml",
+	"image/tiff",
+	"image/vnd.microsoft.icon",
+	"image/webp",
+	// Text
+	"text/calendar",
+	"text/css",
+	"text/csv",
+	"text/html",
+	"text/markdown",
+	"text/plain",
+	"text/xml",
+	// Video
+	"video/mp2t",
+	"video/mp4",
+	"video/mpeg",
+	"video/ogg",
+	"video/quicktime",
+	"video/webm",
+	"video/x-msvideo",
+}
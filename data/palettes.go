@@ -0,0 +1,31 @@
+package data
+
+// Palettes are sets of colors, as hex codes, which are chosen to be easy to tell apart
+// from one another when used together, such as for the series of a chart
+var Palettes = map[string][]string{
+	// The Tableau 10 palette
+	"categorical": {
+		"#4e79a7",
+		"#f28e2b",
+		"#e15759",
+		"#76b7b2",
+		"#59a14f",
+		"#edc948",
+		"#b07aa1",
+		"#ff9da7",
+		"#9c755f",
+		"#bab0ac",
+	},
+	// The Okabe-Ito palette, which stays distinct for the most common kinds of color
+	// blindness
+	"colorblind": {
+		"#e69f00",
+		"#56b4e9",
+		"#009e73",
+		"#f0e442",
+		"#0072b2",
+		"#d55e00",
+		"#cc79a7",
+		"#000000",
+	},
+}
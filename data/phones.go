@@ -0,0 +1,40 @@
+package data
+
+// PhonePlan is how the phone numbers of a country are numbered, which is a simplified
+// version of it's national numbering plan
+type PhonePlan struct {
+	// CallingCode is the country calling code, without the +
+	CallingCode string
+	// Lengths are the number of digits a national number can have, not counting any
+	// trunk prefix
+	Lengths []int
+	// Groups are the sizes of the groups the national number is written out in. The
+	// last group takes any digits left over.
+	Groups []int
+	// TrunkPrefix is dialed before the national number within the country
+	TrunkPrefix string
+	// Separator goes between each group
+	Separator string
+	// NANP marks the countries of the North American Numbering Plan, whose area codes
+	// and exchanges can't start with 0 or 1
+	NANP bool
+}
+
+// PhonePlans are the numbering plans of the countries which phone numbers can be
+// generated for, by their ISO 3166-1 alpha-2 code
+var PhonePlans = map[string]PhonePlan{
+	"US": {CallingCode: "1", Lengths: []int{10}, Groups: []int{3, 3, 4}, Separator: "-", NANP: true},
+	"CA": {CallingCode: "1", Lengths: []int{10}, Groups: []int{3, 3, 4}, Separator: "-", NANP: true},
+	"GB": {CallingCode: "44", Lengths: []int{10}, Groups: []int{4, 6}, TrunkPrefix: "0", Separator: " "},
+	"DE": {CallingCode: "49", Lengths: []int{10, 11}, Groups: []int{3, 8}, TrunkPrefix: "0", Separator: " "},
+	"FR": {CallingCode: "33", Lengths: []int{9}, Groups: []int{1, 2, 2, 2, 2}, TrunkPrefix: "0", Separator: " "},
+	"ES": {CallingCode: "34", Lengths: []int{9}, Groups: []int{3, 3, 3}, Separator: " "},
+	"IT": {CallingCode: "39", Lengths: []int{9, 10}, Groups: []int{3, 7}, Separator: " "},
+	"NL": {CallingCode: "31", Lengths: []int{9}, Groups: []int{2, 7}, TrunkPrefix: "0", Separator: " "},
+	"AU": {CallingCode: "61", Lengths: []int{9}, Groups: []int{1, 4, 4}, TrunkPrefix: "0", Separator: " "},
+	"JP": {CallingCode: "81", Lengths: []int{10}, Groups: []int{2, 4, 4}, TrunkPrefix: "0", Separator: "-"},
+	"IN": {CallingCode: "91", Lengths: []int{10}, Groups: []int{5, 5}, Separator: " "},
+	"BR": {CallingCode: "55", Lengths: []int{10, 11}, Groups: []int{2, 9}, Separator: " "},
+	"MX": {CallingCode: "52", Lengths: []int{10}, Groups: []int{2, 4, 4}, Separator: " "},
+	"CN": {CallingCode: "86", Lengths: []int{11}, Groups: []int{3, 4, 4}, Separator: " "},
+}
@@ -0,0 +1,15 @@
+package data
+
+// PhoneticLetters are the code words of the NATO phonetic alphabet, for A through Z,
+// spelled as ICAO spells them
+var PhoneticLetters = []string{
+	"Alfa", "Bravo", "Charlie", "Delta", "Echo", "Foxtrot", "Golf", "Hotel", "India",
+	"Juliett", "Kilo", "Lima", "Mike", "November", "Oscar", "Papa", "Quebec", "Romeo",
+	"Sierra", "Tango", "Uniform", "Victor", "Whiskey", "X-ray", "Yankee", "Zulu",
+}
+
+// PhoneticDigits are the code words for 0 through 9, as they are written in the NATO
+// phonetic alphabet
+var PhoneticDigits = []string{
+	"Zero", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine",
+}
@@ -0,0 +1,302 @@
+package data
+
+// Subdivision is a state, province or region of a country, as listed in ISO 3166-2
+type Subdivision struct {
+	// Code is the part of the ISO 3166-2 code after the country and the -, such as the
+	// CA of US-CA
+	Code string
+	// Name is the name of the subdivision, as it's written in the country
+	Name string
+}
+
+// CountrySubdivisions holds the top level subdivisions of the more commonly used
+// countries, by their ISO 3166-1 alpha-2 code. Only the countries with the most records
+// written about them are here for now; please contribute more!
+var CountrySubdivisions = map[string][]Subdivision{
+	"AU": {
+		{Code: "ACT", Name: "Australian Capital Territory"},
+		{Code: "NSW", Name: "New South Wales"},
+		{Code: "NT", Name: "Northern Territory"},
+		{Code: "QLD", Name: "Queensland"},
+		{Code: "SA", Name: "South Australia"},
+		{Code: "TAS", Name: "Tasmania"},
+		{Code: "VIC", Name: "Victoria"},
+		{Code: "WA", Name: "Western Australia"},
+	},
+	"BR": {
+		{Code: "AC", Name: "Acre"},
+		{Code: "AL", Name: "Alagoas"},
+		{Code: "AP", Name: "Amapá"},
+		{Code: "AM", Name: "Amazonas"},
+		{Code: "BA", Name: "Bahia"},
+		{Code: "CE", Name: "Ceará"},
+		{Code: "DF", Name: "Distrito Federal"},
+		{Code: "ES", Name: "Espírito Santo"},
+		{Code: "GO", Name: "Goiás"},
+		{Code: "MA", Name: "Maranhão"},
+		{Code: "MT", Name: "Mato Grosso"},
+		{Code: "MS", Name: "Mato Grosso do Sul"},
+		{Code: "MG", Name: "Minas Gerais"},
+		{Code: "PA", Name: "Pará"},
+		{Code: "PB", Name: "Paraíba"},
+		{Code: "PR", Name: "Paraná"},
+		{Code: "PE", Name: "Pernambuco"},
+		{Code: "PI", Name: "Piauí"},
+		{Code: "RJ", Name: "Rio de Janeiro"},
+		{Code: "RN", Name: "Rio Grande do Norte"},
+		{Code: "RS", Name: "Rio Grande do Sul"},
+		{Code: "RO", Name: "Rondônia"},
+		{Code: "RR", Name: "Roraima"},
+		{Code: "SC", Name: "Santa Catarina"},
+		{Code: "SP", Name: "São Paulo"},
+		{Code: "SE", Name: "Sergipe"},
+		{Code: "TO", Name: "Tocantins"},
+	},
+	"CA": {
+		{Code: "AB", Name: "Alberta"},
+		{Code: "BC", Name: "British Columbia"},
+		{Code: "MB", Name: "Manitoba"},
+		{Code: "NB", Name: "New Brunswick"},
+		{Code: "NL", Name: "Newfoundland and Labrador"},
+		{Code: "NS", Name: "Nova Scotia"},
+		{Code: "NT", Name: "Northwest Territories"},
+		{Code: "NU", Name: "Nunavut"},
+		{Code: "ON", Name: "Ontario"},
+		{Code: "PE", Name: "Prince Edward Island"},
+		{Code: "QC", Name: "Quebec"},
+		{Code: "SK", Name: "Saskatchewan"},
+		{Code: "YT", Name: "Yukon"},
+	},
+	"DE": {
+		{Code: "BW", Name: "Baden-Württemberg"},
+		{Code: "BY", Name: "Bayern"},
+		{Code: "BE", Name: "Berlin"},
+		{Code: "BB", Name: "Brandenburg"},
+		{Code: "HB", Name: "Bremen"},
+		{Code: "HH", Name: "Hamburg"},
+		{Code: "HE", Name: "Hessen"},
+		{Code: "MV", Name: "Mecklenburg-Vorpommern"},
+		{Code: "NI", Name: "Niedersachsen"},
+		{Code: "NW", Name: "Nordrhein-Westfalen"},
+		{Code: "RP", Name: "Rheinland-Pfalz"},
+		{Code: "SL", Name: "Saarland"},
+		{Code: "SN", Name: "Sachsen"},
+		{Code: "ST", Name: "Sachsen-Anhalt"},
+		{Code: "SH", Name: "Schleswig-Holstein"},
+		{Code: "TH", Name: "Thüringen"},
+	},
+	"ES": {
+		{Code: "AN", Name: "Andalucía"},
+		{Code: "AR", Name: "Aragón"},
+		{Code: "AS", Name: "Asturias"},
+		{Code: "CN", Name: "Canarias"},
+		{Code: "CB", Name: "Cantabria"},
+		{Code: "CL", Name: "Castilla y León"},
+		{Code: "CM", Name: "Castilla-La Mancha"},
+		{Code: "CT", Name: "Catalunya"},
+		{Code: "EX", Name: "Extremadura"},
+		{Code: "GA", Name: "Galicia"},
+		{Code: "IB", Name: "Illes Balears"},
+		{Code: "RI", Name: "La Rioja"},
+		{Code: "MD", Name: "Madrid"},
+		{Code: "MC", Name: "Murcia"},
+		{Code: "NC", Name: "Navarra"},
+		{Code: "PV", Name: "País Vasco"},
+		{Code: "VC", Name: "Valenciana"},
+		{Code: "CE", Name: "Ceuta"},
+		{Code: "ML", Name: "Melilla"},
+	},
+	"FR": {
+		{Code: "ARA", Name: "Auvergne-Rhône-Alpes"},
+		{Code: "BFC", Name: "Bourgogne-Franche-Comté"},
+		{Code: "BRE", Name: "Bretagne"},
+		{Code: "CVL", Name: "Centre-Val de Loire"},
+		{Code: "20R", Name: "Corse"},
+		{Code: "GES", Name: "Grand Est"},
+		{Code: "HDF", Name: "Hauts-de-France"},
+		{Code: "IDF", Name: "Île-de-France"},
+		{Code: "NOR", Name: "Normandie"},
+		{Code: "NAQ", Name: "Nouvelle-Aquitaine"},
+		{Code: "OCC", Name: "Occitanie"},
+		{Code: "PDL", Name: "Pays de la Loire"},
+		{Code: "PAC", Name: "Provence-Alpes-Côte d'Azur"},
+	},
+	"GB": {
+		{Code: "ENG", Name: "England"},
+		{Code: "NIR", Name: "Northern Ireland"},
+		{Code: "SCT", Name: "Scotland"},
+		{Code: "WLS", Name: "Wales"},
+	},
+	"IT": {
+		{Code: "65", Name: "Abruzzo"},
+		{Code: "77", Name: "Basilicata"},
+		{Code: "78", Name: "Calabria"},
+		{Code: "72", Name: "Campania"},
+		{Code: "45", Name: "Emilia-Romagna"},
+		{Code: "36", Name: "Friuli Venezia Giulia"},
+		{Code: "62", Name: "Lazio"},
+		{Code: "42", Name: "Liguria"},
+		{Code: "25", Name: "Lombardia"},
+		{Code: "57", Name: "Marche"},
+		{Code: "67", Name: "Molise"},
+		{Code: "21", Name: "Piemonte"},
+		{Code: "75", Name: "Puglia"},
+		{Code: "88", Name: "Sardegna"},
+		{Code: "82", Name: "Sicilia"},
+		{Code: "52", Name: "Toscana"},
+		{Code: "32", Name: "Trentino-Alto Adige"},
+		{Code: "55", Name: "Umbria"},
+		{Code: "23", Name: "Valle d'Aosta"},
+		{Code: "34", Name: "Veneto"},
+	},
+	"JP": {
+		{Code: "01", Name: "Hokkaido"},
+		{Code: "02", Name: "Aomori"},
+		{Code: "03", Name: "Iwate"},
+		{Code: "04", Name: "Miyagi"},
+		{Code: "05", Name: "Akita"},
+		{Code: "06", Name: "Yamagata"},
+		{Code: "07", Name: "Fukushima"},
+		{Code: "08", Name: "Ibaraki"},
+		{Code: "09", Name: "Tochigi"},
+		{Code: "10", Name: "Gunma"},
+		{Code: "11", Name: "Saitama"},
+		{Code: "12", Name: "Chiba"},
+		{Code: "13", Name: "Tokyo"},
+		{Code: "14", Name: "Kanagawa"},
+		{Code: "15", Name: "Niigata"},
+		{Code: "16", Name: "Toyama"},
+		{Code: "17", Name: "Ishikawa"},
+		{Code: "18", Name: "Fukui"},
+		{Code: "19", Name: "Yamanashi"},
+		{Code: "20", Name: "Nagano"},
+		{Code: "21", Name: "Gifu"},
+		{Code: "22", Name: "Shizuoka"},
+		{Code: "23", Name: "Aichi"},
+		{Code: "24", Name: "Mie"},
+		{Code: "25", Name: "Shiga"},
+		{Code: "26", Name: "Kyoto"},
+		{Code: "27", Name: "Osaka"},
+		{Code: "28", Name: "Hyogo"},
+		{Code: "29", Name: "Nara"},
+		{Code: "30", Name: "Wakayama"},
+		{Code: "31", Name: "Tottori"},
+		{Code: "32", Name: "Shimane"},
+		{Code: "33", Name: "Okayama"},
+		{Code: "34", Name: "Hiroshima"},
+		{Code: "35", Name: "Yamaguchi"},
+		{Code: "36", Name: "Tokushima"},
+		{Code: "37", Name: "Kagawa"},
+		{Code: "38", Name: "Ehime"},
+		{Code: "39", Name: "Kochi"},
+		{Code: "40", Name: "Fukuoka"},
+		{Code: "41", Name: "Saga"},
+		{Code: "42", Name: "Nagasaki"},
+		{Code: "43", Name: "Kumamoto"},
+		{Code: "44", Name: "Oita"},
+		{Code: "45", Name: "Miyazaki"},
+		{Code: "46", Name: "Kagoshima"},
+		{Code: "47", Name: "Okinawa"},
+	},
+	"MX": {
+		{Code: "AGU", Name: "Aguascalientes"},
+		{Code: "BCN", Name: "Baja California"},
+		{Code: "BCS", Name: "Baja California Sur"},
+		{Code: "CAM", Name: "Campeche"},
+		{Code: "CHP", Name: "Chiapas"},
+		{Code: "CHH", Name: "Chihuahua"},
+		{Code: "CMX", Name: "Ciudad de México"},
+		{Code: "COA", Name: "Coahuila"},
+		{Code: "COL", Name: "Colima"},
+		{Code: "DUR", Name: "Durango"},
+		{Code: "GUA", Name: "Guanajuato"},
+		{Code: "GRO", Name: "Guerrero"},
+		{Code: "HID", Name: "Hidalgo"},
+		{Code: "JAL", Name: "Jalisco"},
+		{Code: "MEX", Name: "México"},
+		{Code: "MIC", Name: "Michoacán"},
+		{Code: "MOR", Name: "Morelos"},
+		{Code: "NAY", Name: "Nayarit"},
+		{Code: "NLE", Name: "Nuevo León"},
+		{Code: "OAX", Name: "Oaxaca"},
+		{Code: "PUE", Name: "Puebla"},
+		{Code: "QUE", Name: "Querétaro"},
+		{Code: "ROO", Name: "Quintana Roo"},
+		{Code: "SLP", Name: "San Luis Potosí"},
+		{Code: "SIN", Name: "Sinaloa"},
+		{Code: "SON", Name: "Sonora"},
+		{Code: "TAB", Name: "Tabasco"},
+		{Code: "TAM", Name: "Tamaulipas"},
+		{Code: "TLA", Name: "Tlaxcala"},
+		{Code: "VER", Name: "Veracruz"},
+		{Code: "YUC", Name: "Yucatán"},
+		{Code: "ZAC", Name: "Zacatecas"},
+	},
+	"NL": {
+		{Code: "DR", Name: "Drenthe"},
+		{Code: "FL", Name: "Flevoland"},
+		{Code: "FR", Name: "Fryslân"},
+		{Code: "GE", Name: "Gelderland"},
+		{Code: "GR", Name: "Groningen"},
+		{Code: "LI", Name: "Limburg"},
+		{Code: "NB", Name: "Noord-Brabant"},
+		{Code: "NH", Name: "Noord-Holland"},
+		{Code: "OV", Name: "Overijssel"},
+		{Code: "UT", Name: "Utrecht"},
+		{Code: "ZE", Name: "Zeeland"},
+		{Code: "ZH", Name: "Zuid-Holland"},
+	},
+	"US": {
+		{Code: "AL", Name: "Alabama"},
+		{Code: "AK", Name: "Alaska"},
+		{Code: "AZ", Name: "Arizona"},
+		{Code: "AR", Name: "Arkansas"},
+		{Code: "CA", Name: "California"},
+		{Code: "CO", Name: "Colorado"},
+		{Code: "CT", Name: "Connecticut"},
+		{Code: "DE", Name: "Delaware"},
+		{Code: "DC", Name: "District of Columbia"},
+		{Code: "FL", Name: "Florida"},
+		{Code: "GA", Name: "Georgia"},
+		{Code: "HI", Name: "Hawaii"},
+		{Code: "ID", Name: "Idaho"},
+		{Code: "IL", Name: "Illinois"},
+		{Code: "IN", Name: "Indiana"},
+		{Code: "IA", Name: "Iowa"},
+		{Code: "KS", Name: "Kansas"},
+		{Code: "KY", Name: "Kentucky"},
+		{Code: "LA", Name: "Louisiana"},
+		{Code: "ME", Name: "Maine"},
+		{Code: "MD", Name: "Maryland"},
+		{Code: "MA", Name: "Massachusetts"},
+		{Code: "MI", Name: "Michigan"},
+		{Code: "MN", Name: "Minnesota"},
+		{Code: "MS", Name: "Mississippi"},
+		{Code: "MO", Name: "Missouri"},
+		{Code: "MT", Name: "Montana"},
+		{Code: "NE", Name: "Nebraska"},
+		{Code: "NV", Name: "Nevada"},
+		{Code: "NH", Name: "New Hampshire"},
+		{Code: "NJ", Name: "New Jersey"},
+		{Code: "NM", Name: "New Mexico"},
+		{Code: "NY", Name: "New York"},
+		{Code: "NC", Name: "North Carolina"},
+		{Code: "ND", Name: "North Dakota"},
+		{Code: "OH", Name: "Ohio"},
+		{Code: "OK", Name: "Oklahoma"},
+		{Code: "OR", Name: "Oregon"},
+		{Code: "PA", Name: "Pennsylvania"},
+		{Code: "RI", Name: "Rhode Island"},
+		{Code: "SC", Name: "South Carolina"},
+		{Code: "SD", Name: "South Dakota"},
+		{Code: "TN", Name: "Tennessee"},
+		{Code: "TX", Name: "Texas"},
+		{Code: "UT", Name: "Utah"},
+		{Code: "VT", Name: "Vermont"},
+		{Code: "VA", Name: "Virginia"},
+		{Code: "WA", Name: "Washington"},
+		{Code: "WV", Name: "West Virginia"},
+		{Code: "WI", Name: "Wisconsin"},
+		{Code: "WY", Name: "Wyoming"},
+	},
+}
@@ -0,0 +1,17 @@
+package data
+
+// Consonants are the consonant sounds {word} builds pronounceable words from, as they
+// are usually spelled in English. Single letters are listed more than once, so they come
+// up more often than the clusters.
+var Consonants = []string{
+	"b", "c", "d", "f", "g", "h", "j", "k", "l", "m", "n", "p", "r", "s", "t", "v", "w", "z",
+	"b", "d", "k", "l", "m", "n", "p", "r", "s", "t",
+	"br", "ch", "cl", "dr", "fl", "gr", "kr", "pl", "sh", "sk", "st", "th", "tr",
+}
+
+// Vowels are the vowel sounds {word} alternates with Consonants
+var Vowels = []string{
+	"a", "e", "i", "o", "u",
+	"a", "e", "i", "o", "u",
+	"ai", "ea", "ee", "ia", "io", "oa", "oo", "ou",
+}
@@ -1,5 +1,7 @@
 package data
 
+import "time"
+
 // TimeFormats is a lookup map of a few common, defined formatting strings for ease
 // of use.
 var TimeFormats = map[string]string{
@@ -7,4 +9,10 @@ var TimeFormats = map[string]string{
 	"simple": "2006-01-02 15:04:05",
 	// SimpleTimeWithZoneFormat is the same as SimpleTimeFormat, but with the Timezone set
 	"simpletz": "2006-01-02 15:04:05 -0700",
+	// The rest are shortcuts for the layouts of the time package, which are easy to mistype
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	// iso8601 is the profile of ISO 8601 that RFC 3339 describes
+	"iso8601": time.RFC3339,
+	"kitchen": time.Kitchen,
 }
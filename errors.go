@@ -1,5 +1,13 @@
 package moldova
 
+import "fmt"
+
+// Errors returned from Write implement a Temporary method, which reports whether
+// calling Write again could succeed. A temporary error comes from the random values
+// generated on that call, such as a constraint that could not be satisfied, so a fresh
+// roll may work. A permanent error comes from the template itself, and will happen on
+// every call. Errors without a Temporary method should be treated as permanent.
+
 // UnsupportedTokenError is returned from the parser when it encounters an unknown token
 type UnsupportedTokenError string
 
@@ -8,6 +16,11 @@ func (e UnsupportedTokenError) Error() string {
 	return string(e)
 }
 
+// Temporary always returns false, as an unknown token will never be recognized
+func (e UnsupportedTokenError) Temporary() bool {
+	return false
+}
+
 // InvalidArgumentError is returned from the parser when it encounters an invalid argument
 // to a known token
 type InvalidArgumentError string
@@ -16,3 +29,124 @@ type InvalidArgumentError string
 func (e InvalidArgumentError) Error() string {
 	return string(e)
 }
+
+// Temporary always returns false, as an invalid argument will never become valid
+func (e InvalidArgumentError) Temporary() bool {
+	return false
+}
+
+// ParseError is returned from BuildCallstack when a token in the template can't be
+// parsed. It wraps the error saying what's wrong with the token, such as an
+// InvalidArgumentError, which errors.As still finds. A token inside of a nested template,
+// such as the :of of a {repeat}, is wrapped in a ParseError for each token it's inside.
+type ParseError struct {
+	// Token is the token as it's written in the template, without it's braces
+	Token string
+	// Position is the byte offset in the template that the token starts at
+	Position int
+	// Err is the error saying what's wrong with the token
+	Err error
+}
+
+// Error implmenets the error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Template could not be parsed, the token %q at position %d is invalid: %v", e.Token, e.Position, e.Err)
+}
+
+// Unwrap returns the error saying what's wrong with the token
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary always returns false, as the template will never parse
+func (e *ParseError) Temporary() bool {
+	return false
+}
+
+// ExhaustedRetriesError is returned from Write when a token could not generate a value
+// satisfying its constraints within MaxRetries attempts
+type ExhaustedRetriesError string
+
+// Error implmenets the error interface
+func (e ExhaustedRetriesError) Error() string {
+	return string(e)
+}
+
+// Temporary always returns true, as the next attempt will reroll every value and may
+// satisfy the constraints
+func (e ExhaustedRetriesError) Temporary() bool {
+	return true
+}
+
+// LimitExceededError is returned from WriteLimited when the next result would take the
+// output past the limit it was given
+type LimitExceededError string
+
+// Error implmenets the error interface
+func (e LimitExceededError) Error() string {
+	return string(e)
+}
+
+// Temporary always returns false, as the output written so far counts against the limit
+// no matter what is generated next
+func (e LimitExceededError) Temporary() bool {
+	return false
+}
+
+// WriteError is returned from Write when the io.Writer it was given fails partway
+// through a result, meaning the result it received is incomplete
+type WriteError struct {
+	// Segment describes the part of the template that was being written
+	Segment string
+	// Written is how many bytes of the result were written before the failure
+	Written int
+	// Err is the error returned by the io.Writer
+	Err error
+}
+
+// Error implmenets the error interface
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("Result is incomplete, writing %s failed after %d bytes: %v", e.Segment, e.Written, e.Err)
+}
+
+// Unwrap returns the error returned by the io.Writer
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the error returned by the io.Writer was temporary
+func (e *WriteError) Temporary() bool {
+	t, ok := e.Err.(interface {
+		Temporary() bool
+	})
+	return ok && t.Temporary()
+}
+
+// EncodeError is returned from WriteEncoded when the result has a character that the
+// encoding it was given can't represent
+type EncodeError struct {
+	// Segment describes the part of the template the character came from
+	Segment string
+	// Err is the error returned by the encoder
+	Err error
+	// token is set when the character came from a token, rather than from the text
+	// of the template
+	token bool
+}
+
+// Error implmenets the error interface
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("Result could not be encoded, %s has a character the encoding can't represent: %v", e.Segment, e.Err)
+}
+
+// Unwrap returns the error returned by the encoder
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the character came from a token, in which case the next
+// value it generates may be one that can be encoded. A character in the text of the
+// template will fail on every call.
+func (e *EncodeError) Temporary() bool {
+	return e.token
+}
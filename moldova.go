@@ -5,14 +5,29 @@ package moldova
 
 import (
 	"bytes"
+	"container/list"
 	crand "crypto/rand"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"html"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"log"
+	"math"
 	"math/rand"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	uni "unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/unicode/norm"
 
 	// I want to keep files that only exist to help provide sources of data or are
 	// helpers to Moldova in their own subdirectory, for organization reasons. Go
@@ -26,6 +41,12 @@ import (
 type cmdOptions map[string]string
 type objectCache map[string]interface{}
 
+// MaxRetries is the number of times a token with constraints on its output, such as
+// an exclusion list, will reroll a value before giving up. Write returns an
+// ExhaustedRetriesError when this happens, rather than looping forever on a
+// constraint that can't be satisfied.
+var MaxRetries = 100
+
 // TokenWriter is a closure that wraps a call to generate random data, and places
 // the result into the provided buffer
 type tokenWriter func(*bytes.Buffer, objectCache) error
@@ -35,623 +56,7826 @@ type tokenWriter func(*bytes.Buffer, objectCache) error
 // than a stack.
 type Callstack struct {
 	stack []tokenWriter
-	cache objectCache
+	// segments describes the part of the template each function on the stack came
+	// from, for reporting errors
+	segments []string
+	// keys are what Records calls the value of each function on the stack which came
+	// from a token, and are empty for the plain text between them
+	keys []string
+	// numeric is set for each function on the stack whose value is a number, which
+	// WriteNDJSON writes into JSON as such
+	numeric []bool
+	// parts describe where each function on the stack came from, for LintSQL
+	parts []stackPart
+	run   *runState
+	// observer, if set, is told about every value generated
+	observer Observer
+	// children are the callstacks nested inside of this one, such as the body of a
+	// {repeat}
+	children []*Callstack
+	// static is set when the template has no tokens at all, in which case literal is
+	// the entire result and can be written out without invoking anything
+	static  bool
+	literal string
+	// seed, when seeded is set, is what every result draws it's random values from, as
+	// given to BuildCallstackWithSeed
+	seed   int64
+	seeded bool
+	// perToken gives each token on the stack a random stream of it's own, for a seeded
+	// result
+	perToken bool
+}
+
+// Observer is told the type of each token generated during Write, the value it
+// generated, and how long it took to generate. This is useful for profiling which
+// tokens are expensive.
+type Observer func(token string, value string, dur time.Duration)
+
+// SetObserver sets the Observer to be told about every token generated during Write,
+// including those nested inside of tokens such as {repeat}. A nil Observer turns
+// observing off, which is the default, and costs nothing. SetObserver must not be
+// called while a Write is in progress.
+func (c *Callstack) SetObserver(o Observer) {
+	c.observer = o
+	for _, child := range c.children {
+		child.SetObserver(o)
+	}
+}
+
+// Reset clears the state that carries over from one call to Write or WriteN to the
+// next, so that the Callstack can be reused for an independent batch of results as if
+// it had just been parsed. The sequences of tokens such as {semver:sequence:patch} start
+// over from their base, and unique tokens forget every value they have emitted, so they
+// can emit them again. A Callstack from BuildCallstackWithSeed generates the same results
+// again, from the first. Without a Reset, both carry on from where they left off. {snowflake}
+// generators start over too, so an id made after a Reset can repeat one made in the same
+// millisecond before it.
+// Reset is safe to call at any time, including while a Write is in progress, although
+// that Write may then see some of it's tokens reset and others not.
+func (c *Callstack) Reset() {
+	c.run.reset()
+	for _, child := range c.children {
+		child.Reset()
+	}
+}
+
+// runState holds the values that live across calls to Write and WriteN for a single
+// Callstack, keyed by the position in the template of the token that owns them
+type runState struct {
+	sync.Mutex
+	// uniques holds every value emitted by a token with the unique option
+	uniques map[int]map[string]struct{}
+	// counters holds how many values a sequential token has emitted so far
+	counters map[int]int
+	// results is how many results a seeded Callstack has generated so far, each of which
+	// draws from a stream of it's own
+	results uint64
+	// snowflakes is the last id each {snowflake} generator made, which is shared with
+	// every template nested inside of this one, so that none of them repeat an id
+	snowflakes *snowflakeClocks
 }
 
-func newCallstack() *Callstack {
+func newCallstack(snowflakes *snowflakeClocks) *Callstack {
 	return &Callstack{
 		stack: make([]tokenWriter, 0),
+		run: &runState{
+			uniques:    make(map[int]map[string]struct{}),
+			counters:   make(map[int]int),
+			snowflakes: snowflakes,
+		},
+	}
+}
+
+// reset forgets every value held, as if nothing had been emitted yet
+func (rs *runState) reset() {
+	rs.Lock()
+	defer rs.Unlock()
+	rs.uniques = make(map[int]map[string]struct{})
+	rs.counters = make(map[int]int)
+	rs.results = 0
+	rs.snowflakes.reset()
+}
+
+// reserve counts n more results, returning how many there were before them
+func (rs *runState) reserve(n uint64) uint64 {
+	rs.Lock()
+	defer rs.Unlock()
+	first := rs.results
+	rs.results += n
+	return first
+}
+
+// next returns how many values the token at pos has emitted so far, and counts one more
+func (rs *runState) next(pos int) int {
+	rs.Lock()
+	defer rs.Unlock()
+	n := rs.counters[pos]
+	rs.counters[pos] = n + 1
+	return n
+}
+
+// claim records val as emitted by the token at pos, returning false if it already was
+func (rs *runState) claim(pos int, val string) bool {
+	rs.Lock()
+	defer rs.Unlock()
+	seen, ok := rs.uniques[pos]
+	if !ok {
+		seen = make(map[string]struct{})
+		rs.uniques[pos] = seen
 	}
+	if _, ok := seen[val]; ok {
+		return false
+	}
+	seen[val] = struct{}{}
+	return true
 }
 
 // Push will place the given tokenWriter function onto the stack. The first function
 // placed onto the stack will be the first one called when Write is called
 func (c *Callstack) Push(t tokenWriter) {
+	c.push("", "", stackPart{}, t)
+}
+
+// stackPart describes the part of the template a function on the stack came from
+type stackPart struct {
+	// token is the type of token it came from, and is empty for the plain text between
+	// tokens
+	token string
+	// opts are the options of the token, including defaults, with any references as
+	// they were written
+	opts cmdOptions
+	// text is the plain text it writes, if it came from between tokens
+	text string
+	// body is the template nested inside of a {repeat}
+	body *Callstack
+}
+
+// push places the given tokenWriter function onto the stack, along with a description
+// of the part of the template it came from, and the key Records gives it's value
+func (c *Callstack) push(segment string, key string, part stackPart, t tokenWriter) {
+	if c.perToken && key != "" {
+		t = tokenStream(key, t)
+	}
 	c.stack = append(c.stack, t)
+	c.segments = append(c.segments, segment)
+	c.keys = append(c.keys, key)
+	c.numeric = append(c.numeric, false)
+	c.parts = append(c.parts, part)
+}
+
+// Write will fill the given io.Writer with the results of calling each known function
+// on the Callstack. The result is generated in full before anything is written to w,
+// so an error generating it leaves w untouched. If w itself fails partway through, a
+// WriteError is returned naming the part of the template being written at the time.
+// Everything a single result needs is kept to that call, so a Callstack can be written
+// from many goroutines at once.
+func (c *Callstack) Write(w io.Writer) error {
+	_, err := c.writeRecord(w, c.newCache())
+	return err
+}
+
+// WriteEncoded works like Write, but converts the result from UTF-8 into the given
+// encoding before writing it, for consumers which expect something else, such as
+// charmap.ISO8859_1 for Latin-1. If the result has a character the encoding can't
+// represent, an EncodeError is returned naming the part of the template it came from,
+// and w is left untouched. A nil encoding writes UTF-8, exactly as Write does.
+func (c *Callstack) WriteEncoded(w io.Writer, enc encoding.Encoding) error {
+	if enc == nil {
+		return c.Write(w)
+	}
+	b, ends, err := c.appendRecord(nil, c.newCache())
+	if err != nil {
+		return err
+	}
+	encoded, err := enc.NewEncoder().Bytes(b)
+	if err != nil {
+		return c.encodeError(b, ends, enc, err)
+	}
+	if n, err := writeAll(w, encoded); err != nil {
+		return &WriteError{Segment: "the encoded result", Written: n, Err: err}
+	}
+	return nil
+}
+
+// encodeError finds which function on the stack generated the output that enc failed
+// on. The result is encoded as a whole, so that encodings which carry state from one
+// character to the next are handled properly, and only split up once it has failed.
+func (c *Callstack) encodeError(result []byte, ends []int, enc encoding.Encoding, err error) error {
+	start := 0
+	for i, end := range ends {
+		if _, segErr := enc.NewEncoder().Bytes(result[start:end]); segErr != nil && i < len(c.segments) {
+			return &EncodeError{Segment: c.segments[i], Err: segErr, token: c.keys[i] != ""}
+		}
+		start = end
+	}
+	return &EncodeError{Segment: "the result", Err: err}
+}
+
+// AppendTo generates a single result and appends it to dst, returning the extended
+// slice, in the same way as the Append functions of the strconv package. A dst with
+// enough spare capacity is written into directly, so a caller which reuses it's buffer
+// avoids the io.Writer entirely. If generating the result fails, dst is returned as it
+// was given.
+func (c *Callstack) AppendTo(dst []byte) ([]byte, error) {
+	b, _, err := c.appendRecord(dst, c.newCache())
+	return b, err
+}
+
+// appendRecord generates a single result using the given cache and appends it to dst,
+// returning the extended slice along with where the output of each function on the
+// stack ends within the result
+func (c *Callstack) appendRecord(dst []byte, cache objectCache) ([]byte, []int, error) {
+	if c.static {
+		if err := cache.checkLimit(len(c.literal)); err != nil {
+			return dst, nil, err
+		}
+		return append(dst, c.literal...), []int{len(c.literal)}, nil
+	}
+	record := bytes.NewBuffer(dst)
+	ends, err := c.render(record, cache)
+	if err != nil {
+		return dst, nil, err
+	}
+	return record.Bytes(), ends, nil
+}
+
+// writeRecord generates a single result using the given cache and writes it to w in one
+// go, returning how many bytes of it were written. If w fails partway through, where the
+// output of each function ends says which part of the template it failed in.
+func (c *Callstack) writeRecord(w io.Writer, cache objectCache) (int, error) {
+	b, ends, err := c.appendRecord(nil, cache)
+	if err != nil {
+		return 0, err
+	}
+	n, err := writeAll(w, b)
+	if err != nil {
+		i := sort.Search(len(ends), func(i int) bool {
+			return ends[i] > n
+		})
+		if i == len(ends) {
+			i--
+		}
+		return n, &WriteError{Segment: c.segments[i], Written: n, Err: err}
+	}
+	return n, nil
+}
+
+// render generates a single result using the given cache, writing it after anything
+// already in record, and returns where the output of each function on the stack ends
+// within the result
+func (c *Callstack) render(record *bytes.Buffer, cache objectCache) ([]int, error) {
+	start := record.Len()
+	ends := make([]int, len(c.stack))
+	for i, f := range c.stack {
+		if err := f(record, cache); err != nil {
+			return nil, err
+		}
+		if err := cache.checkLimit(record.Len() - start); err != nil {
+			return nil, err
+		}
+		ends[i] = record.Len() - start
+	}
+	return ends, nil
+}
+
+// Records generates n results, returning each one as a map holding the value of every
+// token in it. A token given the as option, such as {guid:as:id}, is keyed by that
+// name, and any other token by it's index in the template, counting every token from 0.
+// Like WriteN, it stops at the first error encountered.
+func (c *Callstack) Records(n int) ([]map[string]string, error) {
+	records := make([]map[string]string, 0, n)
+	for i := 0; i < n; i++ {
+		b, ends, err := c.appendRecord(nil, c.newCache())
+		if err != nil {
+			return records, err
+		}
+		m := make(map[string]string)
+		start := 0
+		for j, end := range ends {
+			if k := c.keys[j]; k != "" {
+				m[k] = string(b[start:end])
+			}
+			start = end
+		}
+		records = append(records, m)
+	}
+	return records, nil
+}
+
+// Token describes a token in a parsed template, along with the options it generates
+// it's values with
+type Token struct {
+	// Name is the type of the token, such as int
+	Name string
+	// Options holds every option of the token, both those given in the template and the
+	// defaults of those that weren't. References, such as @0, are as they were written.
+	Options map[string]string
+}
+
+// Tokens describes each token in the template, in the order they appear, so that tools
+// can see exactly what a template will generate. Tokens nested inside of others, such as
+// those in the body of a {repeat}, are part of the options of the token they're in,
+// written out as String does. The options are copies, and changing them has no effect on
+// the Callstack.
+func (c *Callstack) Tokens() []Token {
+	tokens := make([]Token, 0)
+	for _, p := range c.parts {
+		if p.token != "" {
+			tokens = append(tokens, Token{Name: p.token, Options: p.options()})
+		}
+	}
+	return tokens
+}
+
+// options returns a copy of the options of the token a part came from, with the body of
+// a {repeat} written out in the normal form of String
+func (p stackPart) options() map[string]string {
+	opts := make(map[string]string, len(p.opts))
+	for k, v := range p.opts {
+		opts[k] = v
+	}
+	if p.body != nil {
+		opts["of"] = p.body.String()
+	}
+	return opts
+}
+
+// String writes the template back out with every option of every token given
+// explicitly, defaults included, in a normal form where the options are sorted by name
+// and those without a value are left out. Parsing the result gives a Callstack which
+// generates values in the same way, even without the Options that were given to
+// BuildCallstack. Comments are dropped.
+func (c *Callstack) String() string {
+	if c.static {
+		return c.literal
+	}
+	b := &strings.Builder{}
+	for _, p := range c.parts {
+		if p.token == "" {
+			b.WriteString(p.text)
+			continue
+		}
+		opts := make([]string, 0, len(p.opts))
+		raw := ""
+		for k, v := range p.options() {
+			if v == "" {
+				continue
+			}
+			// An option which takes the rest of the options verbatim has to come last
+			if k == rawOptions[p.token] {
+				raw = k + ":" + v
+				continue
+			}
+			opts = append(opts, k+":"+v)
+		}
+		sort.Strings(opts)
+		if raw != "" {
+			opts = append(opts, raw)
+		}
+		b.WriteString("{" + p.token)
+		if len(opts) > 0 {
+			b.WriteString(":" + strings.Join(opts, "|"))
+		}
+		b.WriteString("}")
+	}
+	return b.String()
 }
 
-// Write will take a bytes.Buffer pointer and fill it with the results of calling
-// each known function on the Callstack.
-func (c *Callstack) Write(result *bytes.Buffer) error {
-	c.cache = newObjectCache()
+func (c *Callstack) write(result *bytes.Buffer, cache objectCache) error {
 	for _, f := range c.stack {
-		if err := f(result, c.cache); err != nil {
+		if err := f(result, cache); err != nil {
+			return err
+		}
+		if err := cache.checkLimit(result.Len()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Returns option value as integer
-func (cmd cmdOptions) getInt(n string) (int, error) {
-	v := cmd[n]
-	return strconv.Atoi(v)
+// WriteOption configures how WriteN lays out the results it writes
+type WriteOption func(*writeConfig)
+
+// writeConfig holds everything the WriteOptions given to WriteN have configured
+type writeConfig struct {
+	prefix, suffix string
+	// sep goes between each result, and is only used when separated is set. Otherwise
+	// a newline goes after each result, including the last.
+	sep       string
+	separated bool
 }
 
-// Returns option value as float64
-func (cmd cmdOptions) getFloat(n string) (float64, error) {
-	v := cmd[n]
-	return strconv.ParseFloat(v, 64)
+// WithPrefix makes WriteN write s once, before the first result, such as the column list
+// of an SQL INSERT, or the header of a CSV file. It's written as is, even when n is 0.
+func WithPrefix(s string) WriteOption {
+	return func(cfg *writeConfig) {
+		cfg.prefix = s
+	}
 }
 
-var defaultOptions = map[string]cmdOptions{
-	"guid":      cmdOptions{"ordinal": "-1"},
-	"now":       cmdOptions{"ordinal": "-1", "format": "simple", "zone": "UTC"},
-	"time":      cmdOptions{"ordinal": "-1", "format": "simple", "min": "0", "max": "1455512165", "zone": "UTC"},
-	"int":       cmdOptions{"min": "0", "max": "100", "ordinal": "-1"},
-	"float":     cmdOptions{"min": "0.0", "max": "100.0", "ordinal": "-1"},
-	"ascii":     cmdOptions{"length": "2", "case": "down", "ordinal": "-1"},
-	"unicode":   cmdOptions{"length": "2", "case": "down", "ordinal": "-1"},
-	"country":   cmdOptions{"ordinal": "-1", "case": "up"},
-	"firstname": cmdOptions{"ordinal": "-1", "language": English},
-	"lastname":  cmdOptions{"ordinal": "-1", "language": English},
-}
-
-func newObjectCache() objectCache {
-	return objectCache{
-		"guid":      make([]string, 0),
-		"now":       make([]string, 0),
-		"time":      make([]string, 0),
-		"country":   make([]string, 0),
-		"unicode":   make([]string, 0),
-		"ascii":     make([]string, 0),
-		"int":       make([]int, 0),
-		"float":     make([]float64, 0),
-		"firstname": make([]string, 0),
-		"lastname":  make([]string, 0),
+// WithSeparator makes WriteN write s between each result, rather than a newline after
+// each, so there's nothing after the last result but any suffix.
+func WithSeparator(s string) WriteOption {
+	return func(cfg *writeConfig) {
+		cfg.sep = s
+		cfg.separated = true
 	}
 }
 
-// BuildCallstack will parse the template, and return a callstack of closures to
-// invoke in order, which will produce static/random values that can be turned into
-// a string
-func BuildCallstack(inputTemplate string) (*Callstack, error) {
-	stack := newCallstack()
-	wordBuffer := &bytes.Buffer{}
-	foundWord := false
-	wordStart := 0
-	for i, c := range inputTemplate {
-		if !foundWord && c == '{' {
-			// We're starting a word to parse
-			foundWord = true
-			// Track the position of where the word started, for potential error reporting
-			wordStart = i
-			// Dump the current buffer into a closure
-			// Assigning to 'cb', ClosureBuster, will get around this issue
-			// THANKS .NET PRIOR TO 4.0 FOR TEACHING ME ABOUT ACCESS TO A MODIFIED CLOSURE!
-			cb := wordBuffer.String()
-			wordBuffer.Reset()
-			f := func(result *bytes.Buffer, cache objectCache) error {
-				result.WriteString(cb)
-				return nil
-			}
-			stack.Push(f)
-		} else if foundWord && c == '}' {
-			// We're closing a word, so eval it and get the data to put in the string
-			foundWord = false
-			// TODO I dislike this part of the grammer - i think the arguments list
-			// should begin with the |, or at least it's own demarcation, to avoid the
-			// ugly and dual-purpose : construct. I'm open to even changing the grammar
-			// overall, but would need to be a hard version change.
-			parts := strings.SplitN(wordBuffer.String(), ":", 2)
-			rawOpts := ""
-			if len(parts) > 1 {
-				rawOpts = parts[1]
+// WithSuffix makes WriteN write s once, after the last result, such as the ; which ends
+// an SQL statement. It's written as is, even when n is 0.
+func WithSuffix(s string) WriteOption {
+	return func(cfg *writeConfig) {
+		cfg.suffix = s
+	}
+}
+
+// WriteN will call Write n times, placing a newline after each result. It stops at
+// the first error encountered. Any WriteOptions given change what's written around the
+// results, so that a statement such as an SQL INSERT can be written ready to run:
+//
+//	cs.WriteN(w, 100, moldova.WithPrefix("INSERT INTO users (id, name) VALUES\n"),
+//		moldova.WithSeparator(",\n"), moldova.WithSuffix(";\n"))
+//
+// Unique values last for the lifetime of the Callstack, rather than for one call to
+// WriteN, so a unique token never repeats a value it emitted in an earlier batch until
+// Reset is called.
+func (c *Callstack) WriteN(w io.Writer, n int, options ...WriteOption) error {
+	cfg := &writeConfig{}
+	for _, o := range options {
+		o(cfg)
+	}
+	if _, err := writeAll(w, []byte(cfg.prefix)); err != nil {
+		return &WriteError{Segment: "the prefix", Err: err}
+	}
+	for i := 0; i < n; i++ {
+		if cfg.separated && i > 0 {
+			if _, err := writeAll(w, []byte(cfg.sep)); err != nil {
+				return &WriteError{Segment: "the separator before the result", Err: err}
 			}
-			opts, err := optionsToMap(parts[0], rawOpts)
-			if err != nil {
-				return nil, err
+		}
+		if err := c.Write(w); err != nil {
+			return err
+		}
+		if !cfg.separated {
+			if _, err := writeAll(w, []byte{'\n'}); err != nil {
+				return &WriteError{Segment: "the newline after the result", Err: err}
 			}
-			// Build the closure that will invoke resolveWord
-			f := func(result *bytes.Buffer, cache objectCache) error {
-				val := ""
-				if val, err = resolveWord(cache, parts[0], wordStart, opts); err != nil {
-					return err
-				}
-				result.WriteString(val)
-				return nil
+		}
+	}
+	if _, err := writeAll(w, []byte(cfg.suffix)); err != nil {
+		return &WriteError{Segment: "the suffix", Err: err}
+	}
+	return nil
+}
+
+// WriteSQLValues generates n results of a template for a single row of an SQL INSERT,
+// such as ({guid}, '{firstname}'), and writes them to w separated by a comma and a
+// newline, so that they can follow the VALUES of one statement rather than each needing
+// a statement of it's own. Ordinals refer to tokens within the same row, just as they
+// do within a single result of WriteN. As a statement needs at least one row, an n of
+// less than 1 is an InvalidArgumentError. Like WriteN, it stops at the first error.
+func (c *Callstack) WriteSQLValues(w io.Writer, n int) error {
+	if n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("WriteSQLValues needs at least 1 row, but was asked for %d", n))
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if _, err := writeAll(w, []byte(",\n")); err != nil {
+				return &WriteError{Segment: "the comma before the row", Err: err}
 			}
-			stack.Push(f)
-			wordBuffer.Reset()
-		} else {
-			// Straight pass through
-			wordBuffer.WriteRune(c)
+		}
+		if err := c.Write(w); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// If there is anything remaining in word buffer, add the final call to the stack
-	s := wordBuffer.String()
-	f := func(result *bytes.Buffer, cache objectCache) error {
-		result.WriteString(s)
+// WriteNParallel generates n results across the given number of goroutines, writing
+// each to w followed by a newline, in the order they were generated. It's for writing
+// many millions of results, where WriteN spends most of it's time generating them one
+// at a time. Each goroutine draws from a random stream of it's own, rather than sharing
+// math/rand, or for a Callstack from BuildCallstackWithSeed each result does, in which
+// case the output is the same as WriteN's. The results of unique tokens and sequences,
+// such as {cycle}, are shared by the goroutines, so these are only in order within the
+// results which are written in order. Like WriteN, it stops at the first error, having
+// written every result before the one which failed. An Observer set on the Callstack is
+// called from all of the goroutines.
+func (c *Callstack) WriteNParallel(w io.Writer, n int, workers int) error {
+	return c.writeNParallel(w, n, workers, true)
+}
+
+// WriteNParallelUnordered works like WriteNParallel, but writes each result as soon as
+// it's generated, rather than hold it until every result before it has been written.
+// This keeps the goroutines busier when some results take much longer to generate than
+// others, such as those with a large {repeat}.
+func (c *Callstack) WriteNParallelUnordered(w io.Writer, n int, workers int) error {
+	return c.writeNParallel(w, n, workers, false)
+}
+
+// parallelResult is a result generated by one of the goroutines of WriteNParallel
+type parallelResult struct {
+	index int
+	b     []byte
+	err   error
+}
+
+func (c *Callstack) writeNParallel(w io.Writer, n int, workers int, ordered bool) error {
+	if workers < 1 {
+		return InvalidArgumentError(fmt.Sprintf("WriteNParallel needs at least 1 worker, but was given %d", workers))
+	}
+	if n <= 0 {
+		return nil
+	}
+	var first uint64
+	if c.seeded {
+		first = c.run.reserve(uint64(n))
+	}
+	done := make(chan struct{})
+	defer close(done)
+	// Only so many results may be generated ahead of those being written, so that one
+	// slow result doesn't leave all of the others piling up in memory behind it
+	window := make(chan struct{}, workers*4)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+	results := make(chan parallelResult, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(&splitMix64{state: rand.Uint64()})
+			for i := range jobs {
+				b, _, err := c.appendRecord(nil, c.resultCache(first+uint64(i), r))
+				select {
+				case results <- parallelResult{index: i, b: append(b, '\n'), err: err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	write := func(res parallelResult) error {
+		if res.err != nil {
+			return res.err
+		}
+		if written, err := writeAll(w, res.b); err != nil {
+			return &WriteError{Segment: fmt.Sprintf("result %d", res.index), Written: written, Err: err}
+		}
+		<-window
 		return nil
 	}
-	stack.Push(f)
+	pending := make(map[int]parallelResult)
+	next := 0
+	for res := range results {
+		if !ordered {
+			if err := write(res); err != nil {
+				return err
+			}
+			continue
+		}
+		pending[res.index] = res
+		for res, ok := pending[next]; ok; res, ok = pending[next] {
+			delete(pending, next)
+			if err := write(res); err != nil {
+				return err
+			}
+			next++
+		}
+	}
+	return nil
+}
 
-	return stack, nil
+// WriteNDJSON generates n results, writing each one to w as a JSON object on a line of
+// it's own, which is the newline delimited JSON that many bulk loaders ingest. Each
+// object holds the value of every token in the result, keyed as Records keys them, in
+// the order they appear in the template. Values from {int} and {float} are written as
+// JSON numbers, and all others as strings. Like WriteN, it stops at the first error
+// encountered.
+func (c *Callstack) WriteNDJSON(w io.Writer, n int) error {
+	line := make([]byte, 0, 256)
+	for i := 0; i < n; i++ {
+		b, ends, err := c.appendRecord(nil, c.newCache())
+		if err != nil {
+			return err
+		}
+		line = c.appendJSONObject(line[:0], b, ends)
+		line = append(line, '\n')
+		if written, err := writeAll(w, line); err != nil {
+			return &WriteError{Segment: "the JSON object of the result", Written: written, Err: err}
+		}
+	}
+	return nil
 }
 
-// This function was borrowed with permission from the following location
-// https://github.com/dgryski/trifles/blob/master/uuid/uuid.go
-// All credit / lawsuits can be forwarded to Damian Gryski and Russ Cox
-func uuidv4() string {
-	b := make([]byte, 16)
-	_, err := io.ReadFull(crand.Reader, b)
-	if err != nil {
-		// probably "shouldn't happen"
-		log.Fatal(err)
+// appendJSONObject appends the values of the tokens in a result to dst as a JSON object,
+// given where the output of each function on the stack ends within the result
+func (c *Callstack) appendJSONObject(dst []byte, result []byte, ends []int) []byte {
+	dst = append(dst, '{')
+	start := 0
+	first := true
+	for i, end := range ends {
+		value := result[start:end]
+		start = end
+		k := c.keys[i]
+		if k == "" {
+			continue
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		// Marshalling a string can't fail
+		key, _ := json.Marshal(k)
+		dst = append(append(dst, key...), ':')
+		if c.numeric[i] {
+			dst = append(dst, value...)
+		} else {
+			v, _ := json.Marshal(string(value))
+			dst = append(dst, v...)
+		}
 	}
-	b[6] = (b[6] & 0x0F) | 0x40
-	b[8] = (b[8] &^ 0x40) | 0x80
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+	return append(dst, '}')
 }
 
-func optionsToMap(name string, options string) (map[string]string, error) {
-	m := make(map[string]string)
-	defaults := defaultOptions[name]
-	for k, v := range defaults {
-		m[k] = v
+// WriteLimited will call Write n times, placing a newline after each result, just as
+// WriteN does, but stops with a LimitExceededError rather than write more than maxBytes
+// in total. The limit is checked as each result is generated, so a template such as
+// {repeat:count:999999999|of:{guid}} fails early instead of using up memory, and tokens
+// such as {ascii:length:200000000} fail before generating anything. Each time around a
+// {repeat} or {jsonarray} counts against the limit too, as if it wrote a byte, so one
+// whose body writes nothing can't run for ever. A result which would take the output
+// past the limit is not written at all.
+func (c *Callstack) WriteLimited(w io.Writer, n int, maxBytes int) error {
+	limit := &outputLimit{max: maxBytes, remaining: maxBytes, steps: maxBytes}
+	for i := 0; i < n; i++ {
+		cache := c.newCache()
+		// Leave room for the newline
+		limit.remaining--
+		cache["limit"] = limit
+		written, err := c.writeRecord(w, cache)
+		if err != nil {
+			return err
+		}
+		if _, err := writeAll(w, []byte{'\n'}); err != nil {
+			return &WriteError{Segment: "the newline after the result", Err: err}
+		}
+		limit.remaining -= written
 	}
-	// If there were no options specified, just use defaults
-	if len(options) == 0 {
-		return m, nil
+	return nil
+}
+
+// outputLimit is how many more bytes WriteLimited may write, out of the max it was given,
+// along with how many more times it may go around a {repeat} or {jsonarray}
+type outputLimit struct {
+	max       int
+	remaining int
+	steps     int
+}
+
+// checkLimit returns a LimitExceededError if a result of size bytes would go past the
+// limit set by WriteLimited, if there is one
+func (oc objectCache) checkLimit(size int) error {
+	limit, ok := oc["limit"].(*outputLimit)
+	if ok && size > limit.remaining {
+		return LimitExceededError(fmt.Sprintf("Writing this result would exceed the limit of %d bytes", limit.max))
 	}
-	parts := strings.Split(options, "|")
+	return nil
+}
 
-	for _, p := range parts {
-		// Some options, like format, can have : in them. Only split the first :, which
-		// should have the arg name, ad a value with an arbitrary number of : inside of it
-		opt := strings.SplitN(p, ":", 2)
-		m[opt[0]] = opt[1]
+// takeSteps returns a LimitExceededError if going around a {repeat} or {jsonarray} n
+// more times would go past the limit set by WriteLimited, if there is one. A body which
+// writes nothing never adds to the size of the result, so it's iterations are what stop
+// it instead
+func (oc objectCache) takeSteps(n int) error {
+	limit, ok := oc["limit"].(*outputLimit)
+	if !ok {
+		return nil
 	}
-	return m, nil
+	if n > limit.steps {
+		return LimitExceededError(fmt.Sprintf("Writing this result would repeat more times than the limit of %d bytes allows", limit.max))
+	}
+	limit.steps -= n
+	return nil
 }
 
-func resolveWord(oc objectCache, word string, pos int, opts cmdOptions) (string, error) {
-	// If there were options provided, convert them to a lookup map prior to invoking
-	// a randomizer.
-	switch word {
-	case "guid":
-		return guid(oc, opts)
-	case "int":
-		return integer(oc, opts)
-	case "now":
-		return now(oc, opts)
-	case "time":
-		return datetime(oc, opts)
-	case "float":
-		return float(oc, opts)
-	case "unicode":
-		return unicode(oc, opts)
-	case "ascii":
-		return ascii(oc, opts)
-	case "country":
-		return country(oc, opts)
-	case "firstname":
-		return firstname(oc, opts)
-	case "lastname":
-		return lastname(oc, opts)
+// mulCapped multiplies a and b, neither of which is negative, giving math.MaxInt rather
+// than overflowing
+func mulCapped(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
 	}
-	return "", UnsupportedTokenError(fmt.Sprintf("the token %s at position %d is not recognized, check for typos", word, pos))
+	return a * b
 }
 
-// TODO All the below functions need way better commenting and parameter annotations
+// scope makes an empty cache for a template nested inside of another, such as the body
+// of a {repeat}, which keeps the clock and any limit of the cache it's nested in
+func (oc objectCache) scope() objectCache {
+	s := newObjectCache(oc["clock"].(time.Time))
+	if limit, ok := oc["limit"]; ok {
+		s["limit"] = limit
+	}
+	// Time ordered ids are ordered across the whole result, not just the scope
+	s["guidorder"] = oc["guidorder"]
+	s["ulidorder"] = oc["ulidorder"]
+	// As is the random stream, so that a seeded result is the same every time
+	if r, ok := oc["rand"]; ok {
+		s["rand"] = r
+	}
+	if seeded, ok := oc["seeded"]; ok {
+		s["seeded"] = seeded
+	}
+	// With a stream per token, those in the scope derive theirs from the stream of the
+	// token the scope is nested inside of, so that each time through a {repeat} differs
+	if _, ok := oc["streamseed"]; ok {
+		s["streamseed"] = oc.rng().Uint64()
+	}
+	return s
+}
+
+// tokenStream wraps the function for a token, so that in a seeded result it draws from a
+// random stream of it's own, derived from the stream seed of the scope it's in and the
+// key Records gives it's value
+func tokenStream(key string, t tokenWriter) tokenWriter {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	k := h.Sum64()
+	return func(result *bytes.Buffer, cache objectCache) error {
+		seed, ok := cache["streamseed"].(uint64)
+		if !ok {
+			return t(result, cache)
+		}
+		prev, had := cache["rand"]
+		cache["rand"] = rand.New(&splitMix64{state: mix64(seed ^ k)})
+		err := t(result, cache)
+		if had {
+			cache["rand"] = prev
+		} else {
+			delete(cache, "rand")
+		}
+		return err
+	}
+}
+
+// newCache makes the cache for a single result of the Callstack
+func (c *Callstack) newCache() objectCache {
+	var result uint64
+	if c.seeded {
+		result = c.run.reserve(1)
+	}
+	return c.resultCache(result, nil)
+}
+
+// resultCache makes the cache for the given result of the Callstack, counting from the
+// first generated since it was parsed or Reset. A seeded Callstack gives each result a
+// random stream of it's own, derived from the seed and the result, so that it's the
+// same no matter which goroutine generates it or when. Otherwise, the result draws from
+// r, or from the top level functions of math/rand if r is nil.
+func (c *Callstack) resultCache(result uint64, r *rand.Rand) objectCache {
+	cache := newObjectCache(time.Now())
+	if c.seeded {
+		stream := mix64(uint64(c.seed) ^ mix64(result))
+		cache["rand"] = rand.New(&splitMix64{state: stream})
+		cache["seeded"] = true
+		if c.perToken {
+			cache["streamseed"] = stream
+		}
+	} else if r != nil {
+		cache["rand"] = r
+	}
+	return cache
+}
+
+// rng returns what the tokens of a result draw their random values from
+func (oc objectCache) rng() *rand.Rand {
+	if r, ok := oc["rand"].(*rand.Rand); ok {
+		return r
+	}
+	return sharedRand
+}
+
+// entropy returns what {guid} reads the random bytes of a version 4 uuid from, which
+// is crypto/rand unless the result is seeded
+func (oc objectCache) entropy() io.Reader {
+	if _, ok := oc["seeded"]; ok {
+		return oc.rng()
+	}
+	return crand.Reader
+}
+
+// sharedRand draws from the top level functions of math/rand, so that rand.Seed still
+// decides the results of a Callstack which isn't seeded. It's safe to use from many
+// goroutines at once, except for it's Read method, which randomBytes avoids.
+var sharedRand = rand.New(globalSource{})
+
+// globalSource is a rand.Source backed by the top level functions of math/rand
+type globalSource struct{}
+
+func (globalSource) Int63() int64    { return rand.Int63() }
+func (globalSource) Uint64() uint64  { return rand.Uint64() }
+func (globalSource) Seed(seed int64) {}
+
+// splitMix64 is a small and fast rand.Source, which is cheap enough to seed afresh for
+// every result. See http://prng.di.unimi.it/splitmix64.c
+type splitMix64 struct {
+	state uint64
+}
+
+func (s *splitMix64) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix64(s.state)
+}
+
+func (s *splitMix64) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix64) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// mix64 scrambles the bits of z, so that nearby values end up far apart
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// writeAll writes all of b to w, carrying on past any short writes until either all
+// of it has been written or w returns an error. It returns how many bytes were written.
+func writeAll(w io.Writer, b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := w.Write(b[written:])
+		if n > 0 {
+			written += n
+		}
+		if err != nil {
+			return written, err
+		}
+		if n <= 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+	return written, nil
+}
+
+// resolveUnique resolves the word just as resolveWord does, but rerolls any value
+// that has already been emitted by the token at pos on this Callstack. Ordinal
+// references are passed through, since they never produce a new value.
+func (c *Callstack) resolveUnique(oc objectCache, word string, pos int, opts cmdOptions, prep interface{}) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+	if ord >= 0 {
+		return resolveWord(oc, c.run, word, pos, opts, prep)
+	}
+
+	val := ""
+	rerr := reroll("a unique "+word, func() bool {
+		if val, err = resolveWord(oc, c.run, word, pos, opts, prep); err != nil {
+			// Not a constraint failure, so stop trying and report it below
+			return true
+		}
+		// It's the value as it's written out which must be unique
+		if !c.run.claim(pos, cleanWhitespace(val, opts)) {
+			// Pull the duplicate back out of the cache so ordinals don't see it
+			oc.discardLast(word)
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if rerr != nil {
+		return "", rerr
+	}
+	return val, nil
+}
+
+// whitespaceOptions are the common options which clean up the whitespace of a token's
+// value as it's written out
+var whitespaceOptions = []string{"trim", "collapsews"}
+
+// cleanWhitespace collapses each run of whitespace in s down to a single space, and
+// trims it from either end, as the trim and collapsews options ask for
+func cleanWhitespace(s string, opts cmdOptions) string {
+	if opts["collapsews"] == "true" {
+		var b strings.Builder
+		space := false
+		for _, r := range s {
+			if uni.IsSpace(r) {
+				if !space {
+					b.WriteByte(' ')
+				}
+				space = true
+				continue
+			}
+			space = false
+			b.WriteRune(r)
+		}
+		s = b.String()
+	}
+	if opts["trim"] == "true" {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+// cleanWhitespaceOf wraps the function for a token which writes out a template of it's
+// own, such as a {repeat}, so that the whitespace of the whole of what it writes is
+// cleaned up, along with the value it cached for word
+func cleanWhitespaceOf(t tokenWriter, word string, opts cmdOptions) tokenWriter {
+	if opts["collapsews"] != "true" && opts["trim"] != "true" {
+		return t
+	}
+	return func(result *bytes.Buffer, cache objectCache) error {
+		value := &bytes.Buffer{}
+		if err := t(value, cache); err != nil {
+			return err
+		}
+		result.WriteString(cleanWhitespace(value.String(), opts))
+		cache.cleanLast(word, opts)
+		return nil
+	}
+}
+
+// observed wraps the function for a token which writes out a template of it's own, such
+// as a {repeat}, so that the Observer is told about the token as a whole, once it's
+// written, as well as about each of the tokens inside of it
+func (c *Callstack) observed(word string, t tokenWriter) tokenWriter {
+	return func(result *bytes.Buffer, cache objectCache) error {
+		if c.observer == nil {
+			return t(result, cache)
+		}
+		start := time.Now()
+		n := result.Len()
+		if err := t(result, cache); err != nil {
+			return err
+		}
+		c.observer(word, result.String()[n:], time.Since(start))
+		return nil
+	}
+}
+
+// Returns option value as integer
+func (cmd cmdOptions) getInt(n string) (int, error) {
+	v := cmd[n]
+	return strconv.Atoi(v)
+}
+
+// Returns option value as a list of integers, split on commas
+func (cmd cmdOptions) getIntList(n string) ([]int, error) {
+	v := cmd[n]
+	if v == "" {
+		return nil, nil
+	}
+	parts := strings.Split(v, ",")
+	list := make([]int, len(parts))
+	for i, p := range parts {
+		num, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		list[i] = num
+	}
+	return list, nil
+}
+
+// Returns option value as an inclusive range of counts, given either as a single
+// integer, or as min-max
+func (cmd cmdOptions) getCount(n string) (int, int, error) {
+	v := cmd[n]
+	parts := strings.SplitN(v, "-", 2)
+	min, err := strconv.Atoi(parts[0])
+	max := min
+	if err == nil && len(parts) == 2 {
+		max, err = strconv.Atoi(parts[1])
+	}
+	if err != nil || min < 0 || max < min {
+		return 0, 0, InvalidArgumentError(fmt.Sprintf("%s: %s is not an integer >= 0, or a range of them such as 1-5", n, v))
+	}
+	return min, max, nil
+}
+
+// Returns option value as a set of runes, given as a comma separated list of characters
+// or escaped code points, such as \u0000,\u200b
+func (cmd cmdOptions) getRuneSet(n string) (map[rune]bool, error) {
+	v := cmd[n]
+	set := make(map[rune]bool)
+	if v == "" {
+		return set, nil
+	}
+	for _, p := range strings.Split(v, ",") {
+		r, _, tail, err := strconv.UnquoteChar(p, '\'')
+		if err != nil || tail != "" {
+			return nil, InvalidArgumentError(fmt.Sprintf("%s: %s is not a character or an escaped code point such as \\u200b", n, p))
+		}
+		set[r] = true
+	}
+	return set, nil
+}
+
+// Returns option value as float64
+func (cmd cmdOptions) getFloat(n string) (float64, error) {
+	v := cmd[n]
+	return strconv.ParseFloat(v, 64)
+}
+
+// Returns option value as a list of float64, split on commas
+func (cmd cmdOptions) getFloatList(n string) ([]float64, error) {
+	v := cmd[n]
+	if v == "" {
+		return nil, nil
+	}
+	parts := strings.Split(v, ",")
+	list := make([]float64, len(parts))
+	for i, p := range parts {
+		num, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = num
+	}
+	return list, nil
+}
+
+var defaultOptions = map[string]cmdOptions{
+	"guid":         cmdOptions{"ordinal": "-1", "version": "4", "time": ""},
+	"now":          cmdOptions{"ordinal": "-1"},
+	"time":         cmdOptions{"ordinal": "-1", "min": "0", "max": "1455512165", "after": "", "skip": "", "holidays": "", "sorted": ""},
+	"int":          cmdOptions{"min": "0", "max": "100", "ordinal": "-1", "exclude": "", "type": "", "edge": "false", "maxInclusive": "false", "groupsep": "", "snap": ""},
+	"float":        cmdOptions{"min": "0.0", "max": "100.0", "ordinal": "-1", "format": "decimal", "precision": "6", "sign": "any", "maxInclusive": "false", "decimalsep": ".", "groupsep": "", "round": "", "dist": "uniform", "rate": "1", "snap": ""},
+	"ascii":        cmdOptions{"length": "2", "ordinal": "-1", "require": ""},
+	"unicode":      cmdOptions{"length": "2", "ordinal": "-1", "exclude": "", "require": ""},
+	"country":      cmdOptions{"ordinal": "-1", "weight": "uniform", "exclude": ""},
+	"address":      cmdOptions{"ordinal": "-1"},
+	"state":        cmdOptions{"ordinal": "-1", "format": "code"},
+	"zip":          cmdOptions{"ordinal": "-1", "country": "US", "state": "", "plus4": "false"},
+	"ssn":          cmdOptions{"ordinal": "-1"},
+	"regex":        cmdOptions{"ordinal": "-1", "pattern": ""},
+	"string":       cmdOptions{"ordinal": "-1", "match": "", "invalid": "0"},
+	"palette":      cmdOptions{"ordinal": "-1", "count": "5", "scheme": "categorical", "overflow": "error"},
+	"blob":         cmdOptions{"ordinal": "-1", "length": "16"},
+	"lorem":        cmdOptions{"ordinal": "-1", "words": "5", "list": "latin", "paragraphs": "1", "markup": "none", "model": "simple"},
+	"ulid":         cmdOptions{"ordinal": "-1", "time": ""},
+	"snowflake":    cmdOptions{"ordinal": "-1", "epoch": "2010-11-04T01:42:54.657Z", "machine": "0"},
+	"phone":        cmdOptions{"ordinal": "-1", "country": "US", "ext": "false"},
+	"capital":      cmdOptions{"ordinal": "-1", "of": ""},
+	"subdivision":  cmdOptions{"ordinal": "-1", "of": ""},
+	"numberwords":  cmdOptions{"ordinal": "-1", "min": "0", "max": "1000", "of": "", "lang": "en"},
+	"phonetic":     cmdOptions{"ordinal": "-1", "length": "3", "of": ""},
+	"emoji":        cmdOptions{"ordinal": "-1", "category": "", "count": "1"},
+	"jsonvalue":    cmdOptions{"ordinal": "-1", "maxdepth": "2"},
+	"choice":       cmdOptions{"ordinal": "-1", "of": "", "file": ""},
+	"bool":         cmdOptions{"ordinal": "-1", "chance": "0.5", "not": ""},
+	"cycle":        cmdOptions{"ordinal": "-1", "of": ""},
+	"bytes":        cmdOptions{"ordinal": "-1", "min": "0", "max": "1073741824"},
+	"word":         cmdOptions{"ordinal": "-1", "style": "pronounceable", "length": "6"},
+	"jsonpath":     cmdOptions{"ordinal": "-1", "depth": "3"},
+	"traceparent":  cmdOptions{"ordinal": "-1", "sampled": "true"},
+	"firstname":    cmdOptions{"ordinal": "-1", "language": English, "locale": "", "fallback": "default"},
+	"lastname":     cmdOptions{"ordinal": "-1", "language": English, "locale": "", "fallback": "default"},
+	"geo":          cmdOptions{"ordinal": "-1", "bbox": "-90,-180,90,180"},
+	"semver":       cmdOptions{"ordinal": "-1", "sequence": "", "base": "1.0.0"},
+	"barcode":      cmdOptions{"ordinal": "-1", "type": "ean13"},
+	"isbn":         cmdOptions{"ordinal": "-1", "version": "13", "hyphenated": "false"},
+	"repeat":       cmdOptions{"count": "1", "of": "", "sep": ""},
+	"jsonarray":    cmdOptions{"count": "1", "of": ""},
+	"mask":         cmdOptions{"of": "", "show": "last4", "char": "*"},
+	"mimetype":     cmdOptions{"ordinal": "-1", "category": ""},
+	"currencycode": cmdOptions{"ordinal": "-1"},
+	"money":        cmdOptions{"ordinal": "-1", "min": "0.0", "max": "1000.0", "currency": "USD"},
+	"cents":        cmdOptions{"ordinal": "-1", "min": "0.0", "max": "1000.0", "currency": "USD"},
+	"pool":         cmdOptions{"ordinal": "-1", "name": ""},
+	"email":        cmdOptions{"ordinal": "-1", "from": "", "domain": "example.com"},
+}
+
+// extraOptions are the options a token takes which have no default, on top of those in
+// defaultOptions, such as a format which an ordinal only applies when asked to
+var extraOptions = map[string][]string{
+	"guid":        {"format", "dashes", "case", "prefix"},
+	"now":         {"format", "zone"},
+	"time":        {"format", "zone"},
+	"int":         {"type", "preset"},
+	"country":     {"case", "format"},
+	"unicode":     {"case", "normalize"},
+	"ascii":       {"case"},
+	"firstname":   {"case"},
+	"lastname":    {"case"},
+	"address":     {"format"},
+	"ssn":         {"format"},
+	"blob":        {"format"},
+	"phone":       {"format"},
+	"bool":        {"true", "false"},
+	"subdivision": {"format"},
+	"choice":      {"weights", "other"},
+	"bytes":       {"base", "precision"},
+	"lorem":       {"sentences"},
+	"word":        {"case"},
+}
+
+// commonOptions are the options that every token takes
+var commonOptions = []string{"unique", "as", "trim", "collapsews"}
+
+// TokenSpec describes a token that templates can use, and the options it takes, for
+// tools which guide users through writing templates, such as autocompletion
+type TokenSpec struct {
+	// Name is the name of the token, as it's written in a template
+	Name string
+	// Options are the options the token takes, sorted by name
+	Options []OptionSpec
+}
+
+// OptionSpec describes a single option a token takes
+type OptionSpec struct {
+	// Name is the name of the option, as it's written in a template
+	Name string
+	// Type is the kind of value the option takes, which is one of "int", "float",
+	// "bool", "string", "time", "list" for a comma separated list of values, "ref"
+	// for an option which can refer to an earlier token such as @0, or "template"
+	// for a template of it's own, such as the :of of a {repeat}
+	Type string
+	// Default is the value used when the option isn't given, and is empty if it has
+	// none
+	Default string
+}
+
+// optionTypes are the types of each option, keyed by the name of the option, or by the
+// token and option such as "float.min" when it differs from one token to another. An
+// option listed in tokenRefs is a "ref", so it isn't listed here.
+var optionTypes = map[string]string{
+	"ordinal": "int", "unique": "bool", "as": "string",
+	"min": "int", "max": "int", "float.min": "float", "float.max": "float",
+	"money.min": "float", "money.max": "float", "maxInclusive": "bool",
+	"cents.min": "float", "cents.max": "float",
+	"count": "int", "maxdepth": "int", "length": "int", "words": "int", "paragraphs": "int", "sentences": "int",
+	"precision": "int", "machine": "int", "version": "int", "bytes.base": "int",
+	"chance": "float", "invalid": "float", "rate": "float", "snap": "float", "int.snap": "int",
+	"trim": "bool", "collapsews": "bool", "edge": "bool", "ext": "bool", "plus4": "bool", "dashes": "bool", "hyphenated": "bool",
+	"sampled": "bool", "epoch": "time", "time": "time",
+	"bbox": "list", "exclude": "list", "holidays": "list", "skip": "list",
+	"require": "list", "of": "list", "weights": "list", "emoji.category": "list",
+	"repeat.of": "template", "jsonarray.of": "template", "mask.of": "template",
+	"semver.base": "string", "case": "string", "category": "string", "char": "string",
+	"country": "string", "currency": "string", "decimalsep": "string", "depth": "string",
+	"dist": "string", "domain": "string", "fallback": "string", "false": "string",
+	"file": "string", "format": "string", "groupsep": "string", "lang": "string", "language": "string",
+	"list": "string", "locale": "string", "markup": "string", "match": "string", "model": "string", "name": "string",
+	"normalize": "string", "overflow": "string", "pattern": "string", "prefix": "string", "preset": "string",
+	"round": "string", "scheme": "string", "sep": "string", "sequence": "string",
+	"show": "string", "sign": "string", "sorted": "string", "state": "string",
+	"style": "string", "other": "string", "true": "string", "type": "string", "weight": "string",
+	"zone": "string",
+}
+
+// optionType returns the type of the given option of the given token, or an empty
+// string if it isn't known
+func optionType(token string, option string) string {
+	if _, ok := tokenRefs[token][option]; ok {
+		return "ref"
+	}
+	if t, ok := optionTypes[token+"."+option]; ok {
+		return t
+	}
+	return optionTypes[option]
+}
+
+// SupportedTokens describes every token that templates can use, sorted by name, along
+// with the options each one takes, their types and their defaults. It's built from the
+// same tables BuildCallstack checks options against, so it always matches what a
+// template will accept.
+func SupportedTokens() []TokenSpec {
+	specs := make([]TokenSpec, 0, len(defaultOptions))
+	for name, defaults := range defaultOptions {
+		spec := TokenSpec{Name: name}
+		add := func(option string, def string) {
+			spec.Options = append(spec.Options, OptionSpec{Name: option, Type: optionType(name, option), Default: def})
+		}
+		for option, def := range defaults {
+			// An ordinal of -1 only means that none was given
+			if option == "ordinal" {
+				def = ""
+			}
+			add(option, def)
+		}
+		for _, option := range extraOptions[name] {
+			if _, ok := defaults[option]; !ok {
+				add(option, "")
+			}
+		}
+		for _, option := range commonOptions {
+			add(option, "")
+		}
+		sort.Slice(spec.Options, func(i, j int) bool { return spec.Options[i].Name < spec.Options[j].Name })
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// checkOptions returns an InvalidArgumentError listing every option given to a known
+// token which it doesn't take, so that a typo like {int:mim:5} isn't silently ignored
+func checkOptions(name string, given map[string]string) error {
+	defaults, ok := defaultOptions[name]
+	if !ok {
+		// An unknown token is reported as such when it's resolved
+		return nil
+	}
+	// A {jsonarray} or {mask} of a token without braces hands the options it doesn't
+	// take to that token, which checks them itself
+	if of, ok := given["of"]; ok && wrapperTokens[name] && !strings.HasPrefix(of, "{") {
+		return nil
+	}
+	unknown := make([]string, 0)
+	for k := range given {
+		if !takesOption(defaults, name, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return InvalidArgumentError(fmt.Sprintf("{%s} does not take the options %s. Please check your input string, or use LenientOptions to ignore them", name, strings.Join(unknown, ", ")))
+}
+
+// takesOption reports whether the named token, whose defaults are given, takes the option
+func takesOption(defaults cmdOptions, name string, option string) bool {
+	_, ok := defaults[option]
+	return ok || inStrings(option, extraOptions[name]) || inStrings(option, commonOptions)
+}
+
+// inStrings reports whether s is one of the values in list
+func inStrings(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// optionExpanders turn options which stand in for others into the options they imply,
+// such as an {int} type into it's min and max. Anything they return can still be
+// overridden by options given explicitly in the template.
+var optionExpanders = map[string]func(cmdOptions) (cmdOptions, error){
+	"int":   expandInt,
+	"float": expandFloat,
+}
+
+// intTypes are the bounds of each integer type that {int} can be limited to. An {int} is
+// generated as an int, so where that's only 32 bits, the types whose bounds don't fit in
+// one aren't supported.
+var intTypes = func() map[string]cmdOptions {
+	types := map[string]cmdOptions{
+		"int8":   cmdOptions{"min": strconv.Itoa(math.MinInt8), "max": strconv.Itoa(math.MaxInt8)},
+		"int16":  cmdOptions{"min": strconv.Itoa(math.MinInt16), "max": strconv.Itoa(math.MaxInt16)},
+		"int32":  cmdOptions{"min": strconv.Itoa(math.MinInt32), "max": strconv.Itoa(math.MaxInt32)},
+		"int64":  cmdOptions{"min": strconv.FormatInt(math.MinInt64, 10), "max": strconv.FormatInt(math.MaxInt64, 10)},
+		"uint8":  cmdOptions{"min": "0", "max": strconv.Itoa(math.MaxUint8)},
+		"uint16": cmdOptions{"min": "0", "max": strconv.Itoa(math.MaxUint16)},
+		"uint32": cmdOptions{"min": "0", "max": strconv.FormatUint(math.MaxUint32, 10)},
+	}
+	if strconv.IntSize == 32 {
+		delete(types, "int64")
+		delete(types, "uint32")
+	}
+	return types
+}()
+
+// optionValidators check the options of a token when the template is parsed, so that
+// mistakes can be reported by BuildCallstack rather than on every call to Write
+var optionValidators = map[string]func(cmdOptions) error{
+	"int":         validateInt,
+	"float":       validateFloat,
+	"geo":         validateGeo,
+	"semver":      validateSemver,
+	"barcode":     validateBarcode,
+	"isbn":        validateISBN,
+	"guid":        validateGUID,
+	"mask":        validateMask,
+	"mimetype":    validateMimeType,
+	"money":       validateMoney,
+	"cents":       validateCents,
+	"pool":        validatePool,
+	"email":       validateEmail,
+	"country":     validateCountry,
+	"address":     validateAddress,
+	"state":       validateState,
+	"zip":         validateZip,
+	"ssn":         validateSSN,
+	"regex":       validateRegex,
+	"string":      validateString,
+	"unicode":     validateUnicode,
+	"ascii":       validateRequire,
+	"time":        validateTime,
+	"now":         validateTimeFormat,
+	"palette":     validatePalette,
+	"blob":        validateBlob,
+	"lorem":       validateLorem,
+	"ulid":        validateIDTime,
+	"snowflake":   validateSnowflake,
+	"phone":       validatePhone,
+	"capital":     validateCapital,
+	"subdivision": validateSubdivision,
+	"numberwords": validateNumberWords,
+	"phonetic":    validatePhonetic,
+	"emoji":       validateEmoji,
+	"jsonvalue":   validateJSONValue,
+	"firstname":   validateName,
+	"lastname":    validateName,
+	"choice":      validateChoice,
+	"bool":        validateBool,
+	"cycle":       validateCycle,
+	"bytes":       validateBytes,
+	"word":        validateWord,
+	"jsonpath":    validateJSONPath,
+	"traceparent": validateTraceparent,
+}
+
+// timeValue is a generated time along with how it was written out, so that an ordinal
+// can either repeat it exactly or write the same instant out another way
+type timeValue struct {
+	t         time.Time
+	formatted string
+}
+
+// newObjectCache makes an empty cache for a single Write. clock is the instant that
+// every {now} in that Write will use, so they all agree with each other no matter how
+// long it takes to generate the rest of the result.
+func newObjectCache(clock time.Time) objectCache {
+	return objectCache{
+		"clock":        clock,
+		"guid":         make([]guidValue, 0),
+		"guidorder":    &orderedIDState{},
+		"now":          make([]timeValue, 0),
+		"time":         make([]timeValue, 0),
+		"country":      make([]countryValue, 0),
+		"unicode":      make([]string, 0),
+		"ascii":        make([]string, 0),
+		"int":          make([]int, 0),
+		"float":        make([]float64, 0),
+		"firstname":    make([]string, 0),
+		"lastname":     make([]string, 0),
+		"geo":          make([]string, 0),
+		"semver":       make([]string, 0),
+		"barcode":      make([]string, 0),
+		"isbn":         make([]string, 0),
+		"mimetype":     make([]string, 0),
+		"currencycode": make([]string, 0),
+		"money":        make([]string, 0),
+		"cents":        make([]int, 0),
+		"pool":         make([]string, 0),
+		"email":        make([]string, 0),
+		"address":      make([]addressValue, 0),
+		"state":        make([]string, 0),
+		"zip":          make([]string, 0),
+		"ssn":          make([]string, 0),
+		"regex":        make([]string, 0),
+		"string":       make([]string, 0),
+		"palette":      make([]string, 0),
+		"blob":         make([]blobValue, 0),
+		"lorem":        make([]string, 0),
+		"ulid":         make([]string, 0),
+		"ulidorder":    &orderedIDState{},
+		"snowflake":    make([]string, 0),
+		"phone":        make([]phoneValue, 0),
+		"capital":      make([]string, 0),
+		"subdivision":  make([]subdivisionValue, 0),
+		"numberwords":  make([]string, 0),
+		"phonetic":     make([]string, 0),
+		"emoji":        make([]string, 0),
+		"jsonvalue":    make([]string, 0),
+		"choice":       make([]string, 0),
+		"bool":         make([]boolValue, 0),
+		"cycle":        make([]string, 0),
+		"bytes":        make([]bytesValue, 0),
+		"word":         make([]string, 0),
+		"jsonpath":     make([]string, 0),
+		"traceparent":  make([]string, 0),
+	}
+}
+
+// discardLast removes the most recently cached value for the given word
+func (oc objectCache) discardLast(word string) {
+	switch cache := oc[word].(type) {
+	case []string:
+		oc[word] = cache[:len(cache)-1]
+	case []int:
+		oc[word] = cache[:len(cache)-1]
+	case []float64:
+		oc[word] = cache[:len(cache)-1]
+	case []timeValue:
+		oc[word] = cache[:len(cache)-1]
+	case []countryValue:
+		oc[word] = cache[:len(cache)-1]
+	case []boolValue:
+		oc[word] = cache[:len(cache)-1]
+	case []bytesValue:
+		oc[word] = cache[:len(cache)-1]
+	case []guidValue:
+		oc[word] = cache[:len(cache)-1]
+	case []blobValue:
+		oc[word] = cache[:len(cache)-1]
+	case []phoneValue:
+		oc[word] = cache[:len(cache)-1]
+	case []subdivisionValue:
+		oc[word] = cache[:len(cache)-1]
+	case []addressValue:
+		oc[word] = cache[:len(cache)-1]
+	}
+}
+
+// cleanLast cleans up the whitespace of the most recently cached value for the given
+// word, as the trim and collapsews options ask for, so that an ordinal reference to it
+// writes out the same bytes as the token did
+func (oc objectCache) cleanLast(word string, opts cmdOptions) {
+	if opts["collapsews"] != "true" && opts["trim"] != "true" {
+		return
+	}
+	switch cache := oc[word].(type) {
+	case []string:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n] = cleanWhitespace(cache[n], opts)
+		}
+	case []timeValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []countryValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []guidValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []blobValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []phoneValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []subdivisionValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []bytesValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	case []addressValue:
+		if n := len(cache) - 1; n >= 0 {
+			cache[n].formatted = cleanWhitespace(cache[n].formatted, opts)
+		}
+	}
+}
+
+// Option configures how BuildCallstack parses a template
+type Option func(*parseConfig) error
+
+// parseConfig holds everything the Options given to BuildCallstack have configured
+type parseConfig struct {
+	// defaults replace the built in defaultOptions, per token
+	defaults map[string]cmdOptions
+	// lenient ignores options which a token doesn't take, rather than returning an error
+	lenient bool
+	// repeat is set while parsing the body of a {repeat}, which is the only place a
+	// sorted {time} has any meaning
+	repeat bool
+	// perToken gives every token a random stream of it's own, and is only allowed
+	// along with a seed
+	perToken bool
+	seeded   bool
+	// files is where {choice} reads a file from, and is nil unless WithFiles was given
+	files fs.FS
+	// snowflakes is shared by the whole template, including everything nested inside of it
+	snowflakes *snowflakeClocks
+}
+
+// nested returns the config for parsing a template nested inside of another, such as
+// the body of a {repeat}
+func (cfg *parseConfig) nested(repeat bool) *parseConfig {
+	return &parseConfig{defaults: cfg.defaults, lenient: cfg.lenient, repeat: repeat, perToken: cfg.perToken, seeded: cfg.seeded, files: cfg.files, snowflakes: cfg.snowflakes}
+}
+
+// newParseConfig applies the given Options to a fresh parseConfig
+func newParseConfig(options []Option, seeded bool) (*parseConfig, error) {
+	cfg := &parseConfig{defaults: make(map[string]cmdOptions), seeded: seeded, snowflakes: newSnowflakeClocks()}
+	for _, o := range options {
+		if err := o(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.perToken && !cfg.seeded {
+		return nil, InvalidArgumentError("PerTokenStreams can only be given to BuildCallstackWithSeed, as there are no streams to derive without a seed")
+	}
+	return cfg, nil
+}
+
+// PerTokenStreams gives each token of a template parsed by BuildCallstackWithSeed a
+// random stream of it's own, derived from the seed, the result, and the token's name
+// as given by the as option, or else it's index in the template. Without it, the
+// tokens of a result all draw from one stream in turn, so changing how many random
+// values one token draws, by adding a token or changing it's options, changes the
+// values of every token after it. With it, a token's values only change when the token
+// itself does, or when it's unnamed and a token is added or removed before it, which
+// changes it's index. Naming every token with as keeps their values steady through
+// any change to the others. It costs a little more to generate each value, and gives
+// different results for the same seed than the single stream does. It's an error for
+// BuildCallstack, which has no seed to derive the streams from.
+func PerTokenStreams() Option {
+	return func(cfg *parseConfig) error {
+		cfg.perToken = true
+		return nil
+	}
+}
+
+// WithDefault replaces the default value of an option for every instance of the named
+// token in the template, including those inside of a {repeat}. It is applied just as
+// the built in defaults are, so an option given explicitly in the template still wins.
+// For example, to write every {time} as RFC3339 unless told otherwise:
+//
+//	moldova.BuildCallstack(template, moldova.WithDefault("time", "format", time.RFC3339))
+func WithDefault(token string, option string, value string) Option {
+	return func(cfg *parseConfig) error {
+		if _, ok := defaultOptions[token]; !ok {
+			return UnsupportedTokenError(fmt.Sprintf("the token %s given to WithDefault is not recognized, check for typos", token))
+		}
+		if cfg.defaults[token] == nil {
+			cfg.defaults[token] = make(cmdOptions)
+		}
+		cfg.defaults[token][option] = value
+		return nil
+	}
+}
+
+// LenientOptions makes BuildCallstack ignore any option which a token doesn't take, as
+// it did before unknown options were reported as errors. This keeps templates that have
+// options meant for other tools, or for newer versions of moldova, working.
+func LenientOptions() Option {
+	return func(cfg *parseConfig) error {
+		cfg.lenient = true
+		return nil
+	}
+}
+
+// WithFiles lets a {choice} in the template read it's values from a file in fsys, such
+// as os.DirFS("testdata"). Without it, a {choice} with the file option is an
+// InvalidArgumentError, so that a template from someone you don't trust can't read
+// anything from the filesystem.
+func WithFiles(fsys fs.FS) Option {
+	return func(cfg *parseConfig) error {
+		if fsys == nil {
+			return InvalidArgumentError("WithFiles was given a nil fs.FS")
+		}
+		cfg.files = fsys
+		return nil
+	}
+}
+
+// BuildCallstack will parse the template, and return a callstack of closures to
+// invoke in order, which will produce static/random values that can be turned into
+// a string. Any Options given change how the template is parsed. An empty template is
+// valid, and writes nothing, but an empty token, {}, is an InvalidArgumentError.
+func BuildCallstack(inputTemplate string, options ...Option) (*Callstack, error) {
+	cfg, err := newParseConfig(options, false)
+	if err != nil {
+		return nil, err
+	}
+	return buildCallstack(inputTemplate, cfg)
+}
+
+// BuildCallstackWithSeed parses the template just as BuildCallstack does, but the
+// Callstack it returns generates the same results every time, given the same seed. Each
+// result draws from a random stream of it's own, derived from the seed and how many
+// results came before it since the Callstack was parsed or Reset, so WriteNParallel
+// writes the same results that WriteN would. Values which depend on the time they're
+// generated at, such as {now}, and ids such as {snowflake} which must never repeat,
+// differ from one run to the next regardless. With the PerTokenStreams Option, each
+// token of each result draws from a stream of it's own instead.
+func BuildCallstackWithSeed(inputTemplate string, seed int64, options ...Option) (*Callstack, error) {
+	cfg, err := newParseConfig(options, true)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := buildCallstack(inputTemplate, cfg)
+	if err != nil {
+		return nil, err
+	}
+	cs.seed = seed
+	cs.seeded = true
+	return cs, nil
+}
+
+func buildCallstack(inputTemplate string, cfg *parseConfig) (_ *Callstack, err error) {
+	stack := newCallstack(cfg.snowflakes)
+	stack.perToken = cfg.perToken
+	// Every token parsed so far, and how many new values each type of token will have
+	// generated, so that tokens can refer back to earlier ones
+	tokens := make([]parsedToken, 0)
+	counts := make(map[string]int)
+	// The names given to tokens with the as option, which must not repeat, and the index
+	// of the token each names so that references can use them
+	names := make(map[string]int)
+	wordBuffer := &bytes.Buffer{}
+	foundWord := false
+	wordStart := 0
+	// Where the plain text between words started, for error reporting
+	textStart := 0
+	// Tokens like {repeat} can contain a template of their own, so track how deeply
+	// nested inside of a word we are, to find the } that actually closes it
+	depth := 0
+	// The token being parsed, which any error parsing the template is in
+	token := ""
+	defer func() {
+		if err != nil {
+			err = &ParseError{Token: token, Position: wordStart, Err: err}
+		}
+	}()
+	for i, c := range inputTemplate {
+		if foundWord && c == '{' {
+			depth++
+			wordBuffer.WriteRune(c)
+		} else if foundWord && c == '}' && depth > 0 {
+			depth--
+			wordBuffer.WriteRune(c)
+		} else if !foundWord && c == '{' {
+			// We're starting a word to parse
+			foundWord = true
+			// Track the position of where the word started, for potential error reporting
+			wordStart = i
+			// Dump the current buffer into a closure
+			// Assigning to 'cb', ClosureBuster, will get around this issue
+			// THANKS .NET PRIOR TO 4.0 FOR TEACHING ME ABOUT ACCESS TO A MODIFIED CLOSURE!
+			cb := wordBuffer.String()
+			wordBuffer.Reset()
+			f := func(result *bytes.Buffer, cache objectCache) error {
+				result.WriteString(cb)
+				return nil
+			}
+			stack.push(fmt.Sprintf("the text at position %d", textStart), "", stackPart{text: cb}, f)
+		} else if foundWord && c == '}' {
+			// We're closing a word, so eval it and get the data to put in the string
+			foundWord = false
+			textStart = i + 1
+			token = wordBuffer.String()
+			segment := fmt.Sprintf("the token {%s} at position %d", token, wordStart)
+			// TODO I dislike this part of the grammer - i think the arguments list
+			// should begin with the |, or at least it's own demarcation, to avoid the
+			// ugly and dual-purpose : construct. I'm open to even changing the grammar
+			// overall, but would need to be a hard version change.
+			parts := strings.SplitN(wordBuffer.String(), ":", 2)
+			// A comment, such as {#: the id column}, is dropped without a trace. It isn't
+			// a token, so it doesn't count towards the index that references use.
+			if parts[0] == "#" {
+				wordBuffer.Reset()
+				continue
+			}
+			if wordBuffer.Len() == 0 {
+				return nil, InvalidArgumentError("the token is empty. A token needs a name, such as {guid}. Please check your input string")
+			}
+			if parts[0] == "" && len(parts) > 1 {
+				return nil, InvalidArgumentError("the token has options, but no name to say which token it is. Please check your input string")
+			}
+			rawOpts := ""
+			if len(parts) > 1 {
+				rawOpts = parts[1]
+			}
+			opts, err := optionsToMap(parts[0], rawOpts, cfg.defaults[parts[0]], !cfg.lenient)
+			if err != nil {
+				return nil, err
+			}
+			// Keep the options as they were written, before references are rewritten
+			given := make(cmdOptions, len(opts))
+			for k, v := range opts {
+				given[k] = v
+			}
+			if err := resolveRefs(parts[0], opts, tokens, names); err != nil {
+				return nil, err
+			}
+			if validate, ok := optionValidators[parts[0]]; ok {
+				if err := validate(opts); err != nil {
+					return nil, err
+				}
+			}
+			if o, ok := opts["ordinal"]; ok {
+				if _, err := strconv.Atoi(o); err != nil {
+					return nil, InvalidArgumentError(fmt.Sprintf("ordinal: %s is not an integer. Please check your input string", o))
+				}
+			}
+			for _, o := range whitespaceOptions {
+				if v, ok := opts[o]; ok && v != "true" && v != "false" {
+					return nil, InvalidArgumentError(fmt.Sprintf("%s: %s is not one of true or false", o, v))
+				}
+			}
+			if parts[0] == "time" && opts["sorted"] != "" && !cfg.repeat {
+				return nil, InvalidArgumentError("sorted: A {time} can only be sorted across the iterations of the {repeat} it is directly inside of. Please check your input string")
+			}
+			// Records keys the value of the token by it's name, or else it's index
+			key := strconv.Itoa(len(tokens))
+			if as, ok := opts["as"]; ok {
+				if _, taken := names[as]; !taken && as != "" && !isNumber(as) {
+					key = as
+					names[as] = len(tokens)
+				} else {
+					return nil, InvalidArgumentError(fmt.Sprintf("as: %s must be a name which is not a number, and not used by another token. Please check your input string", as))
+				}
+			}
+			tokens = append(tokens, newParsedToken(parts[0], opts, counts))
+			wordBuffer.Reset()
+			part := stackPart{token: parts[0], opts: given}
+			// A repeat is made of a whole callstack of it's own, rather than a single value
+			if parts[0] == "repeat" {
+				f, err := repeat(stack, opts, cfg)
+				if err != nil {
+					return nil, err
+				}
+				f = stack.observed(parts[0], cleanWhitespaceOf(f, parts[0], opts))
+				part.body = stack.children[len(stack.children)-1]
+				stack.push(segment, key, part, f)
+				continue
+			}
+			if parts[0] == "jsonarray" {
+				f, err := jsonArray(stack, opts, cfg)
+				if err != nil {
+					return nil, err
+				}
+				f = stack.observed(parts[0], cleanWhitespaceOf(f, parts[0], opts))
+				stack.push(segment, key, part, f)
+				continue
+			}
+			if parts[0] == "mask" {
+				f, err := mask(stack, opts, cfg)
+				if err != nil {
+					return nil, err
+				}
+				f = stack.observed(parts[0], cleanWhitespaceOf(f, parts[0], opts))
+				stack.push(segment, key, part, f)
+				continue
+			}
+			// A choice between values with tokens in them is made of a callstack for
+			// each value
+			if parts[0] == "choice" && opts["ordinal"] == "-1" && strings.ContainsRune(opts["of"]+opts["other"], '{') {
+				f, err := choiceOf(stack, opts, cfg)
+				if err != nil {
+					return nil, err
+				}
+				f = stack.observed(parts[0], cleanWhitespaceOf(f, parts[0], opts))
+				stack.push(segment, key, part, f)
+				continue
+			}
+			// Build the closure that will invoke resolveWord, with whatever the token
+			// works out from it's options ahead of time
+			prep, err := prepareToken(parts[0], opts, cfg)
+			if err != nil {
+				return nil, err
+			}
+			pos := wordStart
+			unique := opts["unique"] == "true"
+			f := func(result *bytes.Buffer, cache objectCache) error {
+				val := ""
+				var err error
+				var start time.Time
+				if stack.observer != nil {
+					start = time.Now()
+				}
+				if unique {
+					val, err = stack.resolveUnique(cache, parts[0], pos, opts, prep)
+				} else {
+					val, err = resolveWord(cache, stack.run, parts[0], pos, opts, prep)
+				}
+				if err != nil {
+					return err
+				}
+				val = cleanWhitespace(val, opts)
+				if opts["ordinal"] == "-1" {
+					cache.cleanLast(parts[0], opts)
+				}
+				if stack.observer != nil {
+					stack.observer(parts[0], val, time.Since(start))
+				}
+				result.WriteString(val)
+				return nil
+			}
+			stack.push(segment, key, part, f)
+			stack.numeric[len(stack.numeric)-1] = jsonNumericTokens[parts[0]] && localizeNumber("-1000.5", opts) == "-1000.5"
+		} else {
+			// Straight pass through
+			wordBuffer.WriteRune(c)
+		}
+	}
+
+	if foundWord {
+		token = wordBuffer.String()
+		return nil, InvalidArgumentError("the token is never closed with a }. Please check your input string")
+	}
+	// If there is anything remaining in word buffer, add the final call to the stack
+	s := wordBuffer.String()
+	// Nothing has been pushed if no tokens were found, so the template is entirely
+	// static
+	if len(stack.stack) == 0 {
+		stack.static = true
+		stack.literal = s
+	}
+	f := func(result *bytes.Buffer, cache objectCache) error {
+		result.WriteString(s)
+		return nil
+	}
+	stack.push(fmt.Sprintf("the text at position %d", textStart), "", stackPart{text: s}, f)
+
+	return stack, nil
+}
+
+// repeat builds the closure for a {repeat} token, which writes the template given by
+// it's of option count times. Each time through is a fresh Write of that template,
+// so ordinals inside of it only refer to tokens from the same time through, and the
+// tokens outside of it never see the values generated inside of it.
+func repeat(parent *Callstack, opts cmdOptions, cfg *parseConfig) (tokenWriter, error) {
+	min, max, err := opts.getCount("count")
+	if err != nil {
+		return nil, err
+	}
+	body, err := buildCallstack(opts["of"], cfg.nested(true))
+	if err != nil {
+		return nil, err
+	}
+	parent.children = append(parent.children, body)
+	sep := opts["sep"]
+	return func(result *bytes.Buffer, cache objectCache) error {
+		count := min + cache.rng().Intn(max-min+1)
+		if err := cache.takeSteps(count); err != nil {
+			return err
+		}
+		if count > 0 {
+			if err := cache.checkLimit(mulCapped(len(sep), count-1)); err != nil {
+				return err
+			}
+		}
+		sorted := &sortedTimes{count: count, times: make(map[int][]time.Time)}
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				result.WriteString(sep)
+			}
+			// Share the clock, so that {now} agrees inside and outside of the repeat
+			scope := cache.scope()
+			scope["sortedtimes"] = sorted
+			if err := body.write(result, scope); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// jsonNumericTokens are the tokens whose values are written into JSON as numbers,
+// rather than as strings
+var jsonNumericTokens = map[string]bool{"int": true, "float": true}
+
+// jsonArray builds the closure for a {jsonarray} token, which writes a JSON array of
+// count values generated by the token given by it's of option. Like {repeat}, each
+// value is generated in it's own scope.
+func jsonArray(parent *Callstack, opts cmdOptions, cfg *parseConfig) (tokenWriter, error) {
+	min, max, err := opts.getCount("count")
+	if err != nil {
+		return nil, err
+	}
+	of := wrappedTemplate("jsonarray", opts)
+	body, err := buildCallstack(of, cfg.nested(false))
+	if err != nil {
+		return nil, err
+	}
+	parent.children = append(parent.children, body)
+	numeric := jsonNumericTokens[singleTokenName(of)] && !localizedTemplate(of, cfg)
+	return func(result *bytes.Buffer, cache objectCache) error {
+		count := min + cache.rng().Intn(max-min+1)
+		if err := cache.takeSteps(count); err != nil {
+			return err
+		}
+		// The brackets and the commas between the values
+		if err := cache.checkLimit(count + 1); err != nil {
+			return err
+		}
+		value := &bytes.Buffer{}
+		result.WriteByte('[')
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				result.WriteByte(',')
+			}
+			value.Reset()
+			if err := body.write(value, cache.scope()); err != nil {
+				return err
+			}
+			if err := cache.checkLimit(result.Len() + value.Len()); err != nil {
+				return err
+			}
+			if numeric {
+				result.Write(value.Bytes())
+			} else {
+				b, err := json.Marshal(value.String())
+				if err != nil {
+					return err
+				}
+				result.Write(b)
+			}
+		}
+		result.WriteByte(']')
+		return nil
+	}, nil
+}
+
+// wrapperTokens are the tokens which write out the value of another token, given by
+// their of option
+var wrapperTokens = map[string]bool{"jsonarray": true, "mask": true}
+
+// wrappedTemplate returns the template of the token wrapped by a token such as
+// {jsonarray}. The token can be given without braces, such as of:int:min:1|max:9, in
+// which case it's options after the first are parsed as if they belong to the wrapper.
+// Hand them back to the token.
+func wrappedTemplate(name string, opts cmdOptions) string {
+	of := opts["of"]
+	if strings.HasPrefix(of, "{") {
+		return of
+	}
+	extra := make([]string, 0)
+	for k, v := range opts {
+		if _, ok := defaultOptions[name][k]; !ok {
+			extra = append(extra, k+":"+v)
+		}
+	}
+	sort.Strings(extra)
+	return "{" + strings.Join(append([]string{of}, extra...), "|") + "}"
+}
+
+// localizedTemplate reports whether a template made of a single number changes it's
+// separators, in which case it can't be written into JSON as a number
+func localizedTemplate(template string, cfg *parseConfig) bool {
+	parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(template, "{"), "}"), ":", 2)
+	rawOpts := ""
+	if len(parts) > 1 {
+		rawOpts = parts[1]
+	}
+	opts, err := optionsToMap(parts[0], rawOpts, cfg.defaults[parts[0]], false)
+	if err != nil {
+		return false
+	}
+	return localizeNumber("-1000.5", opts) != "-1000.5"
+}
+
+// singleTokenName returns the name of the token if the template is made of exactly
+// one token and nothing else, or an empty string if it isn't
+func singleTokenName(template string) string {
+	if !strings.HasPrefix(template, "{") {
+		return ""
+	}
+	depth := 0
+	for i, c := range template {
+		if c == '{' {
+			depth++
+		} else if c == '}' {
+			depth--
+			if depth == 0 && i != len(template)-1 {
+				return ""
+			}
+		}
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(template, "{"), "}")
+	return strings.SplitN(inner, ":", 2)[0]
+}
+
+// reroll will invoke attempt until it reports that it produced an acceptable value,
+// giving up after MaxRetries attempts. what describes the value being generated, for
+// the error message.
+func reroll(what string, attempt func() bool) error {
+	tries := MaxRetries
+	if tries < 1 {
+		tries = 1
+	}
+	for i := 0; i < tries; i++ {
+		if attempt() {
+			return nil
+		}
+	}
+	return ExhaustedRetriesError(fmt.Sprintf("Could not generate %s satisfying the given constraints after %d attempts. Please check your input string", what, tries))
+}
+
+// BuildCallstackFromReader will read the entire template from r, and then parse it
+// just as BuildCallstack does. Newlines, tabs and every other character outside of a
+// token are kept as is.
+func BuildCallstackFromReader(r io.Reader, options ...Option) (*Callstack, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCallstack(string(b), options...)
+}
+
+// Warning is something LintSQL found in a template which is likely to be a mistake
+type Warning struct {
+	// Segment describes the part of the template the warning is about
+	Segment string
+	// Message explains what looks to be wrong
+	Message string
+}
+
+// LintSQL looks over a template meant to generate SQL for the mistakes which are most
+// often made writing one. It warns about tokens which write strings, such as {country},
+// that aren't inside of single quotes, and about NULL inside of them, which is the
+// string NULL rather than a NULL value. It only follows single quotes, so it won't be
+// right about every template, like one with quotes inside of a comment. A template
+// which can't be parsed returns a single Warning with the error.
+func LintSQL(template string, options ...Option) []Warning {
+	cs, err := BuildCallstack(template, options...)
+	if err != nil {
+		return []Warning{{Segment: "the template", Message: "It could not be parsed: " + err.Error()}}
+	}
+	l := &sqlLinter{}
+	l.lint(cs)
+	if l.quoted {
+		l.warn(l.opened, "A single quote is opened here, but is never closed")
+	}
+	return l.warnings
+}
+
+// sqlLinter holds the state of LintSQL as it works through a template
+type sqlLinter struct {
+	warnings []Warning
+	// quoted is set while inside of a single quoted string, which was opened in the
+	// segment given by opened
+	quoted bool
+	opened string
+	// literal is the text of the quoted string so far, and generated is set if a token
+	// has written anything into it
+	literal   strings.Builder
+	generated bool
+}
+
+func (l *sqlLinter) warn(segment string, message string) {
+	l.warnings = append(l.warnings, Warning{Segment: segment, Message: message})
+}
+
+// lint works through each part of the template in c, following the {repeat}s nested
+// inside of it, which carry on from the quotes around them
+func (l *sqlLinter) lint(c *Callstack) {
+	for i, p := range c.parts {
+		switch {
+		case p.token == "":
+			l.text(p.text, c.segments[i])
+		case p.body != nil:
+			l.lint(p.body)
+		case l.quoted:
+			l.generated = true
+			if sqlValues(p, func(v string) bool { return strings.EqualFold(v, "NULL") }) {
+				l.warn(c.segments[i], fmt.Sprintf("{%s} can write NULL inside of single quotes, which is the string NULL rather than a NULL value", p.token))
+			}
+		case c.numeric[i] || sqlBare(p):
+		default:
+			l.warn(c.segments[i], fmt.Sprintf("{%s} writes a string, but isn't inside of single quotes", p.token))
+		}
+	}
+}
+
+// text follows the single quotes in plain text from the template
+func (l *sqlLinter) text(s string, segment string) {
+	for _, r := range s {
+		switch {
+		case r == '\'' && l.quoted:
+			if !l.generated && strings.EqualFold(strings.TrimSpace(l.literal.String()), "NULL") {
+				l.warn(l.opened, "'NULL' is the string NULL rather than a NULL value, leave the quotes off of it")
+			}
+			l.quoted = false
+		case r == '\'':
+			l.quoted, l.opened, l.generated = true, segment, false
+			l.literal.Reset()
+		case l.quoted:
+			l.literal.WriteRune(r)
+		}
+	}
+}
+
+// sqlBareValue reports whether SQL can take a value given to a token without quotes
+func sqlBareValue(v string) bool {
+	return plainNumber(v, true) || strings.EqualFold(v, "NULL") || strings.EqualFold(v, "true") || strings.EqualFold(v, "false")
+}
+
+// sqlUnixTime reports whether a {time} or {now} is written as a number of seconds or
+// milliseconds
+func sqlUnixTime(opts cmdOptions) bool {
+	return opts["format"] == "unix" || opts["format"] == "unixms"
+}
+
+// sqlBareTokens are the tokens other than {int} and {float} which, with the options
+// given, only ever write values that SQL can take without quotes. {bytes} isn't one of
+// them, as it writes a unit after the number.
+var sqlBareTokens = map[string]func(opts cmdOptions) bool{
+	"snowflake": func(opts cmdOptions) bool { return true },
+	"cents":     func(opts cmdOptions) bool { return true },
+	"time":      sqlUnixTime,
+	"now":       sqlUnixTime,
+	"blob": func(opts cmdOptions) bool {
+		return opts["format"] == "mysqlhex"
+	},
+	"bool": func(opts cmdOptions) bool {
+		t, f := opts["true"], opts["false"]
+		return (t == "" || sqlBareValue(t)) && (f == "" || sqlBareValue(f))
+	},
+}
+
+// sqlBare reports whether a token only ever writes values which SQL can take without
+// quotes, such as a {snowflake} or a {choice} between numbers
+func sqlBare(p stackPart) bool {
+	if bare, ok := sqlBareTokens[p.token]; ok {
+		return bare(p.opts)
+	}
+	quoted := func(v string) bool { return !sqlBareValue(v) }
+	return (p.token == "choice" || p.token == "cycle") && p.opts["of"] != "" && !sqlValues(p, quoted)
+}
+
+// sqlValues reports whether any of the values a {choice} or {cycle} was given by it's
+// of option, or a {choice} by it's other option, satisfy f. Other tokens have no values to check.
+func sqlValues(p stackPart, f func(string) bool) bool {
+	if p.token != "choice" && p.token != "cycle" {
+		return false
+	}
+	values := splitChoices(p.opts["of"])
+	if other, ok := p.opts["other"]; ok {
+		values = append(values, other)
+	}
+	for _, v := range values {
+		if f(strings.TrimSpace(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultCompileCacheSize is how many parsed templates Compile holds on to, unless
+// SetCompileCacheSize is called
+const DefaultCompileCacheSize = 256
+
+// compiled holds the Callstacks that Compile has parsed, with the most recently used at
+// the front of order
+var compiled = struct {
+	sync.Mutex
+	size  int
+	order *list.List
+	byKey map[string]*list.Element
+}{size: DefaultCompileCacheSize, order: list.New(), byKey: make(map[string]*list.Element)}
+
+// compiledTemplate is an entry in the compile cache
+type compiledTemplate struct {
+	template string
+	cs       *Callstack
+}
+
+// Compile parses the template just as BuildCallstack does, but remembers the result, so
+// that compiling the same template again returns the very same Callstack without parsing
+// it. This suits a service which is given the same templates over and over. Only the
+// most recently used templates are kept, up to the size set by SetCompileCacheSize.
+//
+// As the Callstack is shared by everyone who compiles that template, so is the state
+// which carries over from one Write to the next, such as unique values and sequences.
+// Use BuildCallstack for a Callstack of your own. The Callstack must not be changed, such
+// as by SetObserver, while others may be using it.
+func Compile(template string) (*Callstack, error) {
+	compiled.Lock()
+	if e, ok := compiled.byKey[template]; ok {
+		compiled.order.MoveToFront(e)
+		compiled.Unlock()
+		return e.Value.(*compiledTemplate).cs, nil
+	}
+	compiled.Unlock()
+
+	// Parse without holding the lock, so that a slow template doesn't hold up others
+	cs, err := BuildCallstack(template)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled.Lock()
+	defer compiled.Unlock()
+	// Someone else may have compiled it in the meantime, in which case theirs wins, so
+	// that everyone shares the one Callstack
+	if e, ok := compiled.byKey[template]; ok {
+		compiled.order.MoveToFront(e)
+		return e.Value.(*compiledTemplate).cs, nil
+	}
+	if compiled.size > 0 {
+		compiled.byKey[template] = compiled.order.PushFront(&compiledTemplate{template: template, cs: cs})
+		evictCompiled()
+	}
+	return cs, nil
+}
+
+// SetCompileCacheSize sets how many templates Compile holds on to, forgetting the least
+// recently used templates if it already holds more. A size of 0 turns the cache off.
+func SetCompileCacheSize(n int) {
+	compiled.Lock()
+	defer compiled.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	compiled.size = n
+	evictCompiled()
+}
+
+// ClearCompileCache forgets every template that Compile holds on to. Callstacks that
+// were already returned keep working.
+func ClearCompileCache() {
+	compiled.Lock()
+	defer compiled.Unlock()
+	compiled.order.Init()
+	compiled.byKey = make(map[string]*list.Element)
+}
+
+// evictCompiled forgets the least recently used templates until the compile cache fits
+// within it's size. The caller must hold the lock.
+func evictCompiled() {
+	for compiled.order.Len() > compiled.size {
+		e := compiled.order.Back()
+		compiled.order.Remove(e)
+		delete(compiled.byKey, e.Value.(*compiledTemplate).template)
+	}
+}
+
+// This function was borrowed with permission from the following location
+// https://github.com/dgryski/trifles/blob/master/uuid/uuid.go
+// All credit / lawsuits can be forwarded to Damian Gryski and Russ Cox
+func uuidv4(oc objectCache) string {
+	b := make([]byte, 16)
+	_, err := io.ReadFull(oc.entropy(), b)
+	if err != nil {
+		// probably "shouldn't happen"
+		log.Fatal(err)
+	}
+	b[6] = (b[6] & 0x0F) | 0x40
+	b[8] = (b[8] &^ 0x40) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// orderedIDState is the last time ordered id of a kind generated in a Write, so the
+// next one can be made to sort after it
+type orderedIDState struct {
+	last []byte
+}
+
+// next fills in the timestamp of b, the first 48 bits, with the unix time in ms. If
+// the last id was made in the same millisecond, b becomes the last id with increment
+// applied to it instead, so that it sorts after the last one. The same goes for when
+// the clock has gone backwards, unless the time was pinned by the time option.
+func (s *orderedIDState) next(b []byte, ms uint64, pinned bool, increment func([]byte) bool) {
+	if s.last != nil {
+		var lastMs uint64
+		for _, d := range s.last[:6] {
+			lastMs = lastMs<<8 | uint64(d)
+		}
+		if ms == lastMs || (ms < lastMs && !pinned) {
+			ms = lastMs
+			copy(b, s.last)
+			if !increment(b) {
+				// Every random bit was already set, so move on to the next millisecond
+				ms++
+			}
+		}
+	}
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	s.last = b
+}
+
+// uuidv7 generates a time ordered uuid, as described in RFC 9562. The first 48 bits are
+// the unix time in milliseconds and the rest is random. Every uuid is greater than the
+// one before it in the same Write. Unlike uuidv4 the random bits come from math/rand, so
+// that a uuid with a pinned time can be reproduced.
+func uuidv7(r *rand.Rand, state *orderedIDState, ms uint64, pinned bool) string {
+	b := randomBytes(r, 16)
+	state.next(b, ms, pinned, incrementUUIDv7)
+	b[6] = (b[6] & 0x0F) | 0x70
+	b[8] = (b[8] &^ 0x40) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// incrementUUIDv7 adds one to the random bits of a version 7 uuid, skipping over the
+// version and variant bits. It returns false if they were all set, and wrapped to 0.
+func incrementUUIDv7(b []byte) bool {
+	for i := 15; i >= 6; i-- {
+		// The top of bytes 6 and 8 hold the version and variant, so only the bottom can carry
+		var mask byte = 0xFF
+		switch i {
+		case 6:
+			mask = 0x0F
+		case 8:
+			mask = 0x3F
+		}
+		if b[i]&mask != mask {
+			b[i] = (b[i] &^ mask) | ((b[i] & mask) + 1)
+			return true
+		}
+		b[i] &^= mask
+	}
+	return false
+}
+
+// crockford is the alphabet of Crockford's base 32, which ULIDs are written in
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID generates a ULID, which is a 48 bit unix time in milliseconds followed by 80
+// random bits, written out as 26 characters of Crockford's base 32. As with uuidv7,
+// every ULID is greater than the one before it in the same Write.
+func newULID(r *rand.Rand, state *orderedIDState, ms uint64, pinned bool) string {
+	b := randomBytes(r, 16)
+	state.next(b, ms, pinned, incrementULID)
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+	// Read the 128 bits 5 at a time, from the least significant end
+	s := make([]byte, 26)
+	for j := 0; j < 26; j++ {
+		var d uint64
+		switch shift := uint(5 * j); {
+		case shift >= 64:
+			d = hi >> (shift - 64)
+		case shift > 59:
+			d = lo>>shift | hi<<(64-shift)
+		default:
+			d = lo >> shift
+		}
+		s[25-j] = crockford[d&31]
+	}
+	return string(s)
+}
+
+// incrementULID adds one to the random bits of a ULID. It returns false if they were all
+// set, and wrapped to 0.
+func incrementULID(b []byte) bool {
+	for i := 15; i >= 6; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// idTime returns the unix time in milliseconds that a time ordered id should start
+// with, and whether it was pinned by the time option rather than taken from the clock
+// of the result, which {now} reads as well
+func idTime(oc objectCache, opts cmdOptions) (uint64, bool, error) {
+	var t time.Time
+	switch pin := opts["time"]; {
+	case pin == "":
+		return uint64(oc["clock"].(time.Time).UnixNano() / int64(time.Millisecond)), false, nil
+	case isRef(pin):
+		v, err := oc.lookupRef(pin)
+		if err != nil {
+			return 0, false, err
+		}
+		t = v.(timeValue).t
+	default:
+		var err error
+		if t, err = parseIDTime(pin); err != nil {
+			return 0, false, err
+		}
+	}
+	if t.Before(time.Unix(0, 0)) {
+		return 0, false, InvalidArgumentError(fmt.Sprintf("time: %s is before 1970, which can't be part of a time ordered id", t.Format(time.RFC3339)))
+	}
+	return uint64(t.UnixNano() / int64(time.Millisecond)), true, nil
+}
+
+// parseIDTime reads the time option of a time ordered id, when it isn't a reference
+func parseIDTime(pin string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, pin)
+	if err != nil {
+		return t, InvalidArgumentError(fmt.Sprintf("time: %s must refer to an earlier {time} or {now} token, such as @0, or be a time such as 2020-01-01T00:00:00Z. Please check your input string", pin))
+	}
+	if t.Before(time.Unix(0, 0)) {
+		return t, InvalidArgumentError(fmt.Sprintf("time: %s is before 1970, which can't be part of a time ordered id", pin))
+	}
+	return t, nil
+}
+
+// validateIDTime checks the time option of a time ordered id
+func validateIDTime(opts cmdOptions) error {
+	if pin := opts["time"]; pin != "" && !isRef(pin) {
+		_, err := parseIDTime(pin)
+		return err
+	}
+	return nil
+}
+
+// parsedToken is what BuildCallstack knows about a token it has already parsed, so
+// that later tokens can refer back to it
+type parsedToken struct {
+	name string
+	// slot is the index of the token's value in the cache for it's type, or -1 if the
+	// token doesn't cache it's value
+	slot int
+}
+
+// newParsedToken works out where the value of the named token will be cached, given
+// how many new values have been generated by each type of token before it
+func newParsedToken(name string, opts cmdOptions, counts map[string]int) parsedToken {
+	if _, ok := defaultOptions[name]["ordinal"]; !ok {
+		return parsedToken{name: name, slot: -1}
+	}
+	// An ordinal doesn't generate anything new, it's value is the one it refers to
+	if ord, err := opts.getInt("ordinal"); err == nil && ord >= 0 {
+		return parsedToken{name: name, slot: ord}
+	}
+	slot := counts[name]
+	counts[name]++
+	return parsedToken{name: name, slot: slot}
+}
+
+// refOption describes an option that can refer back to earlier tokens
+type refOption struct {
+	// types are the tokens that the option may refer to
+	types []string
+	// max is how many tokens the option may refer to at once, as a comma separated list
+	max int
+}
+
+// tokenRefs lists the options of each token that can refer back to an earlier token in
+// the template by it's index, such as {money:currency:@0}, along with which types of
+// token they may refer to. Indexes count every token in the template, starting at 0.
+var tokenRefs = map[string]map[string]refOption{
+	"money":     {"currency": {types: []string{"currencycode"}, max: 1}},
+	"cents":     {"currency": {types: []string{"currencycode"}, max: 1}},
+	"email":     {"from": {types: []string{"firstname", "lastname"}, max: 2}},
+	"zip":       {"state": {types: []string{"state"}, max: 1}},
+	"time":      {"after": {types: []string{"time", "now"}, max: 1}},
+	"guid":      {"time": {types: []string{"time", "now"}, max: 1}},
+	"ulid":      {"time": {types: []string{"time", "now"}, max: 1}},
+	"capital":   {"of": {types: []string{"country"}, max: 1}},
+	"bool":      {"not": {types: []string{"bool"}, max: 1}},
+	"firstname": {"locale": {types: []string{"country"}, max: 1}},
+	"lastname":  {"locale": {types: []string{"country"}, max: 1}},
+	"phone":     {"country": {types: []string{"country"}, max: 1}},
+	"int": {
+		"min": {types: []string{"int"}, max: 1},
+		"max": {types: []string{"int"}, max: 1},
+	},
+	"float": {
+		"min": {types: []string{"int", "float"}, max: 1},
+		"max": {types: []string{"int", "float"}, max: 1},
+	},
+	"subdivision": {"of": {types: []string{"country"}, max: 1}},
+	"numberwords": {
+		"of":  {types: []string{"int"}, max: 1},
+		"min": {types: []string{"int"}, max: 1},
+		"max": {types: []string{"int"}, max: 1},
+	},
+	"phonetic": {"of": {types: []string{"ascii", "unicode", "regex", "string", "word", "firstname", "lastname",
+		"country", "zip", "ssn", "ulid", "semver", "isbn", "barcode", "snowflake", "int"}, max: 1}},
+}
+
+// resolveRefs checks every reference in opts against the tokens parsed so far, and
+// rewrites each as @type:slot so that lookupRef can find it in the cache during Write.
+// A list of references, such as @0,@1 (or just @0,1), is rewritten one at a time. A
+// reference is either the index of the token, or the name it was given with as.
+func resolveRefs(name string, opts cmdOptions, tokens []parsedToken, names map[string]int) error {
+	for opt, ro := range tokenRefs[name] {
+		v := opts[opt]
+		if !strings.HasPrefix(v, "@") {
+			continue
+		}
+		list := strings.Split(v[1:], ",")
+		if len(list) > ro.max {
+			return InvalidArgumentError(fmt.Sprintf("%s: %s refers to %d tokens, but can refer to at most %d. Please check your input string", opt, v, len(list), ro.max))
+		}
+		refs := make([]string, len(list))
+		for n, l := range list {
+			l = strings.TrimPrefix(l, "@")
+			i, err := strconv.Atoi(l)
+			if n, ok := names[l]; ok {
+				i, err = n, nil
+			}
+			if err != nil || i < 0 || i >= len(tokens) {
+				return InvalidArgumentError(fmt.Sprintf("%s: %s does not refer to an earlier token. Please check your input string", opt, v))
+			}
+			t := tokens[i]
+			found := false
+			for _, a := range ro.types {
+				if t.name == a && t.slot >= 0 {
+					found = true
+				}
+			}
+			if !found {
+				return InvalidArgumentError(fmt.Sprintf("%s: %s refers to a %s token, but must refer to one of %s. Please check your input string", opt, v, t.name, strings.Join(ro.types, ", ")))
+			}
+			refs[n] = fmt.Sprintf("@%s:%d", t.name, t.slot)
+		}
+		opts[opt] = strings.Join(refs, ",")
+	}
+	return nil
+}
+
+// isNumber reports whether s reads as an integer, which a name given with as can't be,
+// as references would then be ambiguous
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// isRef reports whether an option value is a reference resolved by resolveRefs
+func isRef(v string) bool {
+	return strings.HasPrefix(v, "@")
+}
+
+// lookupRef returns the cached value that a reference resolved by resolveRefs points at
+func (oc objectCache) lookupRef(ref string) (interface{}, error) {
+	parts := strings.SplitN(strings.TrimPrefix(ref, "@"), ":", 2)
+	slot := -1
+	if len(parts) == 2 {
+		slot, _ = strconv.Atoi(parts[1])
+	}
+	if slot >= 0 {
+		switch cache := oc[parts[0]].(type) {
+		case []string:
+			if slot < len(cache) {
+				return cache[slot], nil
+			}
+		case []int:
+			if slot < len(cache) {
+				return cache[slot], nil
+			}
+		case []float64:
+			if slot < len(cache) {
+				return cache[slot], nil
+			}
+		case []timeValue:
+			if slot < len(cache) {
+				return cache[slot], nil
+			}
+		case []boolValue:
+			if slot < len(cache) {
+				return cache[slot], nil
+			}
+		case []countryValue:
+			if slot < len(cache) {
+				return cache[slot].code, nil
+			}
+		}
+	}
+	return nil, InvalidArgumentError(fmt.Sprintf("The token refered to by %s has not been generated. Please check your input string", ref))
+}
+
+// splitOutsideBraces splits s around each instance of sep, except for those inside of
+// a nested {} pair, so that the options of a nested template are kept together
+func splitOutsideBraces(s string, sep rune) []string {
+	parts := []string{}
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// rangeShorthands are the tokens which take a range, such as {int:5-10}, in place of
+// their min and max, and whether the bounds of the range may have a decimal point
+var rangeShorthands = map[string]bool{"int": false, "float": true}
+
+// parseRangeShorthand splits a range into it's min and max. Either bound may be
+// negative, so the range is split at the first - which follows a digit, making -10--5
+// the range from -10 to -5. Each bound must be a plain number, without an exponent.
+func parseRangeShorthand(s string, decimal bool) (string, string, bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '-' && s[i-1] >= '0' && s[i-1] <= '9' {
+			min, max := s[:i], s[i+1:]
+			return min, max, plainNumber(min, decimal) && plainNumber(max, decimal)
+		}
+	}
+	return "", "", false
+}
+
+// plainNumber reports whether s is made of digits, optionally with a leading - and, if
+// decimal is set, a single decimal point between them
+func plainNumber(s string, decimal bool) bool {
+	s = strings.TrimPrefix(s, "-")
+	point := false
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && decimal && !point && i > 0 && i < len(s)-1:
+			point = true
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// rawOptions are the options which take everything after them as their value, other
+// than any options the token takes which follow them. This lets a {regex} pattern
+// contain | as alternation.
+var rawOptions = map[string]string{
+	"regex":  "pattern",
+	"string": "match",
+}
+
+// lastAlternative returns the index of the last | in a pattern which separates it's
+// alternatives, rather than being escaped or inside of a group, class or quantifier, or
+// -1 if there isn't one
+func lastAlternative(pattern string) int {
+	last, depth, class := -1, 0, false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			// A ] straight after the [ is part of the class
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		case c == '(' || c == '{':
+			depth++
+		case (c == ')' || c == '}') && depth > 0:
+			depth--
+		case c == '|' && depth == 0:
+			last = i
+		}
+	}
+	return last
+}
+
+// optionAliases are other names an option can be given by, per token, which are read
+// exactly as if the option itself was given
+var optionAliases = map[string]map[string]string{
+	"choice": {"values": "of"},
+	"cycle":  {"values": "of"},
+}
+
+func optionsToMap(name string, options string, overrides cmdOptions, strict bool) (map[string]string, error) {
+	m := make(map[string]string)
+	defaults := defaultOptions[name]
+	for k, v := range defaults {
+		m[k] = v
+	}
+	// Defaults given to BuildCallstack replace the built in ones
+	for k, v := range overrides {
+		m[k] = v
+	}
+	// If there were no options specified, just use defaults
+	if len(options) == 0 {
+		return m, nil
+	}
+	given := make(map[string]string)
+	// An option which takes the rest of the options verbatim is split off first, so
+	// that any | or : inside of it are left alone
+	if raw, ok := rawOptions[name]; ok {
+		i := strings.Index("|"+options, "|"+raw+":")
+		if i >= 0 {
+			value := options[i+len(raw)+1:]
+			// Options the token takes can still follow, and are split off from the end
+			for {
+				j := lastAlternative(value)
+				if j < 0 {
+					break
+				}
+				opt := strings.SplitN(value[j+1:], ":", 2)
+				if len(opt) < 2 || opt[0] == raw || !takesOption(defaults, name, opt[0]) {
+					break
+				}
+				given[opt[0]] = opt[1]
+				value = value[:j]
+			}
+			given[raw] = value
+			options = strings.TrimSuffix(options[:i], "|")
+		}
+	}
+	if options != "" {
+		shorthand := ""
+		for i, p := range splitOutsideBraces(options, '|') {
+			// Some options, like format, can have : in them. Only split the first :, which
+			// should have the arg name, ad a value with an arbitrary number of : inside of it
+			opt := strings.SplitN(p, ":", 2)
+			if len(opt) == 2 {
+				if shorthand != "" && (opt[0] == "min" || opt[0] == "max") {
+					return nil, InvalidArgumentError(fmt.Sprintf("{%s}: the range %s can't be given along with %s. Please check your input string", name, shorthand, opt[0]))
+				}
+				given[opt[0]] = opt[1]
+				continue
+			}
+			// The only option without a name is a range, such as {int:5-10}, which must
+			// come first
+			decimal, ok := rangeShorthands[name]
+			if !ok || i > 0 {
+				return nil, InvalidArgumentError(fmt.Sprintf("{%s}: %s is not an option, which is written as name:value. Please check your input string", name, p))
+			}
+			min, max, ok := parseRangeShorthand(p, decimal)
+			if !ok {
+				return nil, InvalidArgumentError(fmt.Sprintf("{%s}: %s is not a range of numbers, such as 5-10 or -10--5. Please check your input string", name, p))
+			}
+			// A range reads as including both of it's ends, unless maxInclusive:false
+			// follows it
+			given["min"], given["max"], given["maxInclusive"] = min, max, "true"
+			shorthand = p
+		}
+	}
+	for alias, opt := range optionAliases[name] {
+		v, ok := given[alias]
+		if !ok {
+			continue
+		}
+		if _, both := given[opt]; both {
+			return nil, InvalidArgumentError(fmt.Sprintf("{%s}: %s is another name for %s, so only one of them can be given. Please check your input string", name, alias, opt))
+		}
+		given[opt] = v
+		delete(given, alias)
+	}
+	if strict {
+		if err := checkOptions(name, given); err != nil {
+			return nil, err
+		}
+	}
+	// Some options stand in for the values of others, which sit between the defaults
+	// and anything given explicitly in the template
+	if expand, ok := optionExpanders[name]; ok {
+		expanded, err := expand(given)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range expanded {
+			m[k] = v
+		}
+	}
+	for k, v := range given {
+		m[k] = v
+	}
+	return m, nil
+}
+
+// prepareToken works out anything a token needs from it's options which doesn't change
+// from one result to the next, once when the template is parsed. What it returns is
+// given to resolveWord each time the token is written, or nil if there's nothing.
+func prepareToken(word string, opts cmdOptions, cfg *parseConfig) (interface{}, error) {
+	switch word {
+	case "country":
+		return prepareCountry(opts)
+	case "choice":
+		return prepareChoice(opts, cfg)
+	case "regex":
+		return prepareRegex(opts)
+	case "string":
+		return prepareString(opts)
+	}
+	return nil, nil
+}
+
+func resolveWord(oc objectCache, rs *runState, word string, pos int, opts cmdOptions, prep interface{}) (string, error) {
+	// If there were options provided, convert them to a lookup map prior to invoking
+	// a randomizer.
+	switch word {
+	case "guid":
+		return guid(oc, opts)
+	case "int":
+		return integer(oc, opts)
+	case "now":
+		return now(oc, opts)
+	case "time":
+		return datetime(oc, opts)
+	case "float":
+		return float(oc, opts)
+	case "unicode":
+		return unicode(oc, opts)
+	case "ascii":
+		return ascii(oc, opts)
+	case "country":
+		return country(oc, opts, prep)
+	case "firstname":
+		return firstname(oc, opts)
+	case "lastname":
+		return lastname(oc, opts)
+	case "geo":
+		return geo(oc, opts)
+	case "semver":
+		return semver(oc, rs, pos, opts)
+	case "barcode":
+		return barcode(oc, opts)
+	case "isbn":
+		return isbn(oc, opts)
+	case "mimetype":
+		return mimetype(oc, opts)
+	case "currencycode":
+		return currencycode(oc, opts)
+	case "money":
+		return money(oc, opts)
+	case "cents":
+		return cents(oc, opts)
+	case "pool":
+		return pool(oc, opts)
+	case "email":
+		return email(oc, opts)
+	case "address":
+		return address(oc, opts)
+	case "state":
+		return state(oc, opts)
+	case "zip":
+		return zip(oc, opts)
+	case "ssn":
+		return ssn(oc, opts)
+	case "regex":
+		return regex(oc, opts, prep)
+	case "string":
+		return matchString(oc, opts, prep)
+	case "palette":
+		return palette(oc, opts)
+	case "blob":
+		return blob(oc, opts)
+	case "lorem":
+		return lorem(oc, opts)
+	case "ulid":
+		return ulid(oc, opts)
+	case "snowflake":
+		return snowflake(oc, rs, opts)
+	case "phone":
+		return phone(oc, opts)
+	case "capital":
+		return capital(oc, opts)
+	case "subdivision":
+		return subdivision(oc, opts)
+	case "numberwords":
+		return numberWords(oc, opts)
+	case "phonetic":
+		return phonetic(oc, opts)
+	case "emoji":
+		return emoji(oc, opts)
+	case "choice":
+		return choice(oc, opts, prep)
+	case "bool":
+		return boolean(oc, opts)
+	case "cycle":
+		return cycle(oc, rs, pos, opts)
+	case "bytes":
+		return byteSize(oc, opts)
+	case "word":
+		return madeUpWord(oc, opts)
+	case "jsonpath":
+		return jsonPath(oc, opts)
+	case "jsonvalue":
+		return jsonValue(oc, opts)
+	case "traceparent":
+		return traceparent(oc, opts)
+	}
+	return "", UnsupportedTokenError(fmt.Sprintf("the token %s at position %d is not recognized, check for typos", word, pos))
+}
+
+// TODO All the below functions need way better commenting and parameter annotations
 // It's described in the readme, but I should probably make these public and then
 // give them proper comments, so that GoDoc can also document them
 
-func integer(oc objectCache, opts cmdOptions) (string, error) {
-	min, err := opts.getInt("min")
+func integer(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+	exclude, err := opts.getIntList("exclude")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["int"]
+		cache := c.([]int)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for integers. Please check your input string", ord))
+		}
+		i := cache[ord]
+		return localizeNumber(strconv.Itoa(i), opts), nil
+	}
+
+	min, err := oc.intBound(opts, "min")
+	if err != nil {
+		return "", err
+	}
+	max, err := oc.intBound(opts, "max")
+	if err != nil {
+		return "", err
+	}
+	if err := validateIntRange(min, max, opts); err != nil {
+		return "", err
+	}
+	// The largest value which can be generated
+	top := max
+	if excludesMax(opts, min == max) {
+		top = max - 1
+	}
+
+	// The values at the very edges of the range, which edge:true favors to help with
+	// testing boundary and overflow handling
+	var edges []int
+	if opts["edge"] == "true" {
+		edges = []int{min, top}
+		if min < top {
+			edges = append(edges, min+1, top-1)
+		}
+		if min < 0 && top > 0 {
+			edges = append(edges, -1, 0, 1)
+		}
+	}
+
+	// How many values there are to pick from. The widest ranges, like those of an
+	// int64, can't have this held in an int, so work it out using unsigned math. It
+	// wraps around to 0 when every int64 is in the range.
+	span := uint64(top) - uint64(min) + 1
+	snap, err := opts.getInt("snap")
+	if err != nil && opts["snap"] != "" {
+		return "", err
+	}
+	var n int
+	err = reroll("an integer", func() bool {
+		if len(edges) > 0 && oc.rng().Intn(2) == 0 {
+			n = edges[oc.rng().Intn(len(edges))]
+		} else if span == 0 {
+			n = int(oc.rng().Uint64())
+		} else if span <= math.MaxInt64 {
+			// get a number from 0 to span, and add the lowerbound to it
+			n = int(uint64(min) + uint64(oc.rng().Int63n(int64(span))))
+		} else {
+			n = int(uint64(min) + oc.rng().Uint64()%span)
+		}
+		if snap > 0 {
+			// Snapping can take a value near the edge of the range out of it
+			if n = snapInt(n, snap); n < min || n > top {
+				return false
+			}
+		}
+		for _, e := range exclude {
+			if n == e {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// store it in the cache
+	ca := oc["int"]
+	cache := ca.([]int)
+	oc["int"] = append(cache, n)
+
+	return localizeNumber(strconv.Itoa(n), opts), nil
+}
+
+// intBound returns the min or max of an {int}, which is either given in the template or
+// refers to an earlier {int}, so that one value can be kept above another
+func (oc objectCache) intBound(opts cmdOptions, key string) (int, error) {
+	if !isRef(opts[key]) {
+		return opts.getInt(key)
+	}
+	v, err := oc.lookupRef(opts[key])
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// floatBound returns the min or max of a {float}, which is either given in the template
+// or refers to an earlier {int} or {float}
+func (oc objectCache) floatBound(opts cmdOptions, key string) (float64, error) {
+	if !isRef(opts[key]) {
+		return opts.getFloat(key)
+	}
+	v, err := oc.lookupRef(opts[key])
+	if err != nil {
+		return 0, err
+	}
+	if i, ok := v.(int); ok {
+		return float64(i), nil
+	}
+	return v.(float64), nil
+}
+
+// excludesMax reports whether max is left out of a range, which it is unless
+// maxInclusive:true is given. A range whose min and max are the same, which single says,
+// only has the one value in it, so that's generated either way.
+func excludesMax(opts cmdOptions, single bool) bool {
+	return opts["maxInclusive"] != "true" && !single
+}
+
+// validateIntRange checks that there is at least one integer from min to max
+func validateIntRange(min int, max int, opts cmdOptions) error {
+	inclusive := opts["maxInclusive"]
+	if inclusive != "true" && inclusive != "false" {
+		return InvalidArgumentError(fmt.Sprintf("maxInclusive: %s is not one of true or false", inclusive))
+	}
+	if min > max {
+		return InvalidArgumentError("You cannot generate a random number whose lower bound is greater than it's upper bound. Please check your input string")
+	}
+	return nil
+}
+
+func validateInt(opts cmdOptions) error {
+	if err := validateSeparators(opts, false); err != nil {
+		return err
+	}
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil
+	}
+	// A bound which refers to an earlier token isn't known until that token generates
+	// it's value, so check everything else as though it could be anything
+	min, max := math.MinInt, math.MaxInt
+	var err error
+	if !isRef(opts["min"]) {
+		if min, err = opts.getInt("min"); err != nil {
+			return InvalidArgumentError(fmt.Sprintf("min: %s is not an integer", opts["min"]))
+		}
+	}
+	if !isRef(opts["max"]) {
+		if max, err = opts.getInt("max"); err != nil {
+			return InvalidArgumentError(fmt.Sprintf("max: %s is not an integer", opts["max"]))
+		}
+	}
+	if err := validateIntRange(min, max, opts); err != nil {
+		return err
+	}
+	if opts["snap"] == "" {
+		return nil
+	}
+	snap, err := opts.getInt("snap")
+	if err != nil || snap < 1 {
+		return InvalidArgumentError(fmt.Sprintf("snap: %s is not an integer >= 1", opts["snap"]))
+	}
+	top := max
+	if excludesMax(opts, min == max) {
+		top = max - 1
+	}
+	// The first multiple of snap which isn't below min must not be above the top of the
+	// range, or nothing could ever be generated
+	if r := ((min % snap) + snap) % snap; r != 0 && (min > math.MaxInt-(snap-r) || min+(snap-r) > top) {
+		return InvalidArgumentError(fmt.Sprintf("snap: There is no multiple of %d from %d to %d. Please check your input string", snap, min, top))
+	}
+	return nil
+}
+
+// snapInt rounds n to the nearest multiple of snap, with half way rounding up. If the
+// nearest multiple would overflow, the one on the other side is used instead.
+func snapInt(n int, snap int) int {
+	r := ((n % snap) + snap) % snap
+	if r == 0 {
+		return n
+	}
+	canUp := n <= math.MaxInt-(snap-r)
+	canDown := n >= math.MinInt+r
+	if (2*r >= snap && canUp) || !canDown {
+		return n + (snap - r)
+	}
+	return n - r
+}
+
+func expandInt(given cmdOptions) (cmdOptions, error) {
+	expanded := cmdOptions{}
+	if p, ok := given["preset"]; ok {
+		preset, err := lookupIntPreset(p)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range preset {
+			expanded[k] = v
+		}
+	}
+	// A type in the template takes precedence over everything in the preset, while a
+	// type in the preset gives way to the preset's own min and max
+	t, explicit := given["type"]
+	if !explicit {
+		t = expanded["type"]
+	}
+	if t == "" {
+		return expanded, nil
+	}
+	bounds, ok := intTypes[t]
+	if !ok {
+		return nil, InvalidArgumentError(fmt.Sprintf("type: %s is not a supported integer type", t))
+	}
+	for k, v := range bounds {
+		if _, set := expanded[k]; explicit || !set {
+			expanded[k] = v
+		}
+	}
+	return expanded, nil
+}
+
+var (
+	intPresets = map[string]cmdOptions{
+		// id looks like the primary key of a table that's been in use for a while
+		"id": cmdOptions{"min": "1000", "max": "9999999"},
+	}
+	intPresetsMu sync.RWMutex
+)
+
+// RegisterIntPreset makes a set of options available to the {int} token under the given
+// name, replacing any preset already registered with that name, including the built in
+// id preset. This is useful for ranges which are repeated across templates. Options given
+// explicitly in a template take precedence over those from the preset. The options are
+// copied, so the map can be reused.
+func RegisterIntPreset(name string, options map[string]string) error {
+	if len(options) == 0 {
+		return InvalidArgumentError(fmt.Sprintf("The int preset %s must contain at least one option", name))
+	}
+	o := make(cmdOptions, len(options))
+	for k, v := range options {
+		if k == "preset" || k == "ordinal" {
+			return InvalidArgumentError(fmt.Sprintf("The int preset %s can't set %s", name, k))
+		}
+		o[k] = v
+	}
+	if err := checkOptions("int", o); err != nil {
+		return err
+	}
+	if t, ok := o["type"]; ok {
+		if _, ok := intTypes[t]; !ok {
+			return InvalidArgumentError(fmt.Sprintf("type: %s is not a supported integer type", t))
+		}
+	}
+	intPresetsMu.Lock()
+	defer intPresetsMu.Unlock()
+	intPresets[name] = o
+	return nil
+}
+
+// lookupIntPreset returns the options registered under the given name
+func lookupIntPreset(name string) (cmdOptions, error) {
+	intPresetsMu.RLock()
+	defer intPresetsMu.RUnlock()
+	preset, ok := intPresets[name]
+	if !ok {
+		return nil, InvalidArgumentError(fmt.Sprintf("preset: %s is not a registered int preset. Please check your input string", name))
+	}
+	return preset, nil
+}
+
+// expandFloat leaves an exponential distribution unbounded above, unless it's given a
+// max, as the default max would clamp it's long tail
+func expandFloat(given cmdOptions) (cmdOptions, error) {
+	if given["dist"] == "exponential" {
+		return cmdOptions{"max": "+Inf"}, nil
+	}
+	return nil, nil
+}
+
+func float(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["float"]
+		cache := c.([]float64)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for floats. Please check your input string", ord))
+		}
+		n := cache[ord]
+		s, err := formatFloat(n, opts)
+		if err != nil {
+			return "", err
+		}
+		return localizeNumber(s, opts), nil
+	}
+
+	min, err := oc.floatBound(opts, "min")
+	if err != nil {
+		return "", err
+	}
+	max, err := oc.floatBound(opts, "max")
+	if err != nil {
+		return "", err
+	}
+	if err := validateFloatRange(min, max, opts); err != nil {
+		return "", err
+	}
+
+	// get the difference between them
+	diff := max - min
+	exponential := opts["dist"] == "exponential"
+	rate, err := opts.getFloat("rate")
+	if err != nil {
+		return "", err
+	}
+	snap, err := opts.getFloat("snap")
+	if err != nil && opts["snap"] != "" {
+		return "", err
+	}
+	var n float64
+	var s string
+	var ferr error
+	// Keep rolling until the number has the sign that was asked for, if any
+	err = reroll("a float with the sign "+opts["sign"], func() bool {
+		// get a fraction of the way from min to max, which can be all of the way there
+		// when max is inclusive
+		if exponential {
+			// ExpFloat64 has a rate of 1, so scale it to the one asked for, and clamp it
+			// to the range
+			hi := max
+			if excludesMax(opts, min == max) {
+				hi = math.Nextafter(max, min)
+			}
+			n = math.Min(math.Max(oc.rng().ExpFloat64()/rate, min), hi)
+		} else {
+			var frac float64
+			if excludesMax(opts, min == max) {
+				frac = oc.rng().Float64()
+			} else {
+				frac = float64(oc.rng().Int63n(1<<53+1)) / (1 << 53)
+			}
+			n = min + frac*diff
+		}
+		if snap > 0 {
+			// Snapping can take a value near the edge of the range out of it
+			n = snapFloat(n, snap)
+			if n < min || n > max || (n == max && excludesMax(opts, min == max)) {
+				return false
+			}
+		}
+		s, ferr = formatFloat(n, opts)
+		return ferr != nil || hasSign(s, opts["sign"])
+	})
+	if ferr != nil {
+		return "", ferr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// store it in the cache
+	ca := oc["float"]
+	cache := ca.([]float64)
+	oc["float"] = append(cache, n)
+
+	return localizeNumber(s, opts), nil
+}
+
+// validateFloatRange checks that there is at least one number from min to max
+func validateFloatRange(min float64, max float64, opts cmdOptions) error {
+	inclusive := opts["maxInclusive"]
+	if inclusive != "true" && inclusive != "false" {
+		return InvalidArgumentError(fmt.Sprintf("maxInclusive: %s is not one of true or false", inclusive))
+	}
+	if min > max {
+		return InvalidArgumentError("You cannot generate a random number whose lower bound is greater than it's upper bound. Please check your input string")
+	}
+	return nil
+}
+
+func validateFloat(opts cmdOptions) error {
+	if f := opts["format"]; f != "decimal" && f != "scientific" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of decimal or scientific", f))
+	}
+	p, err := opts.getInt("precision")
+	if err != nil || p < 0 {
+		return InvalidArgumentError(fmt.Sprintf("precision: %s is not an integer >= 0", opts["precision"]))
+	}
+	// Only a float written with a precision has a decimal part
+	if err := validateSeparators(opts, p > 0); err != nil {
+		return err
+	}
+	switch r := opts["round"]; r {
+	case "", "halfup", "halfeven", "truncate":
+	default:
+		return InvalidArgumentError(fmt.Sprintf("round: %s is not one of halfup, halfeven or truncate", r))
+	}
+	if d := opts["dist"]; d != "uniform" && d != "exponential" {
+		return InvalidArgumentError(fmt.Sprintf("dist: %s is not one of uniform or exponential", d))
+	}
+	if rate, err := opts.getFloat("rate"); err != nil || !(rate > 0) || math.IsInf(rate, 1) {
+		return InvalidArgumentError(fmt.Sprintf("rate: %s is not a number > 0", opts["rate"]))
+	}
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil
+	}
+	// As with {int}, a bound which refers to an earlier token could be anything
+	min, max := math.Inf(-1), math.Inf(1)
+	if !isRef(opts["min"]) {
+		if min, err = opts.getFloat("min"); err != nil {
+			return InvalidArgumentError(fmt.Sprintf("min: %s is not a number", opts["min"]))
+		}
+	}
+	if !isRef(opts["max"]) {
+		if max, err = opts.getFloat("max"); err != nil {
+			return InvalidArgumentError(fmt.Sprintf("max: %s is not a number", opts["max"]))
+		}
+	}
+	if err := validateFloatRange(min, max, opts); err != nil {
+		return err
+	}
+	if err := validateSnap(min, max, opts); err != nil {
+		return err
+	}
+	switch s := opts["sign"]; s {
+	case "any":
+	case "positive":
+		if max <= 0 {
+			return InvalidArgumentError("You cannot generate a positive number whose upper bound is not greater than zero. Please check your input string")
+		}
+	case "negative":
+		if min >= 0 {
+			return InvalidArgumentError("You cannot generate a negative number whose lower bound is not less than zero. Please check your input string")
+		}
+	case "nonzero":
+		if min == 0 && max == 0 {
+			return InvalidArgumentError("You cannot generate a non-zero number between 0 and 0. Please check your input string")
+		}
+	default:
+		return InvalidArgumentError(fmt.Sprintf("sign: %s is not one of any, positive, negative or nonzero", s))
+	}
+	return nil
+}
+
+// validateSnap checks the snap option of a {float}. The grid must be one which the
+// precision can write out exactly, so that a value snapped to 0.05 is never written
+// as 0.1, and at least one point on it must fall in the range.
+func validateSnap(min float64, max float64, opts cmdOptions) error {
+	if opts["snap"] == "" {
+		return nil
+	}
+	snap, err := opts.getFloat("snap")
+	if err != nil || !(snap > 0) || math.IsInf(snap, 1) {
+		return InvalidArgumentError(fmt.Sprintf("snap: %s is not a number > 0", opts["snap"]))
+	}
+	if precision, _ := opts.getInt("precision"); opts["format"] == "decimal" && precision < snapDecimals(snap) {
+		return InvalidArgumentError(fmt.Sprintf("snap: %s needs a precision of at least %d to be written out exactly", opts["snap"], snapDecimals(snap)))
+	}
+	lo := snapFloat(math.Ceil(min/snap)*snap, snap)
+	if lo > max || (lo == max && excludesMax(opts, min == max)) {
+		return InvalidArgumentError(fmt.Sprintf("snap: There is no multiple of %s from %s to %s. Please check your input string", opts["snap"], opts["min"], opts["max"]))
+	}
+	return nil
+}
+
+// snapDecimals is how many digits after the decimal point the points on a grid of the
+// given size need when they're written out
+func snapDecimals(snap float64) int {
+	s := strconv.FormatFloat(snap, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// snapFloat rounds n to the nearest multiple of snap. Multiplying back out leaves noise
+// such as 0.35000000000000003, so the result is cleaned up to the digits the grid has.
+func snapFloat(n float64, snap float64) float64 {
+	v := math.Round(n/snap) * snap
+	clean, err := strconv.ParseFloat(strconv.FormatFloat(v, 'f', snapDecimals(snap), 64), 64)
+	if err != nil {
+		return v
+	}
+	return clean
+}
+
+// hasSign reports whether n, as it was written out, satisfies the sign option. The
+// written value is checked so that rounding can't turn 0.0000001 into 0.000000.
+func hasSign(written string, sign string) bool {
+	n, err := strconv.ParseFloat(written, 64)
+	if err != nil {
+		return false
+	}
+	switch sign {
+	case "positive":
+		return n > 0
+	case "negative":
+		return n < 0
+	case "nonzero":
+		return n != 0
+	}
+	return true
+}
+
+// validateSeparators checks the decimalsep and groupsep options of a number. Neither
+// can have digits in them, and when the number is written with a decimal part they
+// can't be the same, or the number couldn't be read back. {int} never writes one, so
+// it's groupsep can be anything without digits, even "."
+func validateSeparators(opts cmdOptions, decimal bool) error {
+	dec, hasDec := opts["decimalsep"]
+	if hasDec && dec == "" {
+		return InvalidArgumentError("decimalsep: The decimal separator can't be empty. Please check your input string")
+	}
+	if !hasDec {
+		dec = "."
+	}
+	for _, sep := range []string{dec, opts["groupsep"]} {
+		if strings.ContainsAny(sep, "0123456789") {
+			return InvalidArgumentError(fmt.Sprintf("The separator %s can't have digits in it. Please check your input string", sep))
+		}
+	}
+	if decimal && opts["groupsep"] == dec {
+		return InvalidArgumentError(fmt.Sprintf("groupsep: %s is the same as the decimal separator, so the number couldn't be read back. Please check your input string", dec))
+	}
+	return nil
+}
+
+// localizeNumber rewrites a number written out by strconv with the decimalsep and
+// groupsep options, putting groupsep between each group of 3 digits before the decimal
+// point. Only the digits before the exponent of a number in scientific notation change.
+func localizeNumber(s string, opts cmdOptions) string {
+	dec, group := opts["decimalsep"], opts["groupsep"]
+	if (dec == "" || dec == ".") && group == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	exp := ""
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		s, exp = s[:i], s[i:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if group != "" {
+		groups := make([]string, 0, len(whole)/3+1)
+		for len(whole) > 3 {
+			groups = append([]string{whole[len(whole)-3:]}, groups...)
+			whole = whole[:len(whole)-3]
+		}
+		whole = strings.Join(append([]string{whole}, groups...), group)
+	}
+	if frac != "" {
+		if dec == "" {
+			dec = "."
+		}
+		whole += dec + frac
+	}
+	return sign + whole + exp
+}
+
+// formatFloat writes n out per the format and precision options. Ordinal references
+// are formatted with their own options, so a value can be repeated in another form.
+func formatFloat(n float64, opts cmdOptions) (string, error) {
+	precision, err := opts.getInt("precision")
+	if err != nil {
+		return "", err
+	}
+	fmtByte := byte('f')
+	if opts["format"] == "scientific" {
+		fmtByte = 'e'
+	}
+	if opts["round"] == "" {
+		return strconv.FormatFloat(n, fmtByte, precision, 64), nil
+	}
+	return roundFloat(n, fmtByte, precision, opts["round"]), nil
+}
+
+// roundFloat writes n out to precision digits after the decimal point, rounding it with
+// the given mode. Unlike strconv, which rounds the exact binary value of n, it rounds the
+// shortest decimal that reads back as n, so 2.675 rounds half up to 2.68 rather than 2.67.
+func roundFloat(n float64, fmtByte byte, precision int, mode string) string {
+	sign := ""
+	if math.Signbit(n) {
+		sign = "-"
+	}
+	s := strconv.FormatFloat(math.Abs(n), fmtByte, -1, 64)
+	exp := ""
+	if fmtByte == 'e' {
+		i := strings.IndexByte(s, 'e')
+		s, exp = s[:i], s[i+1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	for len(frac) < precision {
+		frac += "0"
+	}
+	digits, rest := []byte(whole+frac[:precision]), frac[precision:]
+
+	up := false
+	if rest != "" {
+		switch mode {
+		case "halfup":
+			up = rest[0] >= '5'
+		case "halfeven":
+			// Exactly half way rounds to whichever neighbour is even
+			up = rest[0] > '5' || (rest[0] == '5' && (strings.Trim(rest[1:], "0") != "" || (digits[len(digits)-1]-'0')%2 == 1))
+		}
+	}
+	if up {
+		i := len(digits) - 1
+		for ; i >= 0 && digits[i] == '9'; i-- {
+			digits[i] = '0'
+		}
+		if i >= 0 {
+			digits[i]++
+		} else {
+			digits = append([]byte{'1'}, digits...)
+		}
+	}
+	wholeLen := len(digits) - precision
+	if fmtByte == 'e' {
+		e, _ := strconv.Atoi(exp)
+		// Rounding 9.99e+01 up makes 10.00e+01, which moves the point along one
+		if wholeLen > 1 {
+			e++
+			digits = digits[:len(digits)-1]
+			wholeLen = 1
+		}
+		expSign := "+"
+		if e < 0 {
+			expSign, e = "-", -e
+		}
+		exp = fmt.Sprintf("e%s%02d", expSign, e)
+	}
+	result := string(digits[:wholeLen])
+	if precision > 0 {
+		result += "." + string(digits[wholeLen:])
+	}
+	return sign + result + exp
+}
+
+// countryPool returns the codes that {country} can pick from once any have been
+// excluded. When weighted by population, it also returns the running total of their
+// populations, and leaves out any codes without a population.
+func countryPool(opts cmdOptions) (countryTable, error) {
+	exclude := make(map[string]bool)
+	if opts["exclude"] != "" {
+		for _, c := range strings.Split(opts["exclude"], ",") {
+			c = strings.ToUpper(strings.TrimSpace(c))
+			found := false
+			for _, code := range CountryCodes {
+				found = found || c == code
+			}
+			if !found {
+				return countryTable{}, InvalidArgumentError(fmt.Sprintf("exclude: %s is not a known country code", c))
+			}
+			exclude[c] = true
+		}
+	}
+	weighted := opts["weight"] == "population"
+	pool := make([]string, 0, len(CountryCodes))
+	sums := make([]int, 0, len(CountryCodes))
+	total := 0
+	for _, c := range CountryCodes {
+		if exclude[c] || (weighted && CountryPopulations[c] == 0) {
+			continue
+		}
+		total += CountryPopulations[c]
+		sums = append(sums, total)
+		pool = append(pool, c)
+	}
+	if len(pool) == 0 {
+		return countryTable{}, InvalidArgumentError("exclude: Every country has been excluded, so there are none left to pick from. Please check your input string")
+	}
+	return countryTable{pool: pool, sums: sums}, nil
+}
+
+// countryTable is what a {country} picks from, worked out once when the template is
+// parsed: the codes left after any are excluded, and the running total of their
+// populations
+type countryTable struct {
+	pool []string
+	sums []int
+}
+
+// prepareCountry builds the pool of countries a {country} picks from, once when the
+// template is parsed rather than for every result
+func prepareCountry(opts cmdOptions) (interface{}, error) {
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil, nil
+	}
+	return countryPool(opts)
+}
+
+// countryValue is a generated country code along with how it was written out, so that
+// an ordinal can either repeat it exactly or write the same country out another way,
+// and other tokens can refer to the code no matter how it was written
+type countryValue struct {
+	code      string
+	formatted string
+}
+
+// formatCountry writes out a country code in the named format, which is either the code
+// itself, or it's flag
+func formatCountry(code string, format string) string {
+	if format != "flag" {
+		return code
+	}
+	// A flag is the pair of regional indicator symbols for the letters of the code
+	flag := make([]rune, 0, 2)
+	for _, c := range strings.ToUpper(code) {
+		flag = append(flag, 0x1F1E6+c-'A')
+	}
+	return string(flag)
+}
+
+func validateCountry(opts cmdOptions) error {
+	if w := opts["weight"]; w != "uniform" && w != "population" {
+		return InvalidArgumentError(fmt.Sprintf("weight: %s is not one of uniform or population", w))
+	}
+	if f, ok := opts["format"]; ok && f != "alpha2" && f != "flag" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of alpha2 or flag", f))
+	}
+	return nil
+}
+
+func country(oc objectCache, opts cmdOptions, prep interface{}) (string, error) {
+	cCase := opts["case"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["country"]
+		cache := c.([]countryValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for countries. Please check your input string", ord))
+		}
+		// Countries go into the cache as they were generated, before any case was
+		// applied, and are only re-formatted or re-cased on request
+		if format, ok := opts["format"]; ok {
+			return applyCase(formatCountry(cache[ord].code, format), cCase), nil
+		}
+		return applyCase(cache[ord].formatted, cCase), nil
+	}
+	// Generate a new one
+	table := prep.(countryTable)
+	pool, sums := table.pool, table.sums
+	n := 0
+	if opts["weight"] == "population" {
+		// Find the country whose share of the cumulative population the roll lands in
+		r := oc.rng().Intn(sums[len(sums)-1])
+		n = sort.Search(len(sums), func(i int) bool {
+			return sums[i] > r
+		})
+	} else {
+		n = oc.rng().Intn(len(pool))
+	}
+	country := formatCountry(pool[n], opts["format"])
+	// store it in the cache
+	ca := oc["country"]
+	cache := ca.([]countryValue)
+	oc["country"] = append(cache, countryValue{code: pool[n], formatted: country})
+
+	return applyCase(country, cCase), nil
+}
+
+// applyCase will change the case of s when cCase is "up" or "down", and otherwise
+// leave s exactly as it is
+func applyCase(s string, cCase string) string {
+	switch cCase {
+	case "up":
+		return strings.ToUpper(s)
+	case "down":
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// normalForms are the unicode normalization forms that {unicode} can apply
+var normalForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+func validateUnicode(opts cmdOptions) error {
+	if f, ok := opts["normalize"]; ok {
+		if _, ok := normalForms[f]; !ok {
+			return InvalidArgumentError(fmt.Sprintf("normalize: %s is not one of NFC, NFD, NFKC or NFKD", f))
+		}
+	}
+	if _, err := opts.getRuneSet("exclude"); err != nil {
+		return err
+	}
+	return validateRequire(opts)
+}
+
+// requiredClasses are the classes of character that {unicode} and {ascii} can be made to
+// include at least one of, and the characters which are put in to make sure they do
+var requiredClasses = map[string]string{
+	"digit": "0123456789",
+	"lower": "abcdefghijklmnopqrstuvwxyz",
+	"upper": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+func validateRequire(opts cmdOptions) error {
+	if opts["require"] == "" {
+		return nil
+	}
+	if opts["ordinal"] != "-1" {
+		return InvalidArgumentError("require: can't be given with an ordinal, which repeats a string already generated")
+	}
+	classes := strings.Split(opts["require"], ",")
+	exclude, err := opts.getRuneSet("exclude")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, c := range classes {
+		chars, ok := requiredClasses[c]
+		if !ok {
+			return InvalidArgumentError(fmt.Sprintf("require: %s is not one of digit, lower or upper", c))
+		} else if seen[c] {
+			return InvalidArgumentError(fmt.Sprintf("require: %s is given more than once", c))
+		} else if (c == "lower" && opts["case"] == "up") || (c == "upper" && opts["case"] == "down") {
+			return InvalidArgumentError(fmt.Sprintf("require: %s can't be met with case:%s", c, opts["case"]))
+		} else if len(allowedRunes(chars, exclude)) == 0 {
+			return InvalidArgumentError(fmt.Sprintf("require: every %s character is excluded", c))
+		}
+		seen[c] = true
+	}
+	if n, err := opts.getInt("length"); err == nil && n < len(classes) {
+		return InvalidArgumentError(fmt.Sprintf("require: %d classes of character can't fit in a length of %d", len(classes), n))
+	}
+	return nil
+}
+
+// allowedRunes returns the characters of chars which are not in exclude
+func allowedRunes(chars string, exclude map[rune]bool) []rune {
+	allowed := make([]rune, 0, len(chars))
+	for _, r := range chars {
+		if !exclude[r] {
+			allowed = append(allowed, r)
+		}
+	}
+	return allowed
+}
+
+// requireClasses makes sure s has at least one character of each of the comma separated
+// classes in require. Rather than rerolling all of s until it happens to, which could
+// take a long time for short strings, it overwrites one character for each class, at
+// positions picked at random so the required characters don't always lead.
+func requireClasses(r *rand.Rand, s string, require string, exclude map[rune]bool) string {
+	if require == "" {
+		return s
+	}
+	runes := []rune(s)
+	positions := r.Perm(len(runes))
+	for i, c := range strings.Split(require, ",") {
+		allowed := allowedRunes(requiredClasses[c], exclude)
+		runes[positions[i]] = allowed[r.Intn(len(allowed))]
+	}
+	return string(runes)
+}
+
+// normalize applies the given unicode normalization form to s, or leaves s exactly as
+// it is if no form is given
+func normalize(s string, form string) string {
+	if f, ok := normalForms[form]; ok {
+		return f.String(s)
+	}
+	return s
+}
+
+func unicode(oc objectCache, opts cmdOptions) (string, error) {
+	cCase := opts["case"]
+	num, err := opts.getInt("length")
+	if err != nil {
+		return "", err
+	} else if num <= 0 {
+		return "", InvalidArgumentError("You have specified a number of characters to generate which is not a number greater than zero. Please check your input string")
+	}
+	// Every character takes at least a byte
+	if err := oc.checkLimit(num); err != nil {
+		return "", err
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["unicode"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for unicode strings. Please check your input string", ord))
+		}
+		// Strings go into the cache before any case was applied, and are only re-cased
+		// or normalized on request
+		return normalize(applyCase(cache[ord], cCase), opts["normalize"]), nil
+	}
+
+	exclude, err := opts.getRuneSet("exclude")
+	if err != nil {
+		return "", err
+	}
+	s, err := generateRandomString(oc.rng(), num, exclude)
+	if err != nil {
+		return "", err
+	}
+	result := normalize(requireClasses(oc.rng(), s, opts["require"], exclude), opts["normalize"])
+	// store it in the cache
+	ca := oc["unicode"]
+	cache := ca.([]string)
+	oc["unicode"] = append(cache, result)
+	return normalize(applyCase(result, cCase), opts["normalize"]), nil
+}
+
+func ascii(oc objectCache, opts cmdOptions) (string, error) {
+	cCase := opts["case"]
+	num, err := opts.getInt("length")
+	if err != nil {
+		return "", err
+	} else if num <= 0 {
+		return "", InvalidArgumentError("You have specified a number of characters to generate which is not a number greater than zero. Please check your input string")
+	}
+	// Every character takes at least a byte
+	if err := oc.checkLimit(num); err != nil {
+		return "", err
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["ascii"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for ASCII strings. Please check your input string", ord))
+		}
+		// Strings go into the cache before any case was applied, and are only re-cased
+		// on request
+		return applyCase(cache[ord], cCase), nil
+	}
+
+	result := requireClasses(oc.rng(), generateRandomASCIIString(oc.rng(), num), opts["require"], nil)
+	// store it in the cache
+	ca := oc["ascii"]
+	cache := ca.([]string)
+	oc["ascii"] = append(cache, result)
+	return applyCase(result, cCase), nil
+}
+
+func generateRandomASCIIString(r *rand.Rand, length int) string {
+	// This also includes numbers which is questionable, however since when folks want to
+	// work with ascii strings, they anticipate 0-9 as well. Open to changing this if need be.
+	var letters = []rune("0123456789abcdefghijklmnopqrstuvwxy")
+
+	b := make([]rune, length)
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// generateRandomString returns length random characters from PrintableRanges, leaving
+// out any in exclude
+func generateRandomString(r *rand.Rand, length int, exclude map[rune]bool) (string, error) {
+	rarr := make([]rune, length)
+	for i := 0; i < length; i++ {
+		err := reroll("a unicode character which is not excluded", func() bool {
+			// First, pick which range this character comes from
+			span := PrintableRanges[r.Intn(len(PrintableRanges))]
+
+			minCharCode := span[0]
+			maxCharCode := span[1]
+
+			// Get the delata between max and min
+			diff := maxCharCode - minCharCode
+			// Get a random value within the range specified
+			num := r.Intn(diff) + minCharCode
+			// Turn it into a rune, set it on the result object
+			rarr[i] = rune(num)
+			return !exclude[rarr[i]]
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(rarr), nil
+}
+
+func now(oc objectCache, opts cmdOptions) (string, error) {
+	loc, err := time.LoadLocation(opts["zone"])
+	if err != nil {
+		return "", err
+	}
+
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+	if ord >= 0 {
+		c := oc["now"]
+		cache := c.([]timeValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for time-now. Please check your input string", ord))
+		}
+		return formatTimeOrdinal(cache[ord], loc, opts), nil
+	}
+	now := oc["clock"].(time.Time).In(loc)
+	ts := formatTime(&now, opts["format"])
+
+	// store it in the cache
+	c := oc["now"]
+	cache := c.([]timeValue)
+	oc["now"] = append(cache, timeValue{t: now, formatted: ts})
+	return ts, nil
+}
+
+// afterBounds returns the min and max of a {time} that comes after another, which are
+// durations rather than unix epoch values
+func afterBounds(opts cmdOptions) (time.Duration, time.Duration, error) {
+	min, err := time.ParseDuration(opts["min"])
+	if err != nil {
+		return 0, 0, InvalidArgumentError(fmt.Sprintf("min: %s is not a duration such as 1h30m, which it must be when after is given", opts["min"]))
+	}
+	max, err := time.ParseDuration(opts["max"])
+	if err != nil {
+		return 0, 0, InvalidArgumentError(fmt.Sprintf("max: %s is not a duration such as 24h, which it must be when after is given", opts["max"]))
+	}
+	if min < 0 || max < 0 {
+		return 0, 0, InvalidArgumentError("You cannot generate a random time after another by a negative duration. Please check your input string")
+	}
+	if min > max {
+		return 0, 0, InvalidArgumentError("You cannot generate a random time whose lower bound is greater than it's upper bound. Please check your input string")
+	}
+	return min, max, nil
+}
+
+// timeSkip is the days that a {time} must not fall on
+type timeSkip struct {
+	weekends bool
+	// holidays holds each holiday as YYYY-MM-DD
+	holidays map[string]bool
+}
+
+// skips reports whether t falls on a skipped day, in t's own zone
+func (s timeSkip) skips(t time.Time) bool {
+	if s.weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return true
+	}
+	return s.holidays[t.Format("2006-01-02")]
+}
+
+// parseSkip reads the skip and holidays options of a {time}
+func parseSkip(opts cmdOptions) (timeSkip, error) {
+	s := timeSkip{holidays: make(map[string]bool)}
+	if opts["skip"] == "" {
+		return s, nil
+	}
+	for _, k := range strings.Split(opts["skip"], ",") {
+		switch k {
+		case "weekends":
+			s.weekends = true
+		case "holidays":
+			if opts["holidays"] == "" {
+				return s, InvalidArgumentError("holidays: You must provide a list of holidays, such as 2024-12-25,2025-01-01, to skip holidays. Please check your input string")
+			}
+			for _, h := range strings.Split(opts["holidays"], ",") {
+				d, err := time.Parse("2006-01-02", h)
+				if err != nil {
+					return s, InvalidArgumentError(fmt.Sprintf("holidays: %s is not a date such as 2024-12-25", h))
+				}
+				s.holidays[d.Format("2006-01-02")] = true
+			}
+		default:
+			return s, InvalidArgumentError(fmt.Sprintf("skip: %s is not one of weekends or holidays", k))
+		}
+	}
+	return s, nil
+}
+
+func validateTime(opts cmdOptions) error {
+	if err := validateTimeFormat(opts); err != nil {
+		return err
+	}
+	if _, err := parseSkip(opts); err != nil {
+		return err
+	}
+	if s := opts["sorted"]; s != "" {
+		if s != "asc" && s != "desc" {
+			return InvalidArgumentError(fmt.Sprintf("sorted: %s is not one of asc or desc", s))
+		}
+		if opts["after"] != "" || opts["ordinal"] != "-1" || opts["unique"] == "true" {
+			return InvalidArgumentError("sorted: A sorted {time} can't be given after, ordinal or unique. Please check your input string")
+		}
+	}
+	if a := opts["after"]; a == "" {
+		return nil
+	} else if !isRef(a) {
+		return InvalidArgumentError(fmt.Sprintf("after: %s must refer to an earlier {time} or {now} token, such as @0. Please check your input string", a))
+	}
+	_, _, err := afterBounds(opts)
+	return err
+}
+
+func datetime(oc objectCache, opts cmdOptions) (string, error) {
+	after := opts["after"]
+	var min, max int
+	var err error
+	if after == "" {
+		min, err = opts.getInt("min")
+		if err != nil {
+			return "", err
+		}
+		max, err = opts.getInt("max")
+		if err != nil {
+			return "", err
+		}
+		if min > max {
+			return "", InvalidArgumentError("You cannot generate a random time whose lower bound is greater than it's upper bound. Please check your input string")
+		}
+	}
+
+	z := opts["zone"]
+	loc, err := time.LoadLocation(z)
+	if err != nil {
+		return "", err
+	}
+
+	f := opts["format"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+	if ord >= 0 {
+		c := oc["time"]
+		cache := c.([]timeValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for time-now. Please check your input string", ord))
+		}
+		return formatTimeOrdinal(cache[ord], loc, opts), nil
+	}
+	var base time.Time
+	var dmin, dmax time.Duration
+	if after != "" {
+		v, err := oc.lookupRef(after)
+		if err != nil {
+			return "", err
+		}
+		base = v.(timeValue).t
+		dmin, dmax, err = afterBounds(opts)
+		if err != nil {
+			return "", err
+		}
+	}
+	skip, err := parseSkip(opts)
+	if err != nil {
+		return "", err
+	}
+	var t time.Time
+	pick := func() bool {
+		if after != "" {
+			// Pick a time between min and max after the referenced one
+			delta := dmin
+			if dmax > dmin {
+				delta += time.Duration(oc.rng().Int63n(int64(dmax - dmin)))
+			}
+			t = base.Add(delta).In(loc)
+		} else {
+			// get the difference between them
+			diff := max - min
+			var ut int64
+			// Get a random value from 0 to the delta, and add the minimum
+			// Due to an issue with Int63n, you cannot pass it a 0
+			if diff > 0 {
+				ut = oc.rng().Int63n(int64(diff)) + int64(min)
+			} else {
+				ut = int64(min)
+			}
+			// Get the time at that value
+			t = time.Unix(ut, 0).In(loc)
+		}
+		return !skip.skips(t)
+	}
+	if s := opts["sorted"]; s != "" {
+		t, err = oc.sortedTime(s == "desc", func() (time.Time, error) {
+			err := reroll("a time which is not skipped", pick)
+			return t, err
+		})
+	} else {
+		err = reroll("a time which is not skipped", pick)
+	}
+	if err != nil {
+		return "", err
+	}
+	ts := formatTime(&t, f)
+	// store it in the cache
+	c := oc["time"]
+	cache := c.([]timeValue)
+	oc["time"] = append(cache, timeValue{t: t, formatted: ts})
+
+	return ts, nil
+}
+
+// sortedTimes are the times handed out to each sorted {time} in the body of a {repeat},
+// one per iteration, keyed by where the token's value goes in it's cache
+type sortedTimes struct {
+	count int
+	times map[int][]time.Time
+}
+
+// sortedTime hands out the next time for a sorted {time}. The first time through the
+// {repeat}, as many times are picked as there are iterations, and sorted, so that they
+// are spread out across the whole of the token's range the way that random times would
+// be, rather than each one being squeezed in after the last.
+func (oc objectCache) sortedTime(desc bool, pick func() (time.Time, error)) (time.Time, error) {
+	sorted, ok := oc["sortedtimes"].(*sortedTimes)
+	if !ok {
+		return time.Time{}, InvalidArgumentError("sorted: A {time} can only be sorted across the iterations of the {repeat} it is directly inside of. Please check your input string")
+	}
+	slot := len(oc["time"].([]timeValue))
+	times, ok := sorted.times[slot]
+	if !ok {
+		times = make([]time.Time, sorted.count)
+		for i := range times {
+			t, err := pick()
+			if err != nil {
+				return time.Time{}, err
+			}
+			times[i] = t
+		}
+		sort.Slice(times, func(i, j int) bool {
+			if desc {
+				return times[i].After(times[j])
+			}
+			return times[i].Before(times[j])
+		})
+	}
+	sorted.times[slot] = times[1:]
+	return times[0], nil
+}
+
+// Two times which differ in every part a golang layout can write out, so that a layout
+// which writes them the same way has nothing of the reference time in it
+var (
+	layoutSampleA = time.Date(2021, time.March, 4, 17, 6, 7, 891000000, time.UTC)
+	layoutSampleB = time.Date(2019, time.November, 27, 8, 49, 52, 500000000, time.FixedZone("EST", -5*60*60))
+)
+
+// validateTimeFormat checks that the format given to a {time} or {now} is either one of
+// the named formats, or a plausible golang layout. A layout must write out at least
+// part of the time, and be able to read back what it wrote, which catches formats
+// written in another convention, such as YYYY-MM-DD.
+func validateTimeFormat(opts cmdOptions) error {
+	f := opts["format"]
+	if _, ok := TimeFormats[f]; ok || f == "" || f == "unix" || f == "unixms" {
+		return nil
+	}
+	a := layoutSampleA.Format(f)
+	if a == layoutSampleB.Format(f) {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not a golang layout, which is written using the reference time Mon Jan 2 15:04:05 MST 2006, such as 2006-01-02. Please check your input string", f))
+	}
+	if _, err := time.Parse(f, a); err != nil {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not a golang layout which can be read back: %v. Please check your input string", f, err))
+	}
+	return nil
+}
+
+// formatTime writes t out in the named or golang format. No format at all is the same
+// as the simple format.
+func formatTime(t *time.Time, format string) string {
+	switch format {
+	case "":
+		format = "simple"
+	// These aren't layouts, but the number of seconds or milliseconds since the unix epoch
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if f, ok := TimeFormats[format]; ok {
+		return t.Format(f)
+	}
+	return t.Format(format)
+}
+
+// formatTimeOrdinal writes out a referenced time exactly as it was first written,
+// unless the referencing token gives it's own format or zone, in which case the same
+// instant is written out per those options
+func formatTimeOrdinal(v timeValue, loc *time.Location, opts cmdOptions) string {
+	_, reformat := opts["format"]
+	_, rezone := opts["zone"]
+	if !reformat && !rezone {
+		return v.formatted
+	}
+	t := v.t
+	if rezone {
+		t = t.In(loc)
+	}
+	return formatTime(&t, opts["format"])
+}
+
+// guidFormats are the layouts a guid can be written out in, named after the .NET
+// Guid format specifiers, as well as a URN
+var guidFormats = map[string]bool{"n": true, "d": true, "b": true, "p": true, "x": true, "urn": true}
+
+func validateGUID(opts cmdOptions) error {
+	if f, ok := opts["format"]; ok && !guidFormats[strings.ToLower(f)] {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of n, d, b, p, x or urn", f))
+	}
+	if v := opts["version"]; v != "4" && v != "7" {
+		return InvalidArgumentError(fmt.Sprintf("version: %s is not one of 4 or 7", v))
+	}
+	if d, ok := opts["dashes"]; ok && d != "true" && d != "false" {
+		return InvalidArgumentError(fmt.Sprintf("dashes: %s is not one of true or false", d))
+	}
+	if c, ok := opts["case"]; ok && c != "up" && c != "down" {
+		return InvalidArgumentError(fmt.Sprintf("case: %s is not one of up or down", c))
+	}
+	if opts["time"] != "" && opts["version"] != "7" {
+		return InvalidArgumentError("time: Only a version 7 guid has a time in it. Please check your input string")
+	}
+	if p, ok := opts["prefix"]; ok {
+		if opts["version"] != "4" {
+			return InvalidArgumentError("prefix: The first digits of a version 7 guid are it's time, so only a version 4 guid can have a prefix. Please check your input string")
+		}
+		if len(p) < 1 || len(p) > guidPrefixMax {
+			return InvalidArgumentError(fmt.Sprintf("prefix: %s must be from 1 to %d hex digits", p, guidPrefixMax))
+		}
+		if _, err := strconv.ParseUint(p, 16, 64); err != nil {
+			return InvalidArgumentError(fmt.Sprintf("prefix: %s is not made up of hex digits", p))
+		}
+	}
+	return validateIDTime(opts)
+}
+
+// guidPrefixMax is how many hex digits a guid's prefix can have, which are those ahead
+// of it's version
+const guidPrefixMax = 12
+
+// guidDigits pulls the 32 hex digits back out of a guid written in any format
+func guidDigits(g string) string {
+	g = strings.TrimPrefix(g, "urn:uuid:")
+	g = strings.Replace(g, "0x", "", -1)
+	digits := make([]rune, 0, 32)
+	for _, r := range g {
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') {
+			digits = append(digits, r)
+		}
+	}
+	return string(digits)
+}
+
+// formatGUID lays the 32 hex digits of a guid out in the named format
+func formatGUID(h string, format string) string {
+	d := h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+	switch strings.ToLower(format) {
+	case "n":
+		return h
+	case "b":
+		return "{" + d + "}"
+	case "p":
+		return "(" + d + ")"
+	case "urn":
+		return "urn:uuid:" + d
+	case "x":
+		b := make([]string, 8)
+		for i := range b {
+			b[i] = "0x" + h[16+i*2:18+i*2]
+		}
+		return fmt.Sprintf("{0x%s,0x%s,0x%s,{%s}}", h[:8], h[8:12], h[12:16], strings.Join(b, ","))
+	}
+	return d
+}
+
+// layoutGUID lays the 32 hex digits of a guid out using the format, dashes and case
+// options given to a {guid}, where anything left out takes it's default
+func layoutGUID(h string, opts cmdOptions) string {
+	// Only the hex digits change case, so that the 0x and urn:uuid: prefixes don't
+	h = strings.ToLower(h)
+	if opts["case"] == "up" {
+		h = strings.ToUpper(h)
+	}
+	g := formatGUID(h, opts["format"])
+	if opts["dashes"] == "false" {
+		g = strings.Replace(g, "-", "", -1)
+	}
+	return g
+}
+
+// guidValue is a generated guid along with how it was written out. The digits are kept
+// as they were generated, rather than pulled back out of the written guid, so that an
+// ordinal laying it out another way can never differ from the original in anything but
+// layout.
+type guidValue struct {
+	digits    string
+	formatted string
+}
+
+// guidReformats reports whether a {guid} was given any option which changes how it's
+// laid out
+func guidReformats(opts cmdOptions) bool {
+	for _, o := range extraOptions["guid"] {
+		if _, ok := opts[o]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func guid(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+	if ord >= 0 {
+		c := oc["guid"]
+		cache := c.([]guidValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for guids. Please check your input string", ord))
+		}
+		// Guids go into the cache as they were written out. If the reference gives any
+		// of format, dashes or case, the guid is laid out again using only the options
+		// of the reference, rather than mixing them with those of the original.
+		if guidReformats(opts) {
+			return layoutGUID(cache[ord].digits, opts), nil
+		}
+		return cache[ord].formatted, nil
+	}
+
+	var g string
+	if opts["version"] == "7" {
+		ms, pinned, err := idTime(oc, opts)
+		if err != nil {
+			return "", err
+		}
+		g = uuidv7(oc.rng(), oc["guidorder"].(*orderedIDState), ms, pinned)
+	} else {
+		g = uuidv4(oc)
+	}
+	digits := strings.ToLower(guidDigits(g))
+	// A prefix stands in for the first random digits, so that guids can be made to land
+	// in particular shards
+	if p := strings.ToLower(opts["prefix"]); p != "" {
+		digits = p + digits[len(p):]
+	}
+	guid := layoutGUID(digits, opts)
+	// store it in the cache
+	c := oc["guid"]
+	cache := c.([]guidValue)
+	oc["guid"] = append(cache, guidValue{digits: digits, formatted: guid})
+
+	return guid, nil
+}
+
+func firstname(oc objectCache, opts cmdOptions) (string, error) {
+	return name("firstname", FirstNames, oc, opts)
+}
+
+func lastname(oc objectCache, opts cmdOptions) (string, error) {
+	return name("lastname", LastNames, oc, opts)
+}
+
+func name(nameType string, names []*Name, oc objectCache, opts cmdOptions) (string, error) {
+	cCase := opts["case"]
+	lang := opts["language"]
+	if !KnownLanguage(lang) {
+		return "", InvalidArgumentError(fmt.Sprintf("language: %s is not a known language", lang))
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc[nameType]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for %s values. Please check your input string", ord, nameType))
+		}
+		// Names go into the cache before any case was applied, and are only re-cased
+		// on request
+		return applyCase(cache[ord], cCase), nil
+	}
+
+	// Generate a new one, from the names of the locale if there are any
+	locale, err := localeNames(nameType, oc, opts)
+	if err != nil {
+		return "", err
+	}
+	var result string
+	if locale != nil {
+		result = locale[oc.rng().Intn(len(locale))]
+	} else {
+		n := oc.rng().Intn(len(names))
+		name := names[n]
+		result = name.GetSpelling(lang)
+	}
+
+	// store it in the cache
+	ca := oc[nameType]
+	cache := ca.([]string)
+	oc[nameType] = append(cache, result)
+	return applyCase(result, cCase), nil
+}
+
+func validateName(opts cmdOptions) error {
+	fallback := opts["fallback"]
+	if fallback != "default" && fallback != "error" {
+		return InvalidArgumentError(fmt.Sprintf("fallback: %s is not one of default or error", fallback))
+	}
+	if l := opts["locale"]; l != "" && !isRef(l) && fallback == "error" {
+		if _, ok := NamesByLocale[strings.ToLower(l)]; !ok {
+			return InvalidArgumentError(fmt.Sprintf("locale: %s is not a locale with names of it's own. Please check your input string", l))
+		}
+	}
+	return nil
+}
+
+// localeNames returns the first or last names of the locale given to a name token, or
+// of the country it refers to. Without a locale, or for one without names of it's
+// own, it returns nil so that the default names are used, unless the fallback option
+// asks for an error instead.
+func localeNames(nameType string, oc objectCache, opts cmdOptions) ([]string, error) {
+	l := opts["locale"]
+	if l == "" {
+		return nil, nil
+	}
+	if isRef(l) {
+		v, err := oc.lookupRef(l)
+		if err != nil {
+			return nil, err
+		}
+		// The country may have been written out in another case
+		country := strings.ToUpper(v.(string))
+		if l = CountryLocales[country]; l == "" {
+			if opts["fallback"] == "error" {
+				return nil, InvalidArgumentError(fmt.Sprintf("locale: there are no names for the country %s", country))
+			}
+			return nil, nil
+		}
+	}
+	names, ok := NamesByLocale[strings.ToLower(l)]
+	if !ok {
+		if opts["fallback"] == "error" {
+			return nil, InvalidArgumentError(fmt.Sprintf("locale: %s is not a locale with names of it's own", l))
+		}
+		return nil, nil
+	}
+	if nameType == "firstname" {
+		return names.First, nil
+	}
+	return names.Last, nil
+}
+
+func validateGeo(opts cmdOptions) error {
+	b, err := opts.getFloatList("bbox")
+	if err != nil {
+		return InvalidArgumentError(fmt.Sprintf("bbox: %s is not a list of numbers", opts["bbox"]))
+	}
+	if len(b) != 4 {
+		return InvalidArgumentError("bbox must be provided as minlat,minlng,maxlat,maxlng. Please check your input string")
+	}
+	// NaN passes every comparison below, so it has to be ruled out first
+	for _, v := range b {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return InvalidArgumentError(fmt.Sprintf("bbox: %s is not a list of finite numbers", opts["bbox"]))
+		}
+	}
+	if b[0] < -90 || b[2] > 90 || b[1] < -180 || b[3] > 180 {
+		return InvalidArgumentError("bbox must be within latitudes -90 to 90 and longitudes -180 to 180. Please check your input string")
+	}
+	if b[0] > b[2] || b[1] > b[3] {
+		return InvalidArgumentError("You cannot generate a point inside a bbox whose lower bounds are greater than it's upper bounds. Please check your input string")
+	}
+	return nil
+}
+
+func geo(oc objectCache, opts cmdOptions) (string, error) {
+	b, err := opts.getFloatList("bbox")
+	if err != nil {
+		return "", err
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["geo"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for geo points. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	// Pick a point uniformly within the box
+	lat := b[0] + oc.rng().Float64()*(b[2]-b[0])
+	lng := b[1] + oc.rng().Float64()*(b[3]-b[1])
+	point := fmt.Sprintf("%f,%f", lat, lng)
+
+	// store it in the cache
+	c := oc["geo"]
+	cache := c.([]string)
+	oc["geo"] = append(cache, point)
+
+	return point, nil
+}
+
+func validateSemver(opts cmdOptions) error {
+	switch opts["sequence"] {
+	case "", "patch", "minor", "major":
+	default:
+		return InvalidArgumentError(fmt.Sprintf("sequence: %s is not one of patch, minor or major", opts["sequence"]))
+	}
+	if _, err := parseSemver(opts["base"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseSemver splits a major.minor.patch version into it's components
+func parseSemver(v string) ([3]int, error) {
+	var version [3]int
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return version, InvalidArgumentError(fmt.Sprintf("base: %s is not a major.minor.patch version", v))
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version, InvalidArgumentError(fmt.Sprintf("base: %s is not a major.minor.patch version", v))
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+func semver(oc objectCache, rs *runState, pos int, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["semver"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for semvers. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	var v [3]int
+	if seq := opts["sequence"]; seq != "" {
+		if v, err = parseSemver(opts["base"]); err != nil {
+			return "", err
+		}
+		// The first value in a sequence is the base itself, and every value after
+		// that bumps the chosen component once more, resetting the lower ones
+		n := rs.next(pos)
+		if n > 0 {
+			switch seq {
+			case "major":
+				v = [3]int{v[0] + n, 0, 0}
+			case "minor":
+				v = [3]int{v[0], v[1] + n, 0}
+			default:
+				v[2] += n
+			}
+		}
+	} else {
+		v = [3]int{oc.rng().Intn(10), oc.rng().Intn(20), oc.rng().Intn(50)}
+	}
+	version := fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+
+	// store it in the cache
+	c := oc["semver"]
+	cache := c.([]string)
+	oc["semver"] = append(cache, version)
+
+	return version, nil
+}
+
+// barcodeLengths is the number of digits in each type of barcode, including the check digit
+var barcodeLengths = map[string]int{
+	"ean13": 13,
+	"upca":  12,
+}
+
+func validateBarcode(opts cmdOptions) error {
+	if _, ok := barcodeLengths[opts["type"]]; !ok {
+		return InvalidArgumentError(fmt.Sprintf("type: %s is not one of ean13 or upca", opts["type"]))
+	}
+	return nil
+}
+
+// gtinCheckDigit computes the check digit for the given digits of an EAN or UPC code.
+// Working back from the digit nearest the check digit, each digit is alternately
+// weighted 3 and 1, and the check digit brings the weighted sum up to a multiple of 10.
+func gtinCheckDigit(digits []byte) byte {
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func barcode(oc objectCache, opts cmdOptions) (string, error) {
+	length, ok := barcodeLengths[opts["type"]]
+	if !ok {
+		return "", InvalidArgumentError(fmt.Sprintf("type: %s is not one of ean13 or upca", opts["type"]))
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["barcode"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for barcodes. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	digits := make([]byte, length-1, length)
+	for i := range digits {
+		digits[i] = byte('0' + oc.rng().Intn(10))
+	}
+	code := string(append(digits, gtinCheckDigit(digits)))
+
+	// store it in the cache
+	c := oc["barcode"]
+	cache := c.([]string)
+	oc["barcode"] = append(cache, code)
+
+	return code, nil
+}
+
+func validateISBN(opts cmdOptions) error {
+	if v := opts["version"]; v != "10" && v != "13" {
+		return InvalidArgumentError(fmt.Sprintf("version: %s is not one of 10 or 13", v))
+	}
+	if h := opts["hyphenated"]; h != "true" && h != "false" {
+		return InvalidArgumentError(fmt.Sprintf("hyphenated: %s is not one of true or false", h))
+	}
+	return nil
+}
+
+// isbn10CheckDigit computes the check character for the first 9 digits of an ISBN-10.
+// Each digit is weighted from 10 down to 2, and the check brings the weighted sum up
+// to a multiple of 11, with a check value of 10 written as an X.
+func isbn10CheckDigit(digits []byte) byte {
+	sum := 0
+	for i, d := range digits {
+		sum += (10 - i) * int(d-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// isbnPublisherLength returns how many digits of the publisher and title digits in an
+// English language (registration group 0) ISBN belong to the publisher, per the ranges
+// assigned by the International ISBN Agency
+func isbnPublisherLength(digits []byte) int {
+	prefix := func(n int) int {
+		p, _ := strconv.Atoi(string(digits[:n]))
+		return p
+	}
+	switch {
+	case prefix(2) <= 19:
+		return 2
+	case prefix(3) <= 699:
+		return 3
+	case prefix(4) <= 8499:
+		return 4
+	case prefix(5) <= 89999:
+		return 5
+	case prefix(6) <= 949999:
+		return 6
+	}
+	return 7
+}
+
+func isbn(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["isbn"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for ISBNs. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	// All ISBNs are generated in registration group 0, followed by 8 publisher and
+	// title digits
+	body := make([]byte, 8)
+	for i := range body {
+		body[i] = byte('0' + oc.rng().Intn(10))
+	}
+	group := "0"
+	prefix := ""
+	var check byte
+	if opts["version"] == "10" {
+		check = isbn10CheckDigit(append([]byte(group), body...))
+	} else {
+		prefix = "978"
+		check = gtinCheckDigit(append([]byte(prefix+group), body...))
+	}
+
+	segments := []string{}
+	if prefix != "" {
+		segments = append(segments, prefix)
+	}
+	pl := isbnPublisherLength(body)
+	segments = append(segments, group, string(body[:pl]), string(body[pl:]), string(check))
+	sep := ""
+	if opts["hyphenated"] == "true" {
+		sep = "-"
+	}
+	code := strings.Join(segments, sep)
+
+	// store it in the cache
+	c := oc["isbn"]
+	cache := c.([]string)
+	oc["isbn"] = append(cache, code)
+
+	return code, nil
+}
+
+// mimeTypesIn returns the known MIME types with the given top level type, or all of
+// them if category is empty
+func mimeTypesIn(category string) []string {
+	if category == "" {
+		return MimeTypes
+	}
+	types := make([]string, 0)
+	for _, t := range MimeTypes {
+		if strings.HasPrefix(t, category+"/") {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func validateMimeType(opts cmdOptions) error {
+	if len(mimeTypesIn(opts["category"])) == 0 {
+		return InvalidArgumentError(fmt.Sprintf("category: %s is not a known MIME type category", opts["category"]))
+	}
+	return nil
+}
+
+func mimetype(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["mimetype"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for MIME types. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	types := mimeTypesIn(opts["category"])
+	if len(types) == 0 {
+		return "", InvalidArgumentError(fmt.Sprintf("category: %s is not a known MIME type category", opts["category"]))
+	}
+	t := types[oc.rng().Intn(len(types))]
+
+	// store it in the cache
+	c := oc["mimetype"]
+	cache := c.([]string)
+	oc["mimetype"] = append(cache, t)
+
+	return t, nil
+}
+
+func currencycode(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["currencycode"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for currency codes. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	code := Currencies[oc.rng().Intn(len(Currencies))].Code
+
+	// store it in the cache
+	c := oc["currencycode"]
+	cache := c.([]string)
+	oc["currencycode"] = append(cache, code)
+
+	return code, nil
+}
+
+func validateMoney(opts cmdOptions) error {
+	min, err := opts.getFloat("min")
+	if err != nil {
+		return InvalidArgumentError(fmt.Sprintf("min: %s is not a number", opts["min"]))
+	}
+	max, err := opts.getFloat("max")
+	if err != nil {
+		return InvalidArgumentError(fmt.Sprintf("max: %s is not a number", opts["max"]))
+	}
+	if min > max {
+		return InvalidArgumentError("You cannot generate a random amount of money whose lower bound is greater than it's upper bound. Please check your input string")
+	}
+	if c := opts["currency"]; !isRef(c) {
+		if _, ok := FindCurrency(c); !ok {
+			return InvalidArgumentError(fmt.Sprintf("currency: %s is not a known ISO 4217 currency code", c))
+		}
+	}
+	return nil
+}
+
+func money(oc objectCache, opts cmdOptions) (string, error) {
+	min, err := opts.getFloat("min")
+	if err != nil {
+		return "", err
+	}
+	max, err := opts.getFloat("max")
+	if err != nil {
+		return "", err
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["money"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for money. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	code := opts["currency"]
+	if isRef(code) {
+		v, err := oc.lookupRef(code)
+		if err != nil {
+			return "", err
+		}
+		code = v.(string)
+	}
+	cur, ok := FindCurrency(code)
+	if !ok {
+		return "", InvalidArgumentError(fmt.Sprintf("currency: %s is not a known ISO 4217 currency code", code))
+	}
+	// Write the amount out with as many decimals as the currency's minor unit calls for
+	amount := min + oc.rng().Float64()*(max-min)
+	m := cur.Symbol + strconv.FormatFloat(amount, 'f', cur.Decimals, 64)
+
+	// store it in the cache
+	c := oc["money"]
+	cache := c.([]string)
+	oc["money"] = append(cache, m)
+
+	return m, nil
+}
+
+// minorUnits converts an amount to a whole number of the minor units of a currency,
+// which have scale to each of it's major units, rounding up or down when the amount
+// falls between two. An amount which is a whole number of minor units is kept as it is,
+// even when the float can't hold it exactly, so that 12.34 is 1234 cents rather than
+// 1233.
+func minorUnits(amount float64, scale float64, up bool) (int, error) {
+	v := amount * scale
+	if math.Abs(v) > 1<<53 {
+		return 0, InvalidArgumentError(fmt.Sprintf("%g is too large to be counted exactly in minor units. Please check your input string", amount))
+	}
+	if r := math.Round(v); math.Abs(v-r) < 1e-6 {
+		return int(r), nil
+	}
+	if up {
+		return int(math.Ceil(v)), nil
+	}
+	return int(math.Floor(v)), nil
+}
+
+func validateCents(opts cmdOptions) error {
+	if err := validateMoney(opts); err != nil {
+		return err
+	}
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 || isRef(opts["currency"]) {
+		return nil
+	}
+	// Without a reference, the currency is known, and so is whether it has any whole
+	// number of minor units between the bounds
+	cur, _ := FindCurrency(opts["currency"])
+	min, _ := opts.getFloat("min")
+	max, _ := opts.getFloat("max")
+	_, _, err := centsRange(cur, min, max)
+	return err
+}
+
+// centsRange returns the least and most whole numbers of the minor units of cur which
+// are between min and max, given in it's major units
+func centsRange(cur Currency, min float64, max float64) (int, int, error) {
+	scale := math.Pow10(cur.Decimals)
+	low, err := minorUnits(min, scale, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := minorUnits(max, scale, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if low > high {
+		return 0, 0, InvalidArgumentError(fmt.Sprintf("There is no whole number of the minor units of %s from %g to %g. Please check your input string", cur.Code, min, max))
+	}
+	return low, high, nil
+}
+
+func cents(oc objectCache, opts cmdOptions) (string, error) {
+	min, err := opts.getFloat("min")
+	if err != nil {
+		return "", err
+	}
+	max, err := opts.getFloat("max")
+	if err != nil {
+		return "", err
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["cents"]
+		cache := c.([]int)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for cents. Please check your input string", ord))
+		}
+		return strconv.Itoa(cache[ord]), nil
+	}
+
+	code := opts["currency"]
+	if isRef(code) {
+		v, err := oc.lookupRef(code)
+		if err != nil {
+			return "", err
+		}
+		code = v.(string)
+	}
+	cur, ok := FindCurrency(code)
+	if !ok {
+		return "", InvalidArgumentError(fmt.Sprintf("currency: %s is not a known ISO 4217 currency code", code))
+	}
+	// The bounds are in major units, such as dollars, and the amount is a whole number
+	// of the currency's minor units between them, such as cents
+	low, high, err := centsRange(cur, min, max)
+	if err != nil {
+		return "", err
+	}
+	n := low + int(oc.rng().Int63n(int64(high-low)+1))
+
+	// store it in the cache
+	c := oc["cents"]
+	cache := c.([]int)
+	oc["cents"] = append(cache, n)
+
+	return strconv.Itoa(n), nil
+}
+
+var (
+	pools   = make(map[string][]string)
+	poolsMu sync.RWMutex
+)
+
+// RegisterPool makes a set of values available to the {pool} token under the given
+// name, replacing any pool already registered with that name. This is useful for
+// generating rows that refer to values which already exist, such as the ids of rows
+// generated by an earlier run. The values are copied, so the slice can be reused.
+func RegisterPool(name string, values []string) error {
+	if len(values) == 0 {
+		return InvalidArgumentError(fmt.Sprintf("The pool %s must contain at least one value", name))
+	}
+	v := make([]string, len(values))
+	copy(v, values)
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	pools[name] = v
+	return nil
+}
+
+// lookupPool returns the values registered under the given name
+func lookupPool(name string) ([]string, error) {
+	poolsMu.RLock()
+	defer poolsMu.RUnlock()
+	values, ok := pools[name]
+	if !ok {
+		return nil, InvalidArgumentError(fmt.Sprintf("name: %s is not a registered pool. Please check your input string", name))
+	}
+	return values, nil
+}
+
+func validatePool(opts cmdOptions) error {
+	// An ordinal only refers to a value already picked, so it doesn't need a pool
+	if ord, err := opts.getInt("ordinal"); err == nil && ord >= 0 {
+		return nil
+	}
+	_, err := lookupPool(opts["name"])
+	return err
+}
+
+func pool(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["pool"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for pools. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	values, err := lookupPool(opts["name"])
+	if err != nil {
+		return "", err
+	}
+	v := values[oc.rng().Intn(len(values))]
+
+	// store it in the cache
+	c := oc["pool"]
+	cache := c.([]string)
+	oc["pool"] = append(cache, v)
+
+	return v, nil
+}
+
+func validateEmail(opts cmdOptions) error {
+	if d := opts["domain"]; d == "" || strings.ContainsAny(d, "@ ") {
+		return InvalidArgumentError(fmt.Sprintf("domain: %s is not a valid email domain", d))
+	}
+	if from := opts["from"]; from != "" && !isRef(from) {
+		return InvalidArgumentError(fmt.Sprintf("from: %s must refer to earlier {firstname} or {lastname} tokens, such as @0,@1. Please check your input string", from))
+	}
+	return nil
+}
+
+func email(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["email"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for email. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	// Build the address out of the names it refers to, or a random name if it
+	// doesn't refer to any
+	names := make([]string, 0, 2)
+	if from := opts["from"]; from != "" {
+		for _, ref := range strings.Split(from, ",") {
+			v, err := oc.lookupRef(ref)
+			if err != nil {
+				return "", err
+			}
+			names = append(names, v.(string))
+		}
+	} else {
+		names = append(names,
+			FirstNames[oc.rng().Intn(len(FirstNames))].GetSpelling(English),
+			LastNames[oc.rng().Intn(len(LastNames))].GetSpelling(English))
+	}
+	local := make([]string, 0, len(names))
+	for _, n := range names {
+		if s := emailSafe(n); s != "" {
+			local = append(local, s)
+		}
+	}
+	// Names spelled entirely outside of a-z leave nothing to build the address from
+	if len(local) == 0 {
+		b := make([]byte, 8)
+		for i := range b {
+			b[i] = byte('a' + oc.rng().Intn(26))
+		}
+		local = append(local, string(b))
+	}
+	e := strings.Join(local, ".") + "@" + opts["domain"]
+
+	// store it in the cache
+	c := oc["email"]
+	cache := c.([]string)
+	oc["email"] = append(cache, e)
+
+	return e, nil
+}
+
+// emailSafe lowercases a name and drops everything but a-z and 0-9 from it, so that it
+// can be used in the local part of an email address
+func emailSafe(name string) string {
+	b := &bytes.Buffer{}
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// addressValue is an address as it was written out, along with the street and the rest
+// of it, so that an ordinal can write it out in another format
+type addressValue struct {
+	street    string
+	rest      string
+	formatted string
+}
+
+// addressSeparators are what goes between the street and the rest of an address, for
+// each format it can be written out in
+var addressSeparators = map[string]string{"multiline": "\n", "oneline": ", "}
+
+func validateAddress(opts cmdOptions) error {
+	if f, ok := opts["format"]; ok {
+		if _, ok := addressSeparators[f]; !ok {
+			return InvalidArgumentError(fmt.Sprintf("format: %s is not one of multiline or oneline", f))
+		}
+	}
+	return nil
+}
+
+func address(oc objectCache, opts cmdOptions) (string, error) {
+	format, reformat := opts["format"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["address"]
+		cache := c.([]addressValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for addresses. Please check your input string", ord))
+		}
+		// Addresses are repeated as they were written out, unless a format is asked for
+		a := cache[ord]
+		if !reformat {
+			return a.formatted, nil
+		}
+		return a.street + addressSeparators[format] + a.rest, nil
+	}
+
+	if !reformat {
+		format = "multiline"
+	}
+	city := Cities[oc.rng().Intn(len(Cities))]
+	street := fmt.Sprintf("%d %s %s", 1+oc.rng().Intn(9999), StreetNames[oc.rng().Intn(len(StreetNames))], StreetSuffixes[oc.rng().Intn(len(StreetSuffixes))])
+	rest := fmt.Sprintf("%s, %s %s", city.Name, city.State, randomZip(oc.rng(), city))
+	a := addressValue{street: street, rest: rest, formatted: street + addressSeparators[format] + rest}
+
+	// store it in the cache
+	c := oc["address"]
+	cache := c.([]addressValue)
+	oc["address"] = append(cache, a)
+
+	return a.formatted, nil
+}
+
+// randomZip returns a random 5 digit ZIP code served by the given city
+func randomZip(r *rand.Rand, city City) string {
+	return fmt.Sprintf("%s%02d", city.ZipPrefix, 1+r.Intn(99))
+}
+
+func validateState(opts cmdOptions) error {
+	if f := opts["format"]; f != "code" && f != "name" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of code or name", f))
+	}
+	return nil
+}
+
+func state(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["state"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for states. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	st := States[oc.rng().Intn(len(States))]
+	s := st.Code
+	if opts["format"] == "name" {
+		s = st.Name
+	}
+
+	// store it in the cache
+	c := oc["state"]
+	cache := c.([]string)
+	oc["state"] = append(cache, s)
+
+	return s, nil
+}
+
+func validateZip(opts cmdOptions) error {
+	if c := opts["country"]; c != "US" {
+		return InvalidArgumentError(fmt.Sprintf("country: %s is not supported, only US ZIP codes can be generated", c))
+	}
+	if p := opts["plus4"]; p != "true" && p != "false" {
+		return InvalidArgumentError(fmt.Sprintf("plus4: %s is not one of true or false", p))
+	}
+	if s := opts["state"]; s != "" && !isRef(s) {
+		if _, ok := FindState(s); !ok {
+			return InvalidArgumentError(fmt.Sprintf("state: %s is not a known US state", s))
+		}
+	}
+	return nil
+}
+
+func zip(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["zip"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for ZIP codes. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	// Pick from the cities of the given state, or from every city if there isn't one
+	cities := Cities
+	if s := opts["state"]; s != "" {
+		if isRef(s) {
+			v, err := oc.lookupRef(s)
+			if err != nil {
+				return "", err
+			}
+			s = v.(string)
+		}
+		st, ok := FindState(s)
+		if !ok {
+			return "", InvalidArgumentError(fmt.Sprintf("state: %s is not a known US state", s))
+		}
+		cities = CitiesIn(st.Code)
+	}
+	z := randomZip(oc.rng(), cities[oc.rng().Intn(len(cities))])
+	if opts["plus4"] == "true" {
+		z = fmt.Sprintf("%s-%04d", z, 1+oc.rng().Intn(9999))
+	}
+
+	// store it in the cache
+	c := oc["zip"]
+	cache := c.([]string)
+	oc["zip"] = append(cache, z)
+
+	return z, nil
+}
+
+func validateSSN(opts cmdOptions) error {
+	if f, ok := opts["format"]; ok && f != "dashed" && f != "plain" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of dashed or plain", f))
+	}
+	return nil
+}
+
+func ssn(oc objectCache, opts cmdOptions) (string, error) {
+	format := opts["format"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["ssn"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for SSNs. Please check your input string", ord))
+		}
+		// SSNs go into the cache as they were written out, only re-format them on
+		// request
+		s := cache[ord]
+		if format != "" {
+			s = formatSSN(strings.Replace(s, "-", "", -1), format)
+		}
+		return s, nil
+	}
+
+	// The Social Security Administration never issues an area number in the 900s, so
+	// no SSN generated here can belong to a real person
+	digits := fmt.Sprintf("%03d%02d%04d", 900+oc.rng().Intn(100), 1+oc.rng().Intn(99), 1+oc.rng().Intn(9999))
+	s := formatSSN(digits, format)
+
+	// store it in the cache
+	c := oc["ssn"]
+	cache := c.([]string)
+	oc["ssn"] = append(cache, s)
+
+	return s, nil
+}
+
+// formatSSN writes the 9 digits of an SSN out in the given format, which is dashed
+// unless plain is asked for
+func formatSSN(digits string, format string) string {
+	if format == "plain" {
+		return digits
+	}
+	return digits[:3] + "-" + digits[3:5] + "-" + digits[5:]
+}
+
+// maxRegexRepeat is the most times a single {regex} quantifier can repeat. As nested
+// quantifiers multiply, it doesn't limit the length of the result on it's own, which
+// maxRegexLength does for the whole pattern.
+const maxRegexRepeat = 1000
+
+// maxRegexLength is the most characters a {regex} pattern can produce, at worst
+const maxRegexLength = 10000
+
+// regexNode is a single piece of a parsed {regex} pattern, repeated between min and max
+// times. It is either a set of characters to pick one of, or a group of alternatives to
+// pick one of, each of which is a sequence of nodes.
+type regexNode struct {
+	chars    []rune
+	alts     [][]*regexNode
+	min, max int
+}
+
+// regexParser parses the safe subset of regular expressions supported by {regex}
+type regexParser struct {
+	p   []rune
+	pos int
+}
+
+// Character sets for . and the shorthand classes, limited to printable ASCII
+var (
+	regexPrintable = runeRange(' ', '~')
+	regexDigits    = runeRange('0', '9')
+	regexWord      = append(append(append(runeRange('a', 'z'), runeRange('A', 'Z')...), regexDigits...), '_')
+	regexSpace     = []rune{' ', '\t'}
+)
+
+func runeRange(lo rune, hi rune) []rune {
+	r := make([]rune, 0, hi-lo+1)
+	for c := lo; c <= hi; c++ {
+		r = append(r, c)
+	}
+	return r
+}
+
+// complementRunes returns every printable ASCII character which is not in set
+func complementRunes(set []rune) []rune {
+	in := make(map[rune]bool)
+	for _, c := range set {
+		in[c] = true
+	}
+	r := make([]rune, 0)
+	for _, c := range regexPrintable {
+		if !in[c] {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+func parseRegex(pattern string) ([][]*regexNode, error) {
+	rp := &regexParser{p: []rune(pattern)}
+	// Anchors are implied, as the result is always the whole match
+	if len(rp.p) > 0 && rp.p[0] == '^' {
+		rp.pos++
+	}
+	if len(rp.p) > rp.pos && rp.p[len(rp.p)-1] == '$' && (len(rp.p) < 2 || rp.p[len(rp.p)-2] != '\\') {
+		rp.p = rp.p[:len(rp.p)-1]
+	}
+	alts, err := rp.alternation()
+	if err != nil {
+		return nil, err
+	}
+	if rp.pos < len(rp.p) {
+		return nil, rp.errorf("unmatched )")
+	}
+	if n := longestRegex(alts); n > maxRegexLength {
+		return nil, InvalidArgumentError(fmt.Sprintf("pattern: %s can produce up to %d characters, but at most %d are allowed. Please check your input string", pattern, n, maxRegexLength))
+	}
+	return alts, nil
+}
+
+// longestRegex returns the most characters any of the alternatives can produce
+func longestRegex(alts [][]*regexNode) int {
+	longest := 0
+	for _, seq := range alts {
+		n := 0
+		for _, node := range seq {
+			each := 1
+			if node.alts != nil {
+				each = longestRegex(node.alts)
+			}
+			if n += mulCapped(each, node.max); n < 0 {
+				n = math.MaxInt
+			}
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+func (rp *regexParser) errorf(format string, args ...interface{}) error {
+	return InvalidArgumentError(fmt.Sprintf("pattern: %s at position %d of %s. Please check your input string", fmt.Sprintf(format, args...), rp.pos, string(rp.p)))
+}
+
+func (rp *regexParser) more() bool {
+	return rp.pos < len(rp.p)
+}
+
+func (rp *regexParser) peek() rune {
+	return rp.p[rp.pos]
+}
+
+// alternation parses sequences separated by |, up to the end of the pattern or group
+func (rp *regexParser) alternation() ([][]*regexNode, error) {
+	alts := make([][]*regexNode, 0)
+	for {
+		seq, err := rp.sequence()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		if !rp.more() || rp.peek() != '|' {
+			return alts, nil
+		}
+		rp.pos++
+	}
+}
+
+// sequence parses quantified atoms up to the next |, ) or the end of the pattern
+func (rp *regexParser) sequence() ([]*regexNode, error) {
+	seq := make([]*regexNode, 0)
+	for rp.more() && rp.peek() != '|' && rp.peek() != ')' {
+		n, err := rp.atom()
+		if err != nil {
+			return nil, err
+		}
+		if err := rp.quantifier(n); err != nil {
+			return nil, err
+		}
+		seq = append(seq, n)
+	}
+	return seq, nil
+}
+
+func (rp *regexParser) atom() (*regexNode, error) {
+	c := rp.peek()
+	rp.pos++
+	switch c {
+	case '(':
+		// Groups don't capture anything, so (?:) is the same as ()
+		if rp.pos+1 < len(rp.p) && rp.p[rp.pos] == '?' && rp.p[rp.pos+1] == ':' {
+			rp.pos += 2
+		}
+		alts, err := rp.alternation()
+		if err != nil {
+			return nil, err
+		}
+		if !rp.more() {
+			return nil, rp.errorf("missing )")
+		}
+		rp.pos++
+		return &regexNode{alts: alts, min: 1, max: 1}, nil
+	case '[':
+		chars, err := rp.class()
+		if err != nil {
+			return nil, err
+		}
+		return &regexNode{chars: chars, min: 1, max: 1}, nil
+	case '.':
+		return &regexNode{chars: regexPrintable, min: 1, max: 1}, nil
+	case '\\':
+		chars, err := rp.escape()
+		if err != nil {
+			return nil, err
+		}
+		return &regexNode{chars: chars, min: 1, max: 1}, nil
+	case '*', '+':
+		return nil, rp.errorf("unbounded quantifier %c is not supported, use a bounded one such as {1,5}", c)
+	case '?', '{', '}':
+		return nil, rp.errorf("nothing to repeat before %c", c)
+	case '^', '$':
+		return nil, rp.errorf("anchor %c is only supported at the start or end", c)
+	}
+	return &regexNode{chars: []rune{c}, min: 1, max: 1}, nil
+}
+
+// escape parses the character after a \, returning the characters it stands for
+func (rp *regexParser) escape() ([]rune, error) {
+	if !rp.more() {
+		return nil, rp.errorf("trailing \\")
+	}
+	c := rp.peek()
+	rp.pos++
+	switch c {
+	case 'd':
+		return regexDigits, nil
+	case 'D':
+		return complementRunes(regexDigits), nil
+	case 'w':
+		return regexWord, nil
+	case 'W':
+		return complementRunes(regexWord), nil
+	case 's':
+		return regexSpace, nil
+	case 'S':
+		return complementRunes(regexSpace), nil
+	case 't':
+		return []rune{'\t'}, nil
+	case 'n':
+		return []rune{'\n'}, nil
+	}
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+		return nil, rp.errorf("escape \\%c is not supported", c)
+	}
+	return []rune{c}, nil
+}
+
+// class parses a character class such as [A-Z0-9_] or [^a-z], after it's [
+func (rp *regexParser) class() ([]rune, error) {
+	negate := false
+	if rp.more() && rp.peek() == '^' {
+		negate = true
+		rp.pos++
+	}
+	set := make([]rune, 0)
+	first := true
+	for {
+		if !rp.more() {
+			return nil, rp.errorf("missing ]")
+		}
+		c := rp.peek()
+		if c == ']' && !first {
+			rp.pos++
+			break
+		}
+		first = false
+		rp.pos++
+		if c == '\\' {
+			chars, err := rp.escape()
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, chars...)
+			continue
+		}
+		// A range, unless the - is the last character of the class
+		if rp.pos+1 < len(rp.p) && rp.p[rp.pos] == '-' && rp.p[rp.pos+1] != ']' {
+			hi := rp.p[rp.pos+1]
+			if hi < c {
+				return nil, rp.errorf("range %c-%c is out of order", c, hi)
+			}
+			rp.pos += 2
+			set = append(set, runeRange(c, hi)...)
+			continue
+		}
+		set = append(set, c)
+	}
+	if negate {
+		set = complementRunes(set)
+	}
+	if len(set) == 0 {
+		return nil, rp.errorf("character class matches nothing")
+	}
+	return set, nil
+}
+
+// quantifier parses an optional ?, {n} or {n,m} after an atom, and applies it
+func (rp *regexParser) quantifier(n *regexNode) error {
+	if !rp.more() {
+		return nil
+	}
+	switch rp.peek() {
+	case '?':
+		rp.pos++
+		n.min, n.max = 0, 1
+	case '*', '+':
+		return rp.errorf("unbounded quantifier %c is not supported, use a bounded one such as {1,5}", rp.peek())
+	case '{':
+		end := rp.pos
+		for end < len(rp.p) && rp.p[end] != '}' {
+			end++
+		}
+		if end == len(rp.p) {
+			return rp.errorf("missing }")
+		}
+		bounds := strings.SplitN(string(rp.p[rp.pos+1:end]), ",", 2)
+		min, err := strconv.Atoi(bounds[0])
+		if err != nil || min < 0 {
+			return rp.errorf("quantifier {%s} is not supported", string(rp.p[rp.pos+1:end]))
+		}
+		max := min
+		if len(bounds) == 2 {
+			if bounds[1] == "" {
+				return rp.errorf("unbounded quantifier {%s} is not supported, give it a maximum", string(rp.p[rp.pos+1:end]))
+			}
+			max, err = strconv.Atoi(bounds[1])
+			if err != nil || max < min {
+				return rp.errorf("quantifier {%s} is not supported", string(rp.p[rp.pos+1:end]))
+			}
+		}
+		if max > maxRegexRepeat {
+			return rp.errorf("quantifier {%s} can repeat at most %d times", string(rp.p[rp.pos+1:end]), maxRegexRepeat)
+		}
+		rp.pos = end + 1
+		n.min, n.max = min, max
+	default:
+		return nil
+	}
+	if rp.more() {
+		switch rp.peek() {
+		case '?', '*', '+', '{':
+			return rp.errorf("nothing to repeat before %c", rp.peek())
+		}
+	}
+	return nil
+}
+
+// generateRegex writes out a random string matching one of the alternatives
+func generateRegex(r *rand.Rand, alts [][]*regexNode, result *bytes.Buffer) {
+	for _, n := range alts[r.Intn(len(alts))] {
+		count := n.min + r.Intn(n.max-n.min+1)
+		for i := 0; i < count; i++ {
+			if n.alts != nil {
+				generateRegex(r, n.alts, result)
+			} else {
+				result.WriteRune(n.chars[r.Intn(len(n.chars))])
+			}
+		}
+	}
+}
+
+func validateRegex(opts cmdOptions) error {
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil
+	}
+	if opts["pattern"] == "" {
+		return InvalidArgumentError("pattern: You must provide a pattern to generate a string from. Please check your input string")
+	}
+	return nil
+}
+
+// prepareRegex parses the pattern of a {regex} once, when the template is parsed
+func prepareRegex(opts cmdOptions) (interface{}, error) {
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil, nil
+	}
+	return parseRegex(opts["pattern"])
+}
+
+func regex(oc objectCache, opts cmdOptions, prep interface{}) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["regex"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for regex. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	result := &bytes.Buffer{}
+	generateRegex(oc.rng(), prep.([][]*regexNode), result)
+	s := result.String()
+
+	// store it in the cache
+	c := oc["regex"]
+	cache := c.([]string)
+	oc["regex"] = append(cache, s)
+
+	return s, nil
+}
+
+func validateString(opts cmdOptions) error {
+	invalid, err := opts.getFloat("invalid")
+	if err != nil || invalid < 0 || invalid > 1 {
+		return InvalidArgumentError(fmt.Sprintf("invalid: %s is not a number from 0 to 1", opts["invalid"]))
+	}
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil
+	}
+	if opts["match"] == "" {
+		return InvalidArgumentError("match: You must provide a pattern to generate a string from. Please check your input string")
+	}
+	return nil
+}
+
+// stringPattern is the pattern of a {string}, parsed once when the template is parsed,
+// along with the regexp which checks that it's invalid values don't match it
+type stringPattern struct {
+	alts [][]*regexNode
+	re   *regexp.Regexp
+}
+
+// stringSamples is how many values of a pattern are made invalid when the template is
+// parsed, to check that it's possible
+const stringSamples = 10
+
+// prepareString parses the pattern of a {string}, and when some of it's values are to
+// be invalid, compiles the pattern with Go's regexp package and makes sure that values
+// of it can be made invalid at all
+func prepareString(opts cmdOptions) (interface{}, error) {
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil, nil
+	}
+	pattern := opts["match"]
+	alts, err := parseRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sp := stringPattern{alts: alts}
+	if invalid, _ := opts.getFloat("invalid"); invalid == 0 {
+		return sp, nil
+	}
+	// Any anchors of the pattern's own are harmless inside of these
+	if sp.re, err = regexp.Compile(`^(?:` + pattern + `)$`); err != nil {
+		return nil, InvalidArgumentError(fmt.Sprintf("match: %s could not be compiled to check that it's invalid values don't match it: %s. Please check your input string", pattern, err))
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < stringSamples; i++ {
+		valid := &bytes.Buffer{}
+		generateRegex(r, alts, valid)
+		if _, err := violateRegex(r, sp.re, pattern, valid.String()); err != nil {
+			return nil, InvalidArgumentError(fmt.Sprintf("match: %s matches nearly any string, so the values it generates can't be made invalid. Please check your input string", pattern))
+		}
+	}
+	return sp, nil
+}
+
+// violateRegex changes a string which matches a pattern into one which doesn't, by
+// deleting, inserting or replacing one of it's characters, until the pattern, compiled
+// as re, no longer matches the whole of it
+func violateRegex(r *rand.Rand, re *regexp.Regexp, pattern string, valid string) (string, error) {
+	var s string
+	err := reroll("a string which doesn't match "+pattern, func() bool {
+		runes := []rune(valid)
+		c := regexPrintable[r.Intn(len(regexPrintable))]
+		switch i := r.Intn(len(runes) + 1); {
+		case len(runes) == 0 || i == len(runes):
+			runes = append(runes[:i], append([]rune{c}, runes[i:]...)...)
+		case r.Intn(2) == 0:
+			runes = append(runes[:i], runes[i+1:]...)
+		default:
+			runes[i] = c
+		}
+		s = string(runes)
+		return !re.MatchString(s)
+	})
+	return s, err
+}
+
+func matchString(oc objectCache, opts cmdOptions, prep interface{}) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["string"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for strings. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	invalid, err := opts.getFloat("invalid")
+	if err != nil {
+		return "", err
+	}
+	sp := prep.(stringPattern)
+	result := &bytes.Buffer{}
+	generateRegex(oc.rng(), sp.alts, result)
+	s := result.String()
+	if invalid > 0 && oc.rng().Float64() < invalid {
+		if s, err = violateRegex(oc.rng(), sp.re, opts["match"], s); err != nil {
+			return "", err
+		}
+	}
+
+	// store it in the cache
+	c := oc["string"]
+	cache := c.([]string)
+	oc["string"] = append(cache, s)
+
+	return s, nil
+}
+
+func validatePalette(opts cmdOptions) error {
+	colors, ok := Palettes[opts["scheme"]]
+	if !ok {
+		return InvalidArgumentError(fmt.Sprintf("scheme: %s is not a known palette", opts["scheme"]))
+	}
+	count, err := opts.getInt("count")
+	if err != nil || count < 1 {
+		return InvalidArgumentError(fmt.Sprintf("count: %s is not an integer >= 1", opts["count"]))
+	}
+	switch opts["overflow"] {
+	case "error":
+		if count > len(colors) {
+			return InvalidArgumentError(fmt.Sprintf("count: The %s palette only has %d colors, so %d distinct colors can't be picked. Use overflow:cycle to repeat them", opts["scheme"], len(colors), count))
+		}
+	case "cycle":
+	default:
+		return InvalidArgumentError(fmt.Sprintf("overflow: %s is not one of error or cycle", opts["overflow"]))
+	}
+	return nil
+}
+
+func palette(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["palette"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for palettes. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	colors := Palettes[opts["scheme"]]
+	count, err := opts.getInt("count")
+	if err != nil {
+		return "", err
+	}
+	if err := oc.checkLimit(count); err != nil {
+		return "", err
+	}
+	// Shuffle the palette and take as many colors as are needed, going back around to
+	// the start if more are needed than it has
+	order := oc.rng().Perm(len(colors))
+	picked := make([]string, count)
+	for i := range picked {
+		picked[i] = colors[order[i%len(order)]]
+	}
+	p := strings.Join(picked, ",")
+
+	// store it in the cache
+	c := oc["palette"]
+	cache := c.([]string)
+	oc["palette"] = append(cache, p)
+
+	return p, nil
+}
+
+// blobFormats write random bytes out as a binary literal in a given SQL dialect
+var blobFormats = map[string]func(b []byte) string{
+	"pghex":    func(b []byte) string { return fmt.Sprintf("\\x%x", b) },
+	"mysqlhex": func(b []byte) string { return fmt.Sprintf("0x%X", b) },
+}
+
+// blobValue is a generated blob along with how it was written out, so that an ordinal
+// can either repeat it exactly or write the same bytes out for another dialect
+type blobValue struct {
+	b         []byte
+	formatted string
+}
+
+func validateBlob(opts cmdOptions) error {
+	if f, ok := opts["format"]; ok && blobFormats[f] == nil {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of pghex or mysqlhex", f))
+	}
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	if length, err := opts.getInt("length"); err != nil || length < 1 {
+		return InvalidArgumentError(fmt.Sprintf("length: %s is not an integer >= 1", opts["length"]))
+	}
+	return nil
+}
+
+// randomBytes makes a slice of n random bytes from r. It doesn't use r.Read, which
+// isn't safe to call from many goroutines even when r's source is.
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := 0; i < n; i += 8 {
+		v := r.Uint64()
+		for j := i; j < n && j < i+8; j++ {
+			b[j] = byte(v)
+			v >>= 8
+		}
+	}
+	return b
+}
+
+func blob(oc objectCache, opts cmdOptions) (string, error) {
+	format, reformat := opts["format"]
+	if !reformat {
+		format = "pghex"
+	}
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["blob"]
+		cache := c.([]blobValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for blobs. Please check your input string", ord))
+		}
+		// Blobs are repeated as they were written out, unless a format is asked for
+		if reformat {
+			return blobFormats[format](cache[ord].b), nil
+		}
+		return cache[ord].formatted, nil
+	}
+
+	length, err := opts.getInt("length")
+	if err != nil {
+		return "", err
+	}
+	// However it's formatted, each byte of the blob takes at least a byte of the result
+	if err := oc.checkLimit(length); err != nil {
+		return "", err
+	}
+	b := randomBytes(oc.rng(), length)
+	s := blobFormats[format](b)
+
+	// store it in the cache
+	c := oc["blob"]
+	cache := c.([]blobValue)
+	oc["blob"] = append(cache, blobValue{b: b, formatted: s})
+
+	return s, nil
+}
+
+var (
+	wordLists   = map[string][]string{"latin": LoremWords}
+	wordListsMu sync.RWMutex
+)
+
+// RegisterWordList makes a list of words available to the {lorem} token under the given
+// name, replacing any list already registered with that name, including the built in
+// latin list. This is useful for filler text in the vocabulary of the domain being tested.
+// The words are copied, so the slice can be reused.
+func RegisterWordList(name string, words []string) error {
+	if len(words) == 0 {
+		return InvalidArgumentError(fmt.Sprintf("The word list %s must contain at least one word", name))
+	}
+	w := make([]string, len(words))
+	copy(w, words)
+	wordListsMu.Lock()
+	defer wordListsMu.Unlock()
+	wordLists[name] = w
+	return nil
+}
+
+// lookupWordList returns the words registered under the given name
+func lookupWordList(name string) ([]string, error) {
+	wordListsMu.RLock()
+	defer wordListsMu.RUnlock()
+	words, ok := wordLists[name]
+	if !ok {
+		return nil, InvalidArgumentError(fmt.Sprintf("list: %s is not a registered word list. Please check your input string", name))
+	}
+	return words, nil
+}
+
+// loremMarkup is how the paragraphs of a {lorem} are written out
+type loremMarkup struct {
+	// wrap writes out the text of a single paragraph
+	wrap func(string) string
+	// sep goes between each paragraph
+	sep string
+}
+
+var loremMarkups = map[string]loremMarkup{
+	"none":     {wrap: func(s string) string { return s }, sep: "\n"},
+	"markdown": {wrap: func(s string) string { return s }, sep: "\n\n"},
+	// Registered word lists can have anything in them, so they're escaped to be safe
+	"html": {wrap: func(s string) string { return "<p>" + html.EscapeString(s) + "</p>" }, sep: ""},
+}
+
+// markovChain is an order 2 markov model of some text, which knows every word that
+// follows each pair of words in it
+type markovChain struct {
+	// starts are the first two words of each sentence
+	starts [][2]string
+	// next are the words which follow each pair, once for each time they do, so that
+	// picking one at random picks the likelier ones more often
+	next map[[2]string][]string
+}
+
+// maxSentenceWords stops a markov sentence which never reaches the end of one in the text
+// from going on forever
+const maxSentenceWords = 60
+
+// newMarkovChain trains a markovChain on text, whose sentences each end with a full stop
+func newMarkovChain(text string) *markovChain {
+	m := &markovChain{next: make(map[[2]string][]string)}
+	words := strings.Fields(text)
+	for i := 0; i+1 < len(words); i++ {
+		if i == 0 || strings.HasSuffix(words[i-1], ".") {
+			m.starts = append(m.starts, [2]string{words[i], words[i+1]})
+		}
+		if i+2 < len(words) && !strings.HasSuffix(words[i], ".") && !strings.HasSuffix(words[i+1], ".") {
+			pair := [2]string{words[i], words[i+1]}
+			m.next[pair] = append(m.next[pair], words[i+2])
+		}
+	}
+	return m
+}
+
+// sentence writes out a sentence, by walking the chain from one of it's starts until it
+// reaches a word which ends a sentence
+func (m *markovChain) sentence(r *rand.Rand) []string {
+	start := m.starts[r.Intn(len(m.starts))]
+	words := []string{start[0], start[1]}
+	for !strings.HasSuffix(words[len(words)-1], ".") {
+		next := m.next[[2]string{words[len(words)-2], words[len(words)-1]}]
+		if len(next) == 0 || len(words) == maxSentenceWords {
+			words[len(words)-1] = strings.TrimRight(words[len(words)-1], ",") + "."
+			break
+		}
+		words = append(words, next[r.Intn(len(next))])
+	}
+	return words
+}
+
+// words writes out n words, from as many sentences as it takes, ending the last of
+// them early if need be
+func (m *markovChain) words(r *rand.Rand, n int) []string {
+	var words []string
+	for len(words) < n {
+		words = append(words, m.sentence(r)...)
+	}
+	words = words[:n]
+	words[n-1] = strings.TrimRight(words[n-1], ",.") + "."
+	return words
+}
+
+// loremChain is the markov model of lorem ipsum that {lorem:model:markov} writes from
+var loremChain = newMarkovChain(LoremText)
+
+func validateLorem(opts cmdOptions) error {
+	// An ordinal only refers to text already generated, so it doesn't need a list
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	if n, err := opts.getInt("words"); err != nil || n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("words: %s is not an integer >= 1", opts["words"]))
+	}
+	if n, err := opts.getInt("paragraphs"); err != nil || n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("paragraphs: %s is not an integer >= 1", opts["paragraphs"]))
+	}
+	if _, ok := loremMarkups[opts["markup"]]; !ok {
+		return InvalidArgumentError(fmt.Sprintf("markup: %s is not one of none, html or markdown", opts["markup"]))
+	}
+	switch opts["model"] {
+	case "simple":
+		if _, ok := opts["sentences"]; ok {
+			return InvalidArgumentError("sentences: Only the markov model writes whole sentences. Please check your input string")
+		}
+	case "markov":
+		if s, ok := opts["sentences"]; ok {
+			if n, err := opts.getInt("sentences"); err != nil || n < 1 {
+				return InvalidArgumentError(fmt.Sprintf("sentences: %s is not an integer >= 1", s))
+			}
+		}
+		// The model is trained on text, rather than a list of words
+		if opts["list"] != "latin" {
+			return InvalidArgumentError(fmt.Sprintf("list: The markov model only writes the built in latin text, not %s. Please check your input string", opts["list"]))
+		}
+	default:
+		return InvalidArgumentError(fmt.Sprintf("model: %s is not one of simple or markov", opts["model"]))
+	}
+	_, err := lookupWordList(opts["list"])
+	return err
+}
+
+func lorem(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["lorem"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for lorem. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	list, err := lookupWordList(opts["list"])
+	if err != nil {
+		return "", err
+	}
+	n, err := opts.getInt("words")
+	if err != nil {
+		return "", err
+	}
+	count, err := opts.getInt("paragraphs")
+	if err != nil {
+		return "", err
+	}
+	sentences, _ := opts.getInt("sentences")
+	// Every word takes at least a byte, as does every markov sentence
+	least := mulCapped(n, count)
+	if opts["model"] == "markov" && sentences > 0 {
+		least = mulCapped(sentences, count)
+	}
+	if err := oc.checkLimit(least); err != nil {
+		return "", err
+	}
+	markup := loremMarkups[opts["markup"]]
+	paragraphs := make([]string, count)
+	for p := range paragraphs {
+		var words []string
+		switch {
+		case opts["model"] != "markov":
+			words = make([]string, n)
+			for i := range words {
+				words[i] = list[oc.rng().Intn(len(list))]
+			}
+		case sentences > 0:
+			for i := 0; i < sentences; i++ {
+				words = append(words, loremChain.sentence(oc.rng())...)
+			}
+		default:
+			words = loremChain.words(oc.rng(), n)
+		}
+		paragraphs[p] = markup.wrap(strings.Join(words, " "))
+	}
+	s := strings.Join(paragraphs, markup.sep)
+
+	// store it in the cache
+	c := oc["lorem"]
+	cache := c.([]string)
+	oc["lorem"] = append(cache, s)
+
+	return s, nil
+}
+
+func ulid(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["ulid"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for ULIDs. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	ms, pinned, err := idTime(oc, opts)
+	if err != nil {
+		return "", err
+	}
+	u := newULID(oc.rng(), oc["ulidorder"].(*orderedIDState), ms, pinned)
+
+	// store it in the cache
+	c := oc["ulid"]
+	cache := c.([]string)
+	oc["ulid"] = append(cache, u)
+
+	return u, nil
+}
+
+// The bit layout of a snowflake id, from the most significant end: 1 unused sign bit,
+// 41 bits of milliseconds since the epoch, 10 bits of machine id and 12 bits of sequence
+const (
+	snowflakeMachineBits  = 10
+	snowflakeSequenceBits = 12
+	snowflakeTimeBits     = 41
+)
+
+// snowflakeKey identifies a snowflake generator, which there is one of per machine id
+// and epoch
+type snowflakeKey struct {
+	epoch   int64
+	machine int
+}
+
+// snowflakeClock is the last snowflake a generator made
+type snowflakeClock struct {
+	ms  int64
+	seq int
+}
+
+// snowflakeClocks holds the generators of a Callstack, one per machine id and epoch,
+// which carry on from one Write to the next until the Callstack is Reset
+type snowflakeClocks struct {
+	sync.Mutex
+	clocks map[snowflakeKey]*snowflakeClock
+}
+
+func newSnowflakeClocks() *snowflakeClocks {
+	return &snowflakeClocks{clocks: make(map[snowflakeKey]*snowflakeClock)}
+}
+
+// reset forgets the last id of every generator, so that they start over
+func (sc *snowflakeClocks) reset() {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.clocks = make(map[snowflakeKey]*snowflakeClock)
+}
+
+// next returns the next id for the given machine, at the time given by now. Ids made in
+// the same millisecond count up the sequence, and when it runs out, borrow the next
+// millisecond.
+func (sc *snowflakeClocks) next(now time.Time, epoch time.Time, machine int) (int64, error) {
+	sc.Lock()
+	defer sc.Unlock()
+	key := snowflakeKey{epoch: epoch.UnixNano(), machine: machine}
+	c, ok := sc.clocks[key]
+	if !ok {
+		c = &snowflakeClock{ms: -1}
+		sc.clocks[key] = c
+	}
+	ms := int64(now.Sub(epoch) / time.Millisecond)
+	if ms < 0 {
+		return 0, InvalidArgumentError(fmt.Sprintf("epoch: %s is in the future. Please check your input string", epoch.Format(time.RFC3339)))
+	}
+	seq := 0
+	if ms <= c.ms {
+		ms, seq = c.ms, c.seq+1
+		if seq >= 1<<snowflakeSequenceBits {
+			ms, seq = ms+1, 0
+		}
+	}
+	if ms >= 1<<snowflakeTimeBits {
+		return 0, InvalidArgumentError(fmt.Sprintf("epoch: %s is too long ago for the time since to fit in a snowflake id", epoch.Format(time.RFC3339)))
+	}
+	c.ms, c.seq = ms, seq
+	return ms<<(snowflakeMachineBits+snowflakeSequenceBits) | int64(machine)<<snowflakeSequenceBits | int64(seq), nil
+}
+
+// parseEpoch reads the epoch option of a {snowflake}, which is either a date or an
+// RFC 3339 time
+func parseEpoch(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, InvalidArgumentError(fmt.Sprintf("epoch: %s is not a date such as 2020-01-01, or a time such as 2020-01-01T00:00:00Z", s))
+}
+
+func validateSnowflake(opts cmdOptions) error {
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	epoch, err := parseEpoch(opts["epoch"])
+	if err != nil {
+		return err
+	}
+	if epoch.After(time.Now()) {
+		return InvalidArgumentError(fmt.Sprintf("epoch: %s is in the future. Please check your input string", opts["epoch"]))
+	}
+	if m, err := opts.getInt("machine"); err != nil || m < 0 || m >= 1<<snowflakeMachineBits {
+		return InvalidArgumentError(fmt.Sprintf("machine: %s is not an integer from 0 to %d", opts["machine"], 1<<snowflakeMachineBits-1))
+	}
+	return nil
+}
+
+func snowflake(oc objectCache, rs *runState, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["snowflake"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for snowflakes. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	epoch, err := parseEpoch(opts["epoch"])
+	if err != nil {
+		return "", err
+	}
+	machine, err := opts.getInt("machine")
+	if err != nil {
+		return "", err
+	}
+	id, err := rs.snowflakes.next(oc["clock"].(time.Time), epoch, machine)
+	if err != nil {
+		return "", err
+	}
+	s := strconv.FormatInt(id, 10)
+
+	// store it in the cache
+	c := oc["snowflake"]
+	cache := c.([]string)
+	oc["snowflake"] = append(cache, s)
+
+	return s, nil
+}
+
+// phoneValue is a generated phone number along with how it was written out, so that an
+// ordinal can either repeat it exactly or write the same number out another way
+type phoneValue struct {
+	country   string
+	national  string
+	ext       string
+	formatted string
+}
+
+func validatePhone(opts cmdOptions) error {
+	if f, ok := opts["format"]; ok && f != "national" && f != "e164" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of national or e164", f))
+	}
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	// Any country can be referred to, as phonePlan makes up a plan for those without one
+	c := opts["country"]
+	if _, ok := PhonePlans[strings.ToUpper(c)]; !ok && !isRef(c) && c != "random" {
+		return InvalidArgumentError(fmt.Sprintf("country: %s is not a country phone numbers can be generated for", opts["country"]))
+	}
+	if e := opts["ext"]; e != "true" && e != "false" {
+		return InvalidArgumentError(fmt.Sprintf("ext: %s is not one of true or false", e))
+	}
+	return nil
+}
+
+func phone(oc objectCache, opts cmdOptions) (string, error) {
+	format, reformat := opts["format"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["phone"]
+		cache := c.([]phoneValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for phones. Please check your input string", ord))
+		}
+		// Phone numbers are repeated as they were written out, unless a format is asked for
+		p := cache[ord]
+		if reformat {
+			return formatPhone(p, format), nil
+		}
+		return p.formatted, nil
+	}
+
+	country := strings.ToUpper(opts["country"])
+	if c := opts["country"]; isRef(c) {
+		v, err := oc.lookupRef(c)
+		if err != nil {
+			return "", err
+		}
+		// The country may have been written out in another case
+		country = strings.ToUpper(v.(string))
+	} else if c == "random" {
+		country = phonePlanCodes[oc.rng().Intn(len(phonePlanCodes))]
+	}
+	plan, ok := phonePlan(country)
+	if !ok {
+		// Countries without a calling code of their own, such as AQ, have no phones
+		return "", InvalidArgumentError(fmt.Sprintf("country: %s has no calling code, so a phone number can't be generated for it. Please check your input string", country))
+	}
+	digits := make([]byte, plan.Lengths[oc.rng().Intn(len(plan.Lengths))])
+	for i := range digits {
+		digits[i] = byte('0' + oc.rng().Intn(10))
+	}
+	// National numbers never start with 0, which would be taken as the trunk prefix,
+	// and neither do the area codes or exchanges of the NANP
+	digits[0] = byte('1' + oc.rng().Intn(9))
+	if plan.NANP {
+		digits[0] = byte('2' + oc.rng().Intn(8))
+		digits[3] = byte('2' + oc.rng().Intn(8))
+	}
+	p := phoneValue{country: country, national: string(digits)}
+	if opts["ext"] == "true" {
+		p.ext = strconv.Itoa(100 + oc.rng().Intn(900))
+	}
+	p.formatted = formatPhone(p, format)
+
+	// store it in the cache
+	c := oc["phone"]
+	cache := c.([]phoneValue)
+	oc["phone"] = append(cache, p)
+
+	return p.formatted, nil
+}
+
+// phonePlanCodes are the codes of every country in PhonePlans, in order, so that one can
+// be picked at random
+var phonePlanCodes = func() []string {
+	codes := make([]string, 0, len(PhonePlans))
+	for code := range PhonePlans {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}()
+
+// phonePlan returns the numbering plan of the given country. Those not in PhonePlans,
+// which are only ever generated for because a {country} was referred to, get a plan
+// made up from their calling code: that of the NANP if it's 1, and otherwise 9 digits
+// in groups of 3. It returns false for a country without a calling code.
+func phonePlan(country string) (PhonePlan, bool) {
+	if plan, ok := PhonePlans[country]; ok {
+		return plan, true
+	}
+	code, ok := CountryCallingCodes[country]
+	if !ok {
+		return PhonePlan{}, false
+	}
+	if code == "1" {
+		return PhonePlan{CallingCode: code, Lengths: []int{10}, Groups: []int{3, 3, 4}, Separator: "-", NANP: true}, true
+	}
+	return PhonePlan{CallingCode: code, Lengths: []int{9}, Groups: []int{3, 3, 3}, Separator: " "}, true
+}
+
+// formatPhone writes a phone number out in the given format, which is national unless
+// e164 is asked for. An extension is written after an x in either format.
+func formatPhone(p phoneValue, format string) string {
+	plan, ok := phonePlan(p.country)
+	if !ok {
+		return ""
+	}
+	var s string
+	if format == "e164" {
+		s = "+" + plan.CallingCode + p.national
+	} else {
+		groups := make([]string, 0, len(plan.Groups)+1)
+		rest := p.national
+		for i, size := range plan.Groups {
+			if i == len(plan.Groups)-1 || size >= len(rest) {
+				break
+			}
+			groups = append(groups, rest[:size])
+			rest = rest[size:]
+		}
+		groups = append(groups, rest)
+		groups[0] = plan.TrunkPrefix + groups[0]
+		s = strings.Join(groups, plan.Separator)
+	}
+	if p.ext != "" {
+		s += "x" + p.ext
+	}
+	return s
+}
+
+// capitalCodes are the codes of every country with a capital, in order, so that one can
+// be picked at random
+var capitalCodes = func() []string {
+	codes := make([]string, 0, len(CountryCapitals))
+	for code := range CountryCapitals {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}()
+
+func validateCapital(opts cmdOptions) error {
+	if of := opts["of"]; of != "" && !isRef(of) {
+		return InvalidArgumentError(fmt.Sprintf("of: %s must refer to an earlier {country} token, such as @0. Please check your input string", of))
+	}
+	return nil
+}
+
+func capital(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["capital"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for capitals. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	var s string
+	if of := opts["of"]; of != "" {
+		v, err := oc.lookupRef(of)
+		if err != nil {
+			return "", err
+		}
+		// The country may have been written out in another case. Those without a
+		// capital, such as AQ, have an empty one.
+		s = CountryCapitals[strings.ToUpper(v.(string))]
+	} else {
+		s = CountryCapitals[capitalCodes[oc.rng().Intn(len(capitalCodes))]]
+	}
+
+	// store it in the cache
+	c := oc["capital"]
+	cache := c.([]string)
+	oc["capital"] = append(cache, s)
+
+	return s, nil
+}
+
+// subdivisionValue is a subdivision as it was generated, along with the country it's in
+// so that an ordinal can write it's code
+type subdivisionValue struct {
+	country     string
+	subdivision Subdivision
+	formatted   string
+}
+
+// subdivisionCountries are the codes of every country with subdivisions, in order, so
+// that one can be picked at random
+var subdivisionCountries = func() []string {
+	codes := make([]string, 0, len(CountrySubdivisions))
+	for code := range CountrySubdivisions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}()
+
+func validateSubdivision(opts cmdOptions) error {
+	if of := opts["of"]; of != "" && !isRef(of) {
+		return InvalidArgumentError(fmt.Sprintf("of: %s must refer to an earlier {country} token, such as @0. Please check your input string", of))
+	}
+	if f, ok := opts["format"]; ok && f != "name" && f != "code" {
+		return InvalidArgumentError(fmt.Sprintf("format: %s is not one of name or code", f))
+	}
+	return nil
+}
+
+// formatSubdivision writes a subdivision out as it's name, or as it's full ISO 3166-2
+// code, such as US-CA. A country without subdivisions has an empty one either way.
+func formatSubdivision(s subdivisionValue, format string) string {
+	if s.subdivision.Code == "" {
+		return ""
+	}
+	if format == "code" {
+		return s.country + "-" + s.subdivision.Code
+	}
+	return s.subdivision.Name
+}
+
+func subdivision(oc objectCache, opts cmdOptions) (string, error) {
+	format, reformat := opts["format"]
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["subdivision"]
+		cache := c.([]subdivisionValue)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for subdivisions. Please check your input string", ord))
+		}
+		// Subdivisions are repeated as they were written out, unless a format is asked for
+		s := cache[ord]
+		if reformat {
+			return formatSubdivision(s, format), nil
+		}
+		return s.formatted, nil
+	}
+
+	var country string
+	if of := opts["of"]; of != "" {
+		v, err := oc.lookupRef(of)
+		if err != nil {
+			return "", err
+		}
+		// The country may have been written out in another case
+		country = strings.ToUpper(v.(string))
+	} else {
+		country = subdivisionCountries[oc.rng().Intn(len(subdivisionCountries))]
+	}
+	s := subdivisionValue{country: country}
+	// Countries without subdivisions of their own have an empty one
+	if subdivisions := CountrySubdivisions[country]; len(subdivisions) > 0 {
+		s.subdivision = subdivisions[oc.rng().Intn(len(subdivisions))]
+	}
+	s.formatted = formatSubdivision(s, format)
+
+	// store it in the cache
+	c := oc["subdivision"]
+	cache := c.([]subdivisionValue)
+	oc["subdivision"] = append(cache, s)
+
+	return s.formatted, nil
+}
+
+// numberSpellers spell out an integer in words, keyed by the ISO 639-1 code of the
+// language they spell it in. Please contribute more languages!
+var numberSpellers = map[string]func(int) string{
+	"en": spellEnglish,
+}
+
+// numberWordsInt is the {int} a {numberwords} draws it's number from
+func numberWordsInt(opts cmdOptions) cmdOptions {
+	m := cmdOptions{}
+	for k, v := range defaultOptions["int"] {
+		m[k] = v
+	}
+	m["min"] = opts["min"]
+	m["max"] = opts["max"]
+	return m
+}
+
+func validateNumberWords(opts cmdOptions) error {
+	if _, ok := numberSpellers[opts["lang"]]; !ok {
+		langs := make([]string, 0, len(numberSpellers))
+		for lang := range numberSpellers {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		return InvalidArgumentError(fmt.Sprintf("lang: %s is not one of %s", opts["lang"], strings.Join(langs, ", ")))
+	}
+	if of := opts["of"]; of != "" {
+		if !isRef(of) {
+			return InvalidArgumentError(fmt.Sprintf("of: %s must refer to an earlier {int} token, such as @0. Please check your input string", of))
+		}
+		return nil
+	}
+	if ord, _ := opts.getInt("ordinal"); ord >= 0 {
+		return nil
+	}
+	return validateInt(numberWordsInt(opts))
+}
+
+var (
+	englishOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishScales = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+)
+
+// spellEnglish spells out n in American English, such as one hundred forty-two
+func spellEnglish(n int) string {
+	if n == 0 {
+		return englishOnes[0]
+	}
+	// The magnitude is worked out unsigned, as the smallest int has no positive
+	// counterpart
+	u := uint64(n)
+	if n < 0 {
+		u = -u
+	}
+	var groups []string
+	for scale := 0; u > 0; scale++ {
+		if g := int(u % 1000); g > 0 {
+			words := spellEnglishHundreds(g)
+			if englishScales[scale] != "" {
+				words += " " + englishScales[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		u /= 1000
+	}
+	words := strings.Join(groups, " ")
+	if n < 0 {
+		return "minus " + words
+	}
+	return words
+}
+
+// spellEnglishHundreds spells out a number from 1 to 999
+func spellEnglishHundreds(n int) string {
+	var words []string
+	if n >= 100 {
+		words = append(words, englishOnes[n/100], "hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20 && n%10 != 0:
+		words = append(words, englishTens[n/10]+"-"+englishOnes[n%10])
+	case n >= 20:
+		words = append(words, englishTens[n/10])
+	case n > 0:
+		words = append(words, englishOnes[n])
+	}
+	return strings.Join(words, " ")
+}
+
+func numberWords(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["numberwords"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for number words. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	var n int
+	if of := opts["of"]; of != "" {
+		v, err := oc.lookupRef(of)
+		if err != nil {
+			return "", err
+		}
+		n = v.(int)
+	} else {
+		// The number is drawn just as an {int} with the same range would draw it, but
+		// isn't kept as one, so that it doesn't take up an ordinal of the {int}s
+		s, err := integer(oc, numberWordsInt(opts))
+		if err != nil {
+			return "", err
+		}
+		oc.discardLast("int")
+		if n, err = strconv.Atoi(s); err != nil {
+			return "", err
+		}
+	}
+	words := numberSpellers[opts["lang"]](n)
+
+	// store it in the cache
+	c := oc["numberwords"]
+	cache := c.([]string)
+	oc["numberwords"] = append(cache, words)
+
+	return words, nil
+}
+
+func validatePhonetic(opts cmdOptions) error {
+	if of := opts["of"]; of != "" {
+		if !isRef(of) {
+			return InvalidArgumentError(fmt.Sprintf("of: %s must refer to an earlier token, such as @0. Please check your input string", of))
+		}
+		return nil
+	}
+	if n, err := opts.getInt("length"); err != nil || n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("length: %s is not an integer >= 1", opts["length"]))
+	}
+	return nil
+}
+
+// spellPhonetic spells s out in the NATO phonetic alphabet. Only the letters and digits
+// of ASCII have code words, so anything else is skipped.
+func spellPhonetic(s string) string {
+	words := []string{}
+	for _, c := range strings.ToUpper(s) {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			words = append(words, PhoneticLetters[c-'A'])
+		case c >= '0' && c <= '9':
+			words = append(words, PhoneticDigits[c-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func phonetic(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["phonetic"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for phonetic words. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	var words string
+	if of := opts["of"]; of != "" {
+		v, err := oc.lookupRef(of)
+		if err != nil {
+			return "", err
+		}
+		if i, ok := v.(int); ok {
+			v = strconv.Itoa(i)
+		}
+		words = spellPhonetic(v.(string))
+	} else {
+		n, err := opts.getInt("length")
+		if err != nil {
+			return "", err
+		}
+		if err := oc.checkLimit(n); err != nil {
+			return "", err
+		}
+		w := make([]string, n)
+		for i := range w {
+			w[i] = PhoneticLetters[oc.rng().Intn(len(PhoneticLetters))]
+		}
+		words = strings.Join(w, " ")
+	}
+
+	// store it in the cache
+	c := oc["phonetic"]
+	cache := c.([]string)
+	oc["phonetic"] = append(cache, words)
+
+	return words, nil
+}
+
+// emojiCategories are the names of every category of emoji, in order
+var emojiCategories = func() []string {
+	names := make([]string, 0, len(EmojiByCategory))
+	for name := range EmojiByCategory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}()
+
+// emojiIn returns every emoji in the given categories, or in all of them when there are
+// none, in order so that one can be picked at random
+func emojiIn(categories []string) []string {
+	if len(categories) == 0 {
+		categories = emojiCategories
+	}
+	var all []string
+	for _, name := range categories {
+		all = append(all, EmojiByCategory[name]...)
+	}
+	return all
+}
+
+func validateEmoji(opts cmdOptions) error {
+	if c := opts["category"]; c != "" {
+		for _, name := range strings.Split(c, ",") {
+			if _, ok := EmojiByCategory[name]; !ok {
+				return InvalidArgumentError(fmt.Sprintf("category: %s is not one of %s", name, strings.Join(emojiCategories, ", ")))
+			}
+		}
+	}
+	if n, err := opts.getInt("count"); err != nil || n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("count: %s is not an integer >= 1", opts["count"]))
+	}
+	return nil
+}
+
+func emoji(oc objectCache, opts cmdOptions) (string, error) {
+	ord, err := opts.getInt("ordinal")
+	if err != nil {
+		return "", err
+	}
+
+	if ord >= 0 {
+		c := oc["emoji"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for emoji. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	n, err := opts.getInt("count")
+	if err != nil {
+		return "", err
+	}
+	if err := oc.checkLimit(n); err != nil {
+		return "", err
+	}
+	var categories []string
+	if c := opts["category"]; c != "" {
+		categories = strings.Split(c, ",")
+	}
+	// Each emoji is picked whole, so one made of several code points is never split up
+	all := emojiIn(categories)
+	result := &bytes.Buffer{}
+	for i := 0; i < n; i++ {
+		result.WriteString(all[oc.rng().Intn(len(all))])
+	}
+	s := result.String()
+
+	// store it in the cache
+	c := oc["emoji"]
+	cache := c.([]string)
+	oc["emoji"] = append(cache, s)
+
+	return s, nil
+}
+
+// loadChoiceFile returns the values in the file at path within fsys, one per line,
+// ignoring blank lines
+func loadChoiceFile(fsys fs.FS, path string) ([]string, error) {
+	if fsys == nil {
+		return nil, InvalidArgumentError(fmt.Sprintf("file: %s can't be read, as BuildCallstack was not given WithFiles", path))
+	}
+	b, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, InvalidArgumentError(fmt.Sprintf("file: %s can't be read: %v", path, err))
+	}
+	values := make([]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			values = append(values, line)
+		}
+	}
+	if len(values) == 0 {
+		return nil, InvalidArgumentError(fmt.Sprintf("file: %s has no values in it", path))
+	}
+	return values, nil
+}
+
+// choiceTable is what a {choice} picks from, worked out once when the template is
+// parsed: every value, including the other, and the running total of their weights
+type choiceTable struct {
+	values  []string
+	weights []float64
+}
+
+// prepareChoice splits up the values of a {choice} and adds up it's weights, or reads
+// the file it picks it's values from, once when the template is parsed rather than for
+// every result
+func prepareChoice(opts cmdOptions, cfg *parseConfig) (interface{}, error) {
+	if opts["ordinal"] != "-1" {
+		return nil, nil
+	}
+	if file := opts["file"]; file != "" {
+		values, err := loadChoiceFile(cfg.files, file)
+		if err != nil {
+			return nil, err
+		}
+		return choiceTable{values: values}, nil
+	}
+	weights, err := choiceWeights(opts)
+	if err != nil {
+		return nil, err
+	}
+	values := splitChoices(opts["of"])
+	if other, ok := opts["other"]; ok {
+		values = append(values, other)
+	}
+	return choiceTable{values: values, weights: weights}, nil
+}
+
+// splitChoices splits the values given to a {choice} around each comma, other than
+// those inside of a token's braces, and those escaped as \,
+func splitChoices(s string) []string {
+	values := []string{}
+	value := &bytes.Buffer{}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && depth == 0 && i+1 < len(s) && s[i+1] == ',':
+			value.WriteByte(',')
+			i++
+		case c == ',' && depth == 0:
+			values = append(values, value.String())
+			value.Reset()
+		default:
+			if c == '{' {
+				depth++
+			} else if c == '}' && depth > 0 {
+				depth--
+			}
+			value.WriteByte(c)
+		}
+	}
+	return append(values, value.String())
+}
+
+// choiceOf builds the closure for a {choice} between values which have tokens in them.
+// Each value is a template of it's own, and the one picked is generated in it's own
+// scope, like the body of a {repeat}. What it generates goes into the cache, so that
+// ordinals can refer back to it like any other choice.
+func choiceOf(parent *Callstack, opts cmdOptions, cfg *parseConfig) (tokenWriter, error) {
+	values := splitChoices(opts["of"])
+	if other, ok := opts["other"]; ok {
+		values = append(values, other)
+	}
+	weights, err := choiceWeights(opts)
+	if err != nil {
+		return nil, err
+	}
+	bodies := make([]*Callstack, len(values))
+	for i, v := range values {
+		body, err := buildCallstack(v, cfg.nested(false))
+		if err != nil {
+			return nil, err
+		}
+		bodies[i] = body
+	}
+	parent.children = append(parent.children, bodies...)
+	return func(result *bytes.Buffer, cache objectCache) error {
+		value := &bytes.Buffer{}
+		if err := bodies[pickChoice(cache.rng(), len(bodies), weights)].write(value, cache.scope()); err != nil {
+			return err
+		}
+		c := cache["choice"]
+		cache["choice"] = append(c.([]string), value.String())
+		result.Write(value.Bytes())
+		return nil
+	}, nil
+}
+
+func validateChoice(opts cmdOptions) error {
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	of, file := opts["of"], opts["file"]
+	if (of == "") == (file == "") {
+		return InvalidArgumentError("Exactly one of of or file must be given to {choice}. Please check your input string")
+	}
+	if file != "" {
+		if _, ok := opts["weights"]; ok {
+			return InvalidArgumentError("weights: can only be given along with of, rather than file. Please check your input string")
+		}
+	}
+	// The weights are checked as they're added up, when the token is prepared
+	return nil
+}
+
+// choiceWeights returns the running total of the percentages given by the weights
+// option of a {choice}, one for each of it's values, or nil if none were given so that
+// every value is as likely as every other. Whatever is left of 100 goes to the value
+// given by other, which must be given unless they add up to 100 already.
+func choiceWeights(opts cmdOptions) ([]float64, error) {
+	w, ok := opts["weights"]
+	if !ok {
+		if _, ok := opts["other"]; ok {
+			return nil, InvalidArgumentError("other: can only be given along with weights, as it gets whatever they leave of 100. Please check your input string")
+		}
+		return nil, nil
+	}
+	parts := strings.Split(w, ",")
+	if n := len(splitChoices(opts["of"])); len(parts) != n {
+		return nil, InvalidArgumentError(fmt.Sprintf("weights: %s has %d weights, but there are %d values. Please check your input string", w, len(parts), n))
+	}
+	sums := make([]float64, len(parts))
+	total := 0.0
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || n < 0 || math.IsInf(n, 0) {
+			return nil, InvalidArgumentError(fmt.Sprintf("weights: %s is not a percentage of 0 or more. Please check your input string", p))
+		}
+		total += n
+		sums[i] = total
+	}
+	// Allow for the rounding of weights such as 33.3,33.3,33.4
+	const slack = 1e-9
+	if total > 100+slack {
+		return nil, InvalidArgumentError(fmt.Sprintf("weights: %s add up to %g%%, which is more than 100%%. Please check your input string", w, total))
+	}
+	if _, ok := opts["other"]; !ok && total < 100-slack {
+		return nil, InvalidArgumentError(fmt.Sprintf("weights: %s add up to %g%%, so other must be given to take the rest. Please check your input string", w, total))
+	}
+	if total >= 100-slack {
+		// The other is never picked, even by rounding
+		sums[len(sums)-1] = math.Inf(1)
+	}
+	return sums, nil
+}
+
+// pickChoice picks which of n values a {choice} writes, given the running total of
+// their weights from choiceWeights. When the weights leave some of 100 for the other
+// value, which comes last, n counts it as well.
+func pickChoice(r *rand.Rand, n int, sums []float64) int {
+	if sums == nil {
+		return r.Intn(n)
+	}
+	x := r.Float64() * 100
+	for i, s := range sums {
+		if x < s {
+			return i
+		}
+	}
+	return len(sums)
+}
+
+func choice(oc objectCache, opts cmdOptions, prep interface{}) (string, error) {
+	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
-	max, err := opts.getInt("max")
-	if err != nil {
-		return "", err
+
+	if ord >= 0 {
+		c := oc["choice"]
+		cache := c.([]string)
+		if len(cache)-1 < ord {
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for choices. Please check your input string", ord))
+		}
+		return cache[ord], nil
+	}
+
+	table := prep.(choiceTable)
+	v := table.values[pickChoice(oc.rng(), len(table.values), table.weights)]
+
+	// store it in the cache
+	c := oc["choice"]
+	cache := c.([]string)
+	oc["choice"] = append(cache, v)
+
+	return v, nil
+}
+
+// maskShow is how much of a value a {mask} leaves visible
+type maskShow struct {
+	// first and last are how many letters and digits to show from the start and end
+	first, last int
+	// domain shows everything from the @ of an email address onwards
+	domain bool
+}
+
+// parseMaskShow parses a comma separated list of firstN, lastN and domain
+func parseMaskShow(s string) (maskShow, error) {
+	var show maskShow
+	for _, p := range strings.Split(s, ",") {
+		var n *int
+		switch {
+		case p == "none":
+			continue
+		case p == "domain":
+			show.domain = true
+			continue
+		case strings.HasPrefix(p, "first"):
+			n, p = &show.first, strings.TrimPrefix(p, "first")
+		case strings.HasPrefix(p, "last"):
+			n, p = &show.last, strings.TrimPrefix(p, "last")
+		default:
+			return show, InvalidArgumentError(fmt.Sprintf("show: %s is not one of none, domain, firstN or lastN", p))
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return show, InvalidArgumentError(fmt.Sprintf("show: %s must be followed by an integer >= 0", s))
+		}
+		*n = v
+	}
+	return show, nil
+}
+
+func validateMask(opts cmdOptions) error {
+	if opts["of"] == "" {
+		return InvalidArgumentError("of: {mask} must be given a token to mask. Please check your input string")
+	}
+	if utf8.RuneCountInString(opts["char"]) != 1 {
+		return InvalidArgumentError(fmt.Sprintf("char: %s must be a single character", opts["char"]))
+	}
+	_, err := parseMaskShow(opts["show"])
+	return err
+}
+
+// maskValue replaces every letter and digit of s with c, other than those that show
+// leaves visible. Everything else, such as the dashes of a card number, is kept so that
+// the value keeps it's shape.
+func maskValue(s string, show maskShow, c rune) string {
+	r := []rune(s)
+	end := len(r)
+	if show.domain {
+		if at := strings.LastIndex(s, "@"); at >= 0 {
+			end = utf8.RuneCountInString(s[:at])
+		}
+	}
+	// Find where the visible letters and digits at either end stop
+	masked := make([]bool, end)
+	for i := range masked {
+		masked[i] = uni.IsLetter(r[i]) || uni.IsDigit(r[i])
 	}
-	ord, err := opts.getInt("ordinal")
-	if err != nil {
-		return "", err
+	for i, n := 0, show.first; i < end && n > 0; i++ {
+		if masked[i] {
+			masked[i] = false
+			n--
+		}
 	}
-
-	if ord >= 0 {
-		c := oc["int"]
-		cache := c.([]int)
-		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for integers. Please check your input string", ord))
+	for i, n := end-1, show.last; i >= 0 && n > 0; i-- {
+		if masked[i] {
+			masked[i] = false
+			n--
 		}
-		i := cache[ord]
-		return strconv.Itoa(i), nil
 	}
-
-	if min > max {
-		return "", InvalidArgumentError("You cannot generate a random number whose lower bound is greater than it's upper bound. Please check your input string")
+	for i, m := range masked {
+		if m {
+			r[i] = c
+		}
 	}
+	return string(r)
+}
 
-	// Incase we need to tell the function to invert the case
-	negateResult := false
-	// get the difference between them
-	diff := max - min
-	// Since this supports negatives, need to handle some special corner cases?
-	if max < 0 && min <= 0 {
-		// if the range is entirely negative
-		negateResult = true
-		// Swap the min, diff will be the same, but minimum is now inverted vs it's old value
-		// trip the flag to negate the overall result
-		min = -max
+// mask builds the closure for a {mask} token, which generates the value of the token
+// given by it's of option and then masks all but part of it. Like {repeat}, the value is
+// generated in it's own scope.
+func mask(parent *Callstack, opts cmdOptions, cfg *parseConfig) (tokenWriter, error) {
+	show, err := parseMaskShow(opts["show"])
+	if err != nil {
+		return nil, err
 	}
-	// neg to pos ranges currently not supported
-	// else both are positive
-	// get a number from 0 to diff
-	n := rand.Intn(diff)
-	// add lowerbound to it - now it's between lower and upper
-	n += min
-	if negateResult {
-		n = -n
+	c, _ := utf8.DecodeRuneInString(opts["char"])
+	body, err := buildCallstack(wrappedTemplate("mask", opts), cfg.nested(false))
+	if err != nil {
+		return nil, err
 	}
+	parent.children = append(parent.children, body)
+	return func(result *bytes.Buffer, cache objectCache) error {
+		value := &bytes.Buffer{}
+		if err := body.write(value, cache.scope()); err != nil {
+			return err
+		}
+		result.WriteString(maskValue(value.String(), show, c))
+		return nil
+	}, nil
+}
 
-	// store it in the cache
-	ca := oc["int"]
-	cache := ca.([]int)
-	oc["int"] = append(cache, n)
+// boolValue is a generated boolean along with the strings it was written out with, so
+// that a reference to it can write it or it's inverse the same way
+type boolValue struct {
+	v       bool
+	yes, no string
+}
 
-	return strconv.Itoa(n), nil
+// write returns the string for v, using the strings given to a {bool} where it has
+// them, and those of b where it doesn't
+func (b boolValue) write(v bool, opts cmdOptions) string {
+	t, ok := opts["true"]
+	if !ok {
+		t = b.yes
+	}
+	f, ok := opts["false"]
+	if !ok {
+		f = b.no
+	}
+	if v {
+		return t
+	}
+	return f
 }
 
-func float(oc objectCache, opts cmdOptions) (string, error) {
-	min, err := opts.getFloat("min")
-	if err != nil {
-		return "", err
+func validateBool(opts cmdOptions) error {
+	chance, err := opts.getFloat("chance")
+	if err != nil || chance < 0 || chance > 1 {
+		return InvalidArgumentError(fmt.Sprintf("chance: %s is not a number from 0 to 1", opts["chance"]))
 	}
-	max, err := opts.getFloat("max")
-	if err != nil {
-		return "", err
+	if not := opts["not"]; not != "" {
+		if !isRef(not) {
+			return InvalidArgumentError(fmt.Sprintf("not: %s must refer to an earlier {bool} token, such as @0. Please check your input string", not))
+		}
+		if opts["ordinal"] != "-1" {
+			return InvalidArgumentError("not: A {bool} can't both be the inverse of one token and refer back to another. Please check your input string")
+		}
+	}
+	if t, f := opts["true"], opts["false"]; t == f && t != "" {
+		return InvalidArgumentError(fmt.Sprintf("true: %s can't be the same as false. Please check your input string", t))
 	}
+	return nil
+}
+
+func boolean(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
 
 	if ord >= 0 {
-		c := oc["float"]
-		cache := c.([]float64)
+		c := oc["bool"]
+		cache := c.([]boolValue)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for floats. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for bools. Please check your input string", ord))
 		}
-		n := cache[ord]
-		return fmt.Sprintf("%f", n), nil
+		// Bools go into the cache with the strings they were written out with, only
+		// change them on request
+		return cache[ord].write(cache[ord].v, opts), nil
 	}
 
-	if min > max {
-		return "", InvalidArgumentError("You cannot generate a random number whose lower bound is greater than it's upper bound. Please check your input string")
+	b := boolValue{yes: "true", no: "false"}
+	if not := opts["not"]; not != "" {
+		v, err := oc.lookupRef(not)
+		if err != nil {
+			return "", err
+		}
+		// The inverse is written with the same strings as the original, unless it
+		// gives it's own
+		b = v.(boolValue)
+		b.v = !b.v
+	} else {
+		chance, err := opts.getFloat("chance")
+		if err != nil {
+			return "", err
+		}
+		b.v = oc.rng().Float64() < chance
 	}
-
-	// Incase we need to tell the function to invert the case
-	negateResult := false
-	// get the difference between them
-	diff := max - min
-	// Since this supports negatives, need to handle some special corner cases?
-	if min < 0.0 && max <= 0.0 {
-		// if the range is entirely negative
-		negateResult = true
-		// Swap the min, diff will be the same, but minimum is now inverted vs it's old value
-		// trip the flag to negate the overall result
-		min = -max
+	s := b.write(b.v, opts)
+	if t, ok := opts["true"]; ok {
+		b.yes = t
 	}
-	// neg to pos ranges currently not supported
-	// else both are positive
-	// get a number from 0 to diff
-	n := (rand.Float64() * diff) + min
-	if negateResult {
-		n = -n
+	if f, ok := opts["false"]; ok {
+		b.no = f
 	}
 
 	// store it in the cache
-	ca := oc["float"]
-	cache := ca.([]float64)
-	oc["float"] = append(cache, n)
+	c := oc["bool"]
+	cache := c.([]boolValue)
+	oc["bool"] = append(cache, b)
 
-	return fmt.Sprintf("%f", n), nil
+	return s, nil
 }
 
-func country(oc objectCache, opts cmdOptions) (string, error) {
-	cCase := opts["case"]
+func validateCycle(opts cmdOptions) error {
+	if opts["ordinal"] == "-1" && opts["of"] == "" {
+		return InvalidArgumentError("of: {cycle} must be given the values to cycle through. Please check your input string")
+	}
+	return nil
+}
+
+// cycle writes out the values given by it's of option in turn, rather than at random,
+// starting again from the first once it has written the last. Like a {semver} sequence,
+// it carries on from one Write to the next, until the Callstack is Reset.
+func cycle(oc objectCache, rs *runState, pos int, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
 
 	if ord >= 0 {
-		c := oc["country"]
+		c := oc["cycle"]
 		cache := c.([]string)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for countries. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for cycles. Please check your input string", ord))
 		}
-		country := cache[ord]
-		// Countries go into the cache upper case, only check for lowering it
-		if cCase == "down" {
-			return strings.ToLower(country), nil
-		}
-		return country, nil
+		return cache[ord], nil
 	}
-	// Generate a new one
-	n := rand.Intn(len(CountryCodes))
-	country := CountryCodes[n]
+
+	values := splitChoices(opts["of"])
+	v := values[rs.next(pos)%len(values)]
+
 	// store it in the cache
-	ca := oc["country"]
-	cache := ca.([]string)
-	oc["country"] = append(cache, country)
+	c := oc["cycle"]
+	cache := c.([]string)
+	oc["cycle"] = append(cache, v)
+
+	return v, nil
+}
+
+// byteUnits are the units a {bytes} is written out in, for each base
+var byteUnits = map[string][]string{
+	"1024": {"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"},
+	"1000": {"B", "KB", "MB", "GB", "TB", "PB", "EB"},
+}
 
-	if cCase == "down" {
-		return strings.ToLower(country), nil
+// bytesValue is a generated number of bytes along with how it was written out, so that
+// an ordinal can either repeat it exactly or write the same size out another way
+type bytesValue struct {
+	n         int64
+	formatted string
+}
+
+func validateBytes(opts cmdOptions) error {
+	if b, ok := opts["base"]; ok && byteUnits[b] == nil {
+		return InvalidArgumentError(fmt.Sprintf("base: %s is not one of 1024 or 1000", b))
+	}
+	if p, ok := opts["precision"]; ok {
+		if n, err := strconv.Atoi(p); err != nil || n < 0 {
+			return InvalidArgumentError(fmt.Sprintf("precision: %s is not an integer >= 0", p))
+		}
+	}
+	if opts["ordinal"] != "-1" {
+		return nil
+	}
+	min, err := strconv.ParseInt(opts["min"], 10, 64)
+	if err != nil || min < 0 {
+		return InvalidArgumentError(fmt.Sprintf("min: %s is not an integer >= 0", opts["min"]))
+	}
+	max, err := strconv.ParseInt(opts["max"], 10, 64)
+	if err != nil || max < min {
+		return InvalidArgumentError(fmt.Sprintf("max: %s is not an integer >= min", opts["max"]))
 	}
-	return country, nil
+	return nil
 }
 
-func unicode(oc objectCache, opts cmdOptions) (string, error) {
-	cCase := opts["case"]
-	num, err := opts.getInt("length")
-	if err != nil {
-		return "", err
-	} else if num <= 0 {
-		return "", InvalidArgumentError("You have specified a number of characters to generate which is not a number greater than zero. Please check your input string")
+// formatBytes writes n out in the largest unit of the base it is at least one of, such
+// as 1.5 KiB. Anything less than one of the smallest unit is written in whole bytes.
+func formatBytes(n int64, opts cmdOptions) string {
+	base, ok := opts["base"]
+	if !ok {
+		base = "1024"
 	}
+	precision := 1
+	if p, ok := opts["precision"]; ok {
+		precision, _ = strconv.Atoi(p)
+	}
+	units := byteUnits[base]
+	step := 1024.0
+	if base == "1000" {
+		step = 1000
+	}
+	v := float64(n)
+	u := 0
+	for v >= step && u < len(units)-1 {
+		v /= step
+		u++
+	}
+	if u == 0 {
+		return strconv.FormatInt(n, 10) + " " + units[0]
+	}
+	return strconv.FormatFloat(v, 'f', precision, 64) + " " + units[u]
+}
+
+func byteSize(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
 
 	if ord >= 0 {
-		c := oc["unicode"]
-		cache := c.([]string)
+		c := oc["bytes"]
+		cache := c.([]bytesValue)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for unicode strings. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for bytes. Please check your input string", ord))
 		}
-		str := cache[ord]
-		// Countries go into the cache upper case, only check for lowering it
-		if cCase == "up" {
-			return strings.ToUpper(str), nil
+		// Sizes go into the cache as they were written out, only change their base or
+		// precision on request
+		_, rebase := opts["base"]
+		_, reprecision := opts["precision"]
+		if rebase || reprecision {
+			return formatBytes(cache[ord].n, opts), nil
 		}
-		return str, nil
-	}
-
-	result := generateRandomString(num)
-	// store it in the cache
-	ca := oc["unicode"]
-	cache := ca.([]string)
-	oc["unicode"] = append(cache, result)
-	if cCase == "up" {
-		return strings.ToUpper(string(result)), nil
+		return cache[ord].formatted, nil
 	}
-	return string(result), nil
-}
 
-func ascii(oc objectCache, opts cmdOptions) (string, error) {
-	cCase := opts["case"]
-	num, err := opts.getInt("length")
+	min, err := strconv.ParseInt(opts["min"], 10, 64)
 	if err != nil {
 		return "", err
-	} else if num <= 0 {
-		return "", InvalidArgumentError("You have specified a number of characters to generate which is not a number greater than zero. Please check your input string")
 	}
-	ord, err := opts.getInt("ordinal")
+	max, err := strconv.ParseInt(opts["max"], 10, 64)
 	if err != nil {
 		return "", err
 	}
-
-	if ord >= 0 {
-		c := oc["ascii"]
-		cache := c.([]string)
-		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for ASCII strings. Please check your input string", ord))
-		}
-		str := cache[ord]
-		// Countries go into the cache upper case, only check for lowering it
-		if cCase == "up" {
-			return strings.ToUpper(str), nil
-		}
-		return str, nil
+	n := min
+	if span := max - min; span == math.MaxInt64 {
+		n = oc.rng().Int63()
+	} else if span > 0 {
+		n += oc.rng().Int63n(span + 1)
 	}
+	s := formatBytes(n, opts)
 
-	result := generateRandomASCIIString(num)
 	// store it in the cache
-	ca := oc["ascii"]
-	cache := ca.([]string)
-	oc["ascii"] = append(cache, result)
-	if cCase == "up" {
-		return strings.ToUpper(string(result)), nil
-	}
-	return string(result), nil
-}
-
-func generateRandomASCIIString(length int) string {
-	// This also includes numbers which is questionable, however since when folks want to
-	// work with ascii strings, they anticipate 0-9 as well. Open to changing this if need be.
-	var letters = []rune("0123456789abcdefghijklmnopqrstuvwxy")
+	c := oc["bytes"]
+	cache := c.([]bytesValue)
+	oc["bytes"] = append(cache, bytesValue{n: n, formatted: s})
 
-	b := make([]rune, length)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
+	return s, nil
 }
 
-func generateRandomString(length int) string {
-	rarr := make([]rune, length)
-	for i := 0; i < length; i++ {
-		// First, pick which range this character comes from
-		r := PrintableRanges[rand.Intn(len(PrintableRanges))]
-
-		minCharCode := r[0]
-		maxCharCode := r[1]
-
-		// Get the delata between max and min
-		diff := maxCharCode - minCharCode
-		// Get a random value within the range specified
-		num := rand.Intn(diff) + minCharCode
-		// Turn it into a rune, set it on the result object
-		rarr[i] = rune(num)
+func validateWord(opts cmdOptions) error {
+	if opts["ordinal"] != "-1" {
+		return nil
 	}
-	return string(rarr)
+	if opts["style"] != "pronounceable" {
+		return InvalidArgumentError(fmt.Sprintf("style: %s is not pronounceable", opts["style"]))
+	}
+	if n, err := opts.getInt("length"); err != nil || n < 1 {
+		return InvalidArgumentError(fmt.Sprintf("length: %s is not an integer >= 1", opts["length"]))
+	}
+	return nil
 }
 
-func now(oc objectCache, opts cmdOptions) (string, error) {
-	loc, err := time.LoadLocation(opts["zone"])
-	if err != nil {
-		return "", err
+// pronounceableWord builds a word of exactly length letters, from consonant and vowel
+// sounds taken in turn. It starts with either, so words like "avoki" come up as well as
+// "bavoki". Near the end, only sounds short enough to fit are picked.
+func pronounceableWord(r *rand.Rand, length int) string {
+	var b strings.Builder
+	vowel := r.Intn(2) == 0
+	for b.Len() < length {
+		sounds := Consonants
+		if vowel {
+			sounds = Vowels
+		}
+		s := sounds[r.Intn(len(sounds))]
+		for len(s) > length-b.Len() {
+			s = sounds[r.Intn(len(sounds))]
+		}
+		b.WriteString(s)
+		vowel = !vowel
 	}
+	return b.String()
+}
 
+func madeUpWord(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
+
 	if ord >= 0 {
-		c := oc["now"]
+		c := oc["word"]
 		cache := c.([]string)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for time-now. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for words. Please check your input string", ord))
 		}
-		return cache[ord], nil
+		// Words go into the cache before any case was applied, and are only re-cased
+		// on request
+		return applyCase(cache[ord], opts["case"]), nil
 	}
-	now := time.Now().In(loc)
-	ts := formatTime(&now, opts["format"])
 
+	length, err := opts.getInt("length")
+	if err != nil {
+		return "", err
+	}
+	if err := oc.checkLimit(length); err != nil {
+		return "", err
+	}
+	result := pronounceableWord(oc.rng(), length)
 	// store it in the cache
-	c := oc["now"]
+	c := oc["word"]
 	cache := c.([]string)
-	oc["now"] = append(cache, ts)
-	return ts, nil
+	oc["word"] = append(cache, result)
+	return applyCase(result, opts["case"]), nil
 }
 
-func datetime(oc objectCache, opts cmdOptions) (string, error) {
-	min, err := opts.getInt("min")
-	if err != nil {
-		return "", err
+// parseDepth reads a {jsonpath} depth, which is either a single number or a range of
+// them such as 2-4, into it's min and max
+func parseDepth(s string) (int, int, bool) {
+	lo, hi := s, s
+	if !plainNumber(s, false) {
+		var ok bool
+		if lo, hi, ok = parseRangeShorthand(s, false); !ok {
+			return 0, 0, false
+		}
 	}
-	max, err := opts.getInt("max")
+	min, err := strconv.Atoi(lo)
 	if err != nil {
-		return "", err
+		return 0, 0, false
 	}
-	if min > max {
-		return "", InvalidArgumentError("You cannot generate a random time whose lower bound is greater than it's upper bound. Please check your input string")
+	max, err := strconv.Atoi(hi)
+	if err != nil || min < 1 || max < min {
+		return 0, 0, false
 	}
+	return min, max, true
+}
 
-	z := opts["zone"]
-	loc, err := time.LoadLocation(z)
-	if err != nil {
-		return "", err
+func validateJSONPath(opts cmdOptions) error {
+	if opts["ordinal"] != "-1" {
+		return nil
 	}
+	if _, _, ok := parseDepth(opts["depth"]); !ok {
+		return InvalidArgumentError(fmt.Sprintf("depth: %s is not an integer >= 1, or a range of them such as 2-4", opts["depth"]))
+	}
+	return nil
+}
 
-	f := opts["format"]
+func jsonPath(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
+
 	if ord >= 0 {
-		c := oc["time"]
+		c := oc["jsonpath"]
 		cache := c.([]string)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for time-now. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for jsonpath. Please check your input string", ord))
 		}
 		return cache[ord], nil
 	}
-	// get the difference between them
-	diff := max - min
-	var ut int64
-	// Get a random value from 0 to the delta, and add the minimum
-	// Due to an issue with Int63n, you cannot pass it a 0
-	if diff > 0 {
-		ut = rand.Int63n(int64(diff)) + int64(min)
-	} else {
-		ut = int64(min)
+
+	min, max, ok := parseDepth(opts["depth"])
+	if !ok {
+		return "", InvalidArgumentError(fmt.Sprintf("depth: %s is not an integer >= 1, or a range of them such as 2-4", opts["depth"]))
 	}
-	// Get the time at that value
-	t := time.Unix(ut, 0).In(loc)
-	ts := formatTime(&t, f)
+	// Each segment is a made up word, which keeps them readable and means they're always
+	// valid identifiers, as they only ever have lower case letters in them
+	depth := min + oc.rng().Intn(max-min+1)
+	if err := oc.checkLimit(depth); err != nil {
+		return "", err
+	}
+	segments := make([]string, depth)
+	for i := range segments {
+		segments[i] = pronounceableWord(oc.rng(), 3+oc.rng().Intn(6))
+	}
+	s := strings.Join(segments, ".")
+
 	// store it in the cache
-	c := oc["time"]
+	c := oc["jsonpath"]
 	cache := c.([]string)
-	oc["time"] = append(cache, ts)
+	oc["jsonpath"] = append(cache, s)
 
-	return ts, nil
+	return s, nil
 }
 
-func formatTime(t *time.Time, format string) string {
-	if f, ok := TimeFormats[format]; ok {
-		return t.Format(f)
+// maxJSONDepth is the deepest that a {jsonvalue} can nest arrays and objects, which keeps
+// the size of each value in check
+const maxJSONDepth = 8
+
+// jsonAwkward are the pieces of text a {jsonvalue} string is sometimes made of, which
+// need escaping or are otherwise easy to mishandle
+var jsonAwkward = []string{`"`, `\`, "/", "\n", "\t", "\x00", "\u2028", "é", "日本", "😀", "</script>", ""}
+
+func validateJSONValue(opts cmdOptions) error {
+	if n, err := opts.getInt("maxdepth"); err != nil || n < 0 || n > maxJSONDepth {
+		return InvalidArgumentError(fmt.Sprintf("maxdepth: %s is not an integer from 0 to %d", opts["maxdepth"], maxJSONDepth))
 	}
-	return t.Format(format)
+	return nil
 }
 
-func guid(oc objectCache, opts cmdOptions) (string, error) {
+// writeJSONValue writes out a random JSON value, which is an array or object only while
+// depth is above 0, each of whose values is nested one deeper
+func writeJSONValue(r *rand.Rand, buf *bytes.Buffer, depth int) {
+	kinds := 4
+	if depth > 0 {
+		kinds = 6
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		buf.WriteString("null")
+	case 1:
+		buf.WriteString(strconv.FormatBool(r.Intn(2) == 0))
+	case 2:
+		if r.Intn(2) == 0 {
+			buf.WriteString(strconv.Itoa(r.Intn(2000001) - 1000000))
+		} else {
+			buf.WriteString(strconv.FormatFloat(r.NormFloat64()*1000, 'g', -1, 64))
+		}
+	case 3:
+		writeJSONString(r, buf)
+	case 4:
+		buf.WriteByte('[')
+		for i, n := 0, r.Intn(4); i < n; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONValue(r, buf, depth-1)
+		}
+		buf.WriteByte(']')
+	default:
+		buf.WriteByte('{')
+		seen := make(map[string]bool)
+		for i, n := 0, r.Intn(4); i < n; i++ {
+			// Keys are made up words, and any which repeats one already used is left out
+			key := pronounceableWord(r, 3+r.Intn(6))
+			if seen[key] {
+				continue
+			}
+			if len(seen) > 0 {
+				buf.WriteByte(',')
+			}
+			seen[key] = true
+			b, _ := json.Marshal(key)
+			buf.Write(b)
+			buf.WriteByte(':')
+			writeJSONValue(r, buf, depth-1)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+// writeJSONString writes out a JSON string, which is either a made up word, or a few
+// pieces of awkward text
+func writeJSONString(r *rand.Rand, buf *bytes.Buffer) {
+	var s string
+	if r.Intn(2) == 0 {
+		s = pronounceableWord(r, 1+r.Intn(10))
+	} else {
+		for i, n := 0, 1+r.Intn(3); i < n; i++ {
+			s += jsonAwkward[r.Intn(len(jsonAwkward))]
+		}
+	}
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+func jsonValue(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
+
 	if ord >= 0 {
-		c := oc["guid"]
+		c := oc["jsonvalue"]
 		cache := c.([]string)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for guids. Please check your input string", ord))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for JSON values. Please check your input string", ord))
 		}
 		return cache[ord], nil
 	}
 
-	guid := uuidv4()
+	depth, err := opts.getInt("maxdepth")
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	writeJSONValue(oc.rng(), buf, depth)
+	s := buf.String()
+
 	// store it in the cache
-	c := oc["guid"]
+	c := oc["jsonvalue"]
 	cache := c.([]string)
-	oc["guid"] = append(cache, guid)
+	oc["jsonvalue"] = append(cache, s)
 
-	return guid, nil
+	return s, nil
 }
 
-func firstname(oc objectCache, opts cmdOptions) (string, error) {
-	return name("firstname", FirstNames, oc, opts)
+func validateTraceparent(opts cmdOptions) error {
+	if s := opts["sampled"]; s != "true" && s != "false" {
+		return InvalidArgumentError(fmt.Sprintf("sampled: %s is not one of true or false", s))
+	}
+	return nil
 }
 
-func lastname(oc objectCache, opts cmdOptions) (string, error) {
-	return name("lastname", LastNames, oc, opts)
+// nonZeroBytes makes a slice of n random bytes, which are not all zero, as the ids in
+// a traceparent would then be invalid
+func nonZeroBytes(r *rand.Rand, n int) []byte {
+	for {
+		b := randomBytes(r, n)
+		for _, c := range b {
+			if c != 0 {
+				return b
+			}
+		}
+	}
 }
 
-func name(nameType string, names []*Name, oc objectCache, opts cmdOptions) (string, error) {
-	cCase := opts["case"]
-	lang := opts["language"]
-	if !KnownLanguage(lang) {
-		return "", InvalidArgumentError(fmt.Sprintf("language: %s is not a known language", lang))
-	}
+func traceparent(oc objectCache, opts cmdOptions) (string, error) {
 	ord, err := opts.getInt("ordinal")
 	if err != nil {
 		return "", err
 	}
 
 	if ord >= 0 {
-		c := oc[nameType]
+		c := oc["traceparent"]
 		cache := c.([]string)
 		if len(cache)-1 < ord {
-			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for %s values. Please check your input string", ord, nameType))
+			return "", InvalidArgumentError(fmt.Sprintf("Ordinal %d has not yet been encountered for traceparent. Please check your input string", ord))
 		}
-		str := cache[ord]
-		// Names go into the cache as camel case, check if we need to swap it
-		if cCase == "up" {
-			return strings.ToUpper(str), nil
-		} else if cCase == "down" {
-			return strings.ToLower(str), nil
-		}
-		return str, nil
+		return cache[ord], nil
 	}
 
-	// Generate a new one
-	n := rand.Intn(len(names))
-	name := names[n]
-	result := name.GetSpelling(lang)
+	// Version 00 is the only one defined, and the only flag it has is sampled, in the
+	// lowest bit
+	flags := "00"
+	if opts["sampled"] == "true" {
+		flags = "01"
+	}
+	s := fmt.Sprintf("00-%x-%x-%s", nonZeroBytes(oc.rng(), 16), nonZeroBytes(oc.rng(), 8), flags)
 
 	// store it in the cache
-	ca := oc[nameType]
-	cache := ca.([]string)
-	oc[nameType] = append(cache, result)
-	if cCase == "up" {
-		return strings.ToUpper(string(result)), nil
-	} else if cCase == "down" {
-		return strings.ToLower(string(result)), nil
-	}
-	return result, nil
+	c := oc["traceparent"]
+	cache := c.([]string)
+	oc["traceparent"] = append(cache, s)
+
+	return s, nil
 }
@@ -2,14 +2,29 @@ package moldova
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+
+	. "github.com/StabbyCutyou/moldova/data"
 )
 
 type TestComparator func(string) error
@@ -21,6 +36,18 @@ type TestCase struct {
 	WriteFailure bool
 }
 
+var CommentCases = []TestCase{
+	{
+		Template:   "a{#: this is dropped}b{#}c{#: even with {int} or | and : in it}d",
+		Comparator: exactly("abcd"),
+	},
+	{
+		// The comment doesn't count as a token, so @1 is still the second {time}
+		Template:   "{#: created}{time:min:1|max:1}@{#: updated}{time:min:2|max:2}@{time:after:@1|min:1h|max:1h}",
+		Comparator: exactly("1970-01-01 00:00:01@1970-01-01 00:00:02@1970-01-01 01:00:02"),
+	},
+}
+
 var GUIDCases = []TestCase{
 	{
 		Template: "{guid}",
@@ -49,482 +76,6187 @@ var GUIDCases = []TestCase{
 		Template:     "{guid}@{guid:ordinal:1}",
 		WriteFailure: true,
 	},
-}
-
-var NowCases = []TestCase{
 	{
-		// There is no proper deterministic way to test what the value of now is, without
-		// something like rubys timecop (but the go-equivalent is not viable) or relying
-		// on luck, which will run out if tests are run at just the wrong moment.
-		// Therefore, for the basic test, i'm just asserting nothing went wrong for now.
-		Template: "{now}",
+		Template: "{guid:format:n}",
 		Comparator: func(s string) error {
-			if len(s) > 0 {
+			if len(s) == 32 && guidDigits(s) == s {
 				return nil
 			}
-			return errors.New("Now not in correct format: " + s)
+			return errors.New("Guid not in N format: " + s)
 		},
 	},
 	{
-		Template: "{now}@{now:ordinal:0}",
+		Template: "{guid:format:b}",
 		Comparator: func(s string) error {
-			p := strings.Split(s, "@")
-			if p[0] == p[1] {
+			if len(s) == 38 && s[0] == '{' && s[37] == '}' && strings.Count(s, "-") == 4 {
 				return nil
 			}
-			return errors.New("Now at position 1 not equal to now at position 0: " + p[0] + " " + p[1])
+			return errors.New("Guid not in B format: " + s)
 		},
 	},
 	{
-		Template:     "{now}@{now:ordinal:1}",
-		WriteFailure: true,
-	},
-}
-
-var TimeCases = []TestCase{
-	{
-		Template: "{time:min:1|max:1|format:simple|zone:EST}",
+		Template: "{guid:format:P}",
 		Comparator: func(s string) error {
-			if s == "1969-12-31 19:00:01" {
+			if len(s) == 38 && s[0] == '(' && s[37] == ')' && strings.Count(s, "-") == 4 {
 				return nil
 			}
-			return errors.New("Time value was not the expected value")
+			return errors.New("Guid not in P format: " + s)
 		},
 	},
 	{
-		Template: "{time:min:1|max:1|format:simpletz|zone:EST}",
+		Template: "{guid:format:x}",
 		Comparator: func(s string) error {
-			if s == "1969-12-31 19:00:01 -0500" {
+			if len(s) == 68 && strings.HasPrefix(s, "{0x") && strings.HasSuffix(s, "}}") && strings.Count(s, "0x") == 11 {
 				return nil
 			}
-			return errors.New("Time value was not the expected value")
+			return errors.New("Guid not in X format: " + s)
 		},
 	},
 	{
-		Template: "{time:min:1|max:1|format:2006//01//02@@15_04_05|zone:EST}",
+		Template: "{guid:format:urn}@{guid:ordinal:0}@{guid:ordinal:0|format:n}",
 		Comparator: func(s string) error {
-			if s == "1969//12//31@@19_00_01" {
-				return nil
+			p := strings.Split(s, "@")
+			if !strings.HasPrefix(p[0], "urn:uuid:") || len(p[0]) != 45 {
+				return errors.New("Guid not in urn format: " + p[0])
 			}
-			return errors.New("Time value was not the expected value")
+			if p[0] != p[1] {
+				return errors.New("Guid at position 1 did not keep the format of guid at position 0: " + p[0] + " " + p[1])
+			}
+			if guidDigits(p[0]) != p[2] {
+				return errors.New("Guid at position 2 not the N format of guid at position 0: " + p[0] + " " + p[2])
+			}
+			return nil
 		},
 	},
 	{
-		Template: "{time}@{time:ordinal:0}",
+		Template:     "{guid:format:q}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{guid}@{guid:ordinal:0|dashes:false}@{guid:format:urn|case:up}@{guid:ordinal:1|dashes:false}",
 		Comparator: func(s string) error {
 			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			if p[1] != strings.Replace(p[0], "-", "", -1) {
+				return errors.New("Guid at position 1 is not guid at position 0 without dashes: " + p[0] + " " + p[1])
 			}
-			return errors.New("Time at position 1 not equal to time at position 0: " + p[0] + " " + p[1])
+			// None of the original's format or case carry over to the reference
+			if p[3] != strings.ToLower(guidDigits(p[2])) {
+				return errors.New("Guid at position 3 did not re-apply only it's own options: " + p[2] + " " + p[3])
+			}
+			return nil
 		},
 	},
 	{
-		Template:     "{time}@{time:ordinal:1}",
-		WriteFailure: true,
+		Template:     "{guid:dashes:no}",
+		ParseFailure: true,
 	},
-}
-
-var CountryCases = []TestCase{
 	{
-		Template: "{country}",
+		Template:     "{guid:case:title}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{guid:version:7}",
 		Comparator: func(s string) error {
-			// TODO better check here in case we ever support different types of country codes
-			if len(s) == 2 {
-				return nil
+			p := strings.Split(s, "-")
+			if len(p) != 5 || len(p[0]) != 8 || len(p[4]) != 12 {
+				return errors.New("Guid not in correct format: " + s)
 			}
-			return errors.New("Invalid country code generated somehow")
+			if p[2][0] != '7' || !strings.ContainsRune("89ab", rune(p[3][0])) {
+				return errors.New("Guid does not have the version 7 and variant bits set: " + s)
+			}
+			ms, err := strconv.ParseInt(p[0]+p[1], 16, 64)
+			if err != nil {
+				return err
+			}
+			if d := time.Since(time.Unix(0, ms*int64(time.Millisecond))); d < 0 || d > time.Minute {
+				return errors.New("Guid timestamp is not the current time: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template: "{country:case:up}",
+		Template:     "{guid:version:5}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{guid:version:7|time:2020-01-01T00:00:00Z}",
 		Comparator: func(s string) error {
-			// Since I can't know which country comes out, i'll invert the result
-			// If the ToLowered result is not the same as the original result, we know
-			// that the original was successfully output in upper case
-			if strings.ToLower(s) != s {
-				return nil
+			if !strings.HasPrefix(s, "016f5e66-e800-7") {
+				return errors.New("Guid timestamp is not pinned to 2020-01-01: " + s)
 			}
-			return errors.New("Country was returned in lowercase, but was requested in uppercase")
+			return nil
 		},
 	},
 	{
-		Template: "{country:case:down}",
+		Template:     "{guid:time:2020-01-01T00:00:00Z}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{guid:prefix:AB}@{guid:prefix:0123456789ab|format:n}",
+		Comparator: matches(`^ab[0-9a-f]{6}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}@0123456789ab4[0-9a-f]{3}[89ab][0-9a-f]{15}$`),
+	},
+	{
+		Template:     "{guid:prefix:0123456789abc}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid:prefix:zz}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid:prefix:-1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid:version:7|prefix:ab}",
+		ParseFailure: true,
+	},
+}
+
+var ULIDCases = []TestCase{
+	{
+		Template: "{ulid}",
 		Comparator: func(s string) error {
-			// Since I can't know which country comes out, i'll invert the result
-			// If the ToLowered result is not the same as the original result, we know
-			// that the original was successfully output in upper case
-			if strings.ToUpper(s) != s {
-				return nil
+			if !regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(s) {
+				return errors.New("ULID not in correct format: " + s)
 			}
-			return errors.New("Country was returned in uppercase, but was requested in lowercase")
+			return nil
 		},
 	},
 	{
-		Template: "{country}@{country:ordinal:0}",
+		Template: "{ulid}@{ulid:ordinal:0}",
 		Comparator: func(s string) error {
 			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			if p[0] != p[1] {
+				return errors.New("ULID at position 1 not equal to ULID at position 0: " + s)
 			}
-			return errors.New("Country at position 1 not equal to country at position 0: " + p[0] + " " + p[1])
+			return nil
 		},
 	},
 	{
-		Template:     "{country}@{country:ordinal:1}",
+		Template:     "{ulid}@{ulid:ordinal:1}",
 		WriteFailure: true,
 	},
-}
-
-// Placeholders
-var FloatCases = []TestCase{
 	{
-		Template: "{float}",
+		Template: "{ulid:time:2020-01-01T00:00:00Z}",
 		Comparator: func(s string) error {
-			i, err := strconv.ParseFloat(s, 64)
-			if err != nil {
-				return err
-			}
-			if i >= 0.0 && i <= 100.0 {
-				return nil
+			if !strings.HasPrefix(s, "01DXF6DT00") {
+				return errors.New("ULID timestamp is not pinned to 2020-01-01: " + s)
 			}
-			return errors.New("Float out of range for default min/max values")
+			return nil
 		},
 	},
 	{
-		Template: "{float:max:5000.0|min:4999.0}",
+		Template: "{time:min:1577836800|max:1577836800}@{ulid:time:@0}@{guid:version:7|time:@0}",
 		Comparator: func(s string) error {
-			i, err := strconv.ParseFloat(s, 64)
-			if err != nil {
-				return err
-			}
-			if i >= 4999.0 && i <= 5000.0 {
-				return nil
+			p := strings.Split(s, "@")
+			if !strings.HasPrefix(p[1], "01DXF6DT00") || !strings.HasPrefix(p[2], "016f5e66-e800") {
+				return errors.New("Ids are not pinned to the time they refer to: " + s)
 			}
-			return errors.New("Float out of range for custom min/max values")
+			return nil
 		},
 	},
 	{
-		Template: "{float:max:-5000.0|min:-5001.0}",
+		// Ids which aren't pinned take the time of the result they're in, as {now} does
+		Template: "{now:format:unixms}@{ulid}@{guid:version:7}",
 		Comparator: func(s string) error {
-			i, err := strconv.ParseFloat(s, 64)
+			p := strings.Split(s, "@")
+			now, err := strconv.ParseInt(p[0], 10, 64)
 			if err != nil {
 				return err
 			}
-			if i >= -5001.0 && i <= -5000.0 {
-				return nil
+			var ulid int64
+			for _, c := range p[1][:10] {
+				ulid = ulid<<5 | int64(strings.IndexRune("0123456789ABCDEFGHJKMNPQRSTVWXYZ", c))
 			}
-			return errors.New("Float out of range for custom min/max values")
+			guid, err := strconv.ParseInt(strings.Replace(p[2][:13], "-", "", 1), 16, 64)
+			if err != nil {
+				return err
+			}
+			if ulid != now || guid != now {
+				return errors.New("Ids are not made at the time of their result: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template: "{float}@{float:ordinal:0}",
-		Comparator: func(s string) error {
-			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
-			}
-			return errors.New("Float at position 1 not equal to Float at position 0: " + p[0] + " " + p[1])
-		},
+		Template:     "{ulid:time:yesterday}",
+		ParseFailure: true,
 	},
 	{
-		Template:     "{float}@{float:ordinal:1}",
-		WriteFailure: true,
+		Template:     "{ulid:time:1969-12-31T00:00:00Z}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int}@{ulid:time:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{ulid:time:@0}",
+		ParseFailure: true,
 	},
 }
 
-var IntegerCases = []TestCase{
+// matches returns a comparator which expects the result to match the regular expression
+func matches(pattern string) TestComparator {
+	re := regexp.MustCompile(pattern)
+	return func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("Expected a match for %s, got %s", pattern, s)
+		}
+		return nil
+	}
+}
+
+var PhoneCases = []TestCase{
 	{
-		Template: "{int}",
-		Comparator: func(s string) error {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				return err
-			}
-			if i >= 0 && i <= 100 {
-				return nil
-			}
-			return errors.New("Int out of range for default min/max values")
-		},
+		Template:   "{phone}",
+		Comparator: matches(`^[2-9]\d\d-[2-9]\d\d-\d{4}$`),
 	},
 	{
-		Template: "{int:max:5000|min:4999}",
-		Comparator: func(s string) error {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				return err
-			}
-			if i >= 4999 && i <= 5000 {
-				return nil
-			}
-			return errors.New("Int out of range for custom min/max values")
-		},
+		Template:   "{phone:country:GB|format:e164}",
+		Comparator: matches(`^\+44[1-9]\d{9}$`),
 	},
 	{
-		Template: "{int:max:-5000|min:-5001}",
-		Comparator: func(s string) error {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				return err
-			}
-			if i >= -5001 && i <= -5000 {
-				return nil
-			}
-			return errors.New("Int out of range for custom min/max values")
-		},
+		Template:   "{phone:country:fr}",
+		Comparator: matches(`^0[1-9]( \d\d){4}$`),
 	},
 	{
-		Template: "{int}@{int:ordinal:0}",
+		Template:   "{phone:country:DE}",
+		Comparator: matches(`^0[1-9]\d\d \d{7,8}$`),
+	},
+	{
+		Template:   "{phone:country:US|format:e164|ext:true}",
+		Comparator: matches(`^\+1[2-9]\d\d[2-9]\d{6}x\d{3}$`),
+	},
+	{
+		Template: "{phone:country:GB|ext:true}@{phone:ordinal:0}@{phone:ordinal:0|format:e164}",
 		Comparator: func(s string) error {
 			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			if p[0] != p[1] {
+				return errors.New("Phone at position 1 not equal to phone at position 0: " + s)
 			}
-			return errors.New("Int at position 1 not equal to int at position 0: " + p[0] + " " + p[1])
+			if "+44"+strings.Replace(strings.TrimPrefix(p[0], "0"), " ", "", -1) != p[2] {
+				return errors.New("Phone at position 2 is not phone 0 in E.164 format: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template:     "{int}@{int:ordinal:1}",
+		Template:     "{phone}@{phone:ordinal:1}",
 		WriteFailure: true,
 	},
-}
-
-var UnicodeCases = []TestCase{
 	{
-		Template: "{unicode}",
-		Comparator: func(s string) error {
-			if len([]rune(s)) == 2 {
-				return nil
-			}
-			return errors.New("Unicode string not the correct length")
-		},
+		Template:     "{phone:country:ZZ}",
+		ParseFailure: true,
 	},
 	{
-		Template: "{unicode:length:10}",
+		Template:     "{phone:format:rfc3966}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{phone:ext:maybe}",
+		ParseFailure: true,
+	},
+	{
+		// Those without a calling code are an error, so they're excluded
+		Template: "{country:case:down|exclude:AQ,BV,HM,CP,EU,EZ,SU,UN}@{phone:country:@0|format:e164}@{phone:ordinal:0}",
 		Comparator: func(s string) error {
-			if len([]rune(s)) == 10 {
-				return nil
+			p := strings.Split(s, "@")
+			code := CountryCallingCodes[strings.ToUpper(p[0])]
+			if !regexp.MustCompile(`^\+` + code + `[1-9]\d{8,10}$`).MatchString(p[1]) {
+				return errors.New("Phone at position 1 does not have the calling code of the country at position 0: " + s)
 			}
-			return errors.New("Unicode string not the correct length")
+			if p[2] != p[1] {
+				return errors.New("Phone at position 2 not equal to phone at position 1: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template: "{unicode:length:10|case:up}",
+		Template:   "{country:exclude:US,AQ,BV,HM,CP,EU,EZ,SU,UN|as:home}@{phone:country:@home|format:national}",
+		Comparator: matches(`^[A-Z]{2}@[-0-9 ]+$`),
+	},
+	{
+		Template:   "{phone:country:random|format:e164}",
+		Comparator: matches(`^\+[1-9]\d{9,13}$`),
+	},
+	{
+		Template:     "{guid}@{phone:country:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{phone:country:@0}",
+		ParseFailure: true,
+	},
+}
+
+var CapitalCases = []TestCase{
+	{
+		Template: "{capital}",
 		Comparator: func(s string) error {
-			if len([]rune(s)) == 10 || strings.ToLower(s) != strings.ToUpper(s) {
-				return nil
+			for _, c := range CountryCapitals {
+				if c == s {
+					return nil
+				}
 			}
-			return errors.New("Unicode string not the correct length")
+			return errors.New("Capital is not the capital of a country: " + s)
 		},
 	},
 	{
-		Template: "{unicode}@{unicode:ordinal:0}",
+		Template: "{country:case:down}@{capital:of:@0}@{capital:ordinal:0}",
 		Comparator: func(s string) error {
 			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			if p[1] != CountryCapitals[strings.ToUpper(p[0])] {
+				return errors.New("Capital is not the capital of the country it refers to: " + s)
 			}
-			return errors.New("Unicode at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
+			if p[2] != p[1] {
+				return errors.New("Capital at position 2 not equal to capital at position 1: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template:     "{unicode}@{unicode:ordinal:1}",
-		WriteFailure: true,
+		Template:     "{firstname}{capital:of:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{capital:of:GB}",
+		ParseFailure: true,
 	},
 }
 
-var ASCIICases = []TestCase{
+// subdivisionNamed returns the subdivision of the given country with the given name
+func subdivisionNamed(country string, name string) (Subdivision, bool) {
+	for _, s := range CountrySubdivisions[country] {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Subdivision{}, false
+}
+
+var SubdivisionCases = []TestCase{
 	{
-		Template: "{ascii}",
+		Template: "{subdivision}",
 		Comparator: func(s string) error {
-			if len(s) == 2 {
-				return nil
+			for country := range CountrySubdivisions {
+				if _, ok := subdivisionNamed(country, s); ok {
+					return nil
+				}
 			}
-			return errors.New("Ascii string not the correct length")
+			return errors.New("Subdivision is not the subdivision of a country: " + s)
 		},
 	},
 	{
-		Template: "{ascii:length:10}",
+		Template: "{country:case:down}@{subdivision:of:@0}@{subdivision:ordinal:0|format:code}",
 		Comparator: func(s string) error {
-			if len(s) == 10 {
+			p := strings.Split(s, "@")
+			country := strings.ToUpper(p[0])
+			if len(CountrySubdivisions[country]) == 0 {
+				if p[1] != "" || p[2] != "" {
+					return errors.New("Expected no subdivision for a country without any: " + s)
+				}
 				return nil
 			}
-			return errors.New("ASCII string not the correct length")
+			sub, ok := subdivisionNamed(country, p[1])
+			if !ok {
+				return errors.New("Subdivision is not in the country it refers to: " + s)
+			}
+			if p[2] != country+"-"+sub.Code {
+				return errors.New("Subdivision at position 2 is not the code of subdivision 1: " + s)
+			}
+			return nil
 		},
 	},
 	{
-		Template: "{ascii:length:10|case:up}",
+		Template:   "{country:exclude:" + strings.Join(otherCountries("US"), ",") + "}@{subdivision:of:@0|format:code}",
+		Comparator: matches(`^US@US-[A-Z]{2}$`),
+	},
+	{
+		Template:     "{guid}{subdivision:of:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{subdivision:of:US}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{subdivision:format:iso}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{subdivision}@{subdivision:ordinal:1}",
+		WriteFailure: true,
+	},
+}
+
+func TestCountrySubdivisions(t *testing.T) {
+	for country, subdivisions := range CountrySubdivisions {
+		if !inStrings(country, CountryCodes) {
+			t.Errorf("Expected the subdivisions of %s to belong to a known country code", country)
+		}
+		seen := make(map[string]bool)
+		for _, s := range subdivisions {
+			if seen[s.Code] || s.Name == "" {
+				t.Errorf("Expected the subdivisions of %s to have a name and a code of their own, got %v", country, s)
+			}
+			seen[s.Code] = true
+		}
+	}
+}
+
+var NumberWordsCases = []TestCase{
+	{
+		Template:   "{numberwords}",
+		Comparator: matches(`^[a-z]+([ -][a-z]+)*$`),
+	},
+	{
+		Template:   "{numberwords:min:142|max:142}@{numberwords:ordinal:0}",
+		Comparator: exactly("one hundred forty-two@one hundred forty-two"),
+	},
+	{
+		Template:   "{int:min:-7|max:-7}@{numberwords:of:@0}@{int:ordinal:0}",
+		Comparator: exactly("-7@minus seven@-7"),
+	},
+	{
+		// A number drawn by {numberwords} isn't one of the {int}s
+		Template:   "{int:min:3|max:3}{numberwords:min:4|max:4}{int:ordinal:0}",
+		Comparator: exactly("3four3"),
+	},
+	{
+		Template:   "{int:min:5|max:5}@{numberwords:min:@0|max:@0}",
+		Comparator: exactly("5@five"),
+	},
+	{
+		Template:     "{numberwords:lang:xx}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{numberwords:min:10|max:1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{numberwords:of:7}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid}{numberwords:of:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{numberwords:ordinal:0}",
+		WriteFailure: true,
+	},
+}
+
+var PhoneticCases = []TestCase{
+	{
+		Template:   "{phonetic}@{phonetic:length:1}@{phonetic:ordinal:0}",
+		Comparator: matches(`^([A-Z][a-z-]+ ){2}[A-Z][a-z-]+@[A-Z][a-z-]+@([A-Z][a-z-]+ ){2}[A-Z][a-z-]+$`),
+	},
+	{
+		Template:   "{regex:pattern:aB-9x\\.}@{phonetic:of:@0}",
+		Comparator: exactly("aB-9x.@Alfa Bravo Nine X-ray"),
+	},
+	{
+		Template:   "{int:min:-40|max:-40}@{country:exclude:" + strings.Join(otherCountries("US"), ",") + "}@{phonetic:of:@0}@{phonetic:of:@1}",
+		Comparator: exactly("-40@US@Four Zero@Uniform Sierra"),
+	},
+	{
+		Template:     "{phonetic:length:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{phonetic:of:abc}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid}{phonetic:of:@0}",
+		ParseFailure: true,
+	},
+}
+
+var EmojiCases = []TestCase{
+	{
+		Template:   "{emoji}",
+		Comparator: emojiRun(nil, 1),
+	},
+	{
+		Template:   "{emoji:category:faces|count:3}",
+		Comparator: emojiRun([]string{"faces"}, 3),
+	},
+	{
+		Template:   "{emoji:category:animals,food|count:5}",
+		Comparator: emojiRun([]string{"animals", "food"}, 5),
+	},
+	{
+		// Emoji made of several code points are written out whole
+		Template:   "{emoji:category:people|count:20}",
+		Comparator: emojiRun([]string{"people"}, 20),
+	},
+	{
+		Template: "{emoji:count:4}@{emoji:ordinal:0}",
 		Comparator: func(s string) error {
-			if len(s) == 10 || strings.ToLower(s) != strings.ToUpper(s) {
-				return nil
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Emoji at position 1 not equal to emoji at position 0: " + s)
 			}
-			return errors.New("ASCII string not the correct length")
+			return nil
 		},
 	},
 	{
-		Template: "{ascii}@{ascii:ordinal:0}",
+		Template:     "{emoji:category:plants}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{emoji:count:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{emoji:ordinal:0}",
+		WriteFailure: true,
+	},
+}
+
+// emojiRun checks that a result is made up of exactly n emoji from the given categories
+func emojiRun(categories []string, n int) func(string) error {
+	all := emojiIn(categories)
+	var split func(s string, n int) bool
+	split = func(s string, n int) bool {
+		if n == 0 {
+			return s == ""
+		}
+		for _, e := range all {
+			if strings.HasPrefix(s, e) && split(s[len(e):], n-1) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(s string) error {
+		if !split(s, n) {
+			return fmt.Errorf("Expected %d emoji from %v, got %s", n, categories, s)
+		}
+		return nil
+	}
+}
+
+func TestEmojiByCategory(t *testing.T) {
+	seen := make(map[string]bool)
+	for name, emoji := range EmojiByCategory {
+		if len(emoji) == 0 {
+			t.Errorf("Expected the %s category to have emoji", name)
+		}
+		for _, e := range emoji {
+			if seen[e] || !utf8.ValidString(e) {
+				t.Errorf("Expected %q to be valid, and in only one category", e)
+			}
+			seen[e] = true
+		}
+	}
+}
+
+var JSONValueCases = []TestCase{
+	{
+		Template:   "{jsonvalue}",
+		Comparator: jsonValueDepth(2),
+	},
+	{
+		Template:   "{jsonvalue:maxdepth:0}",
+		Comparator: jsonValueDepth(0),
+	},
+	{
+		Template:   "[{jsonvalue:maxdepth:4},{jsonvalue:ordinal:0}]",
+		Comparator: jsonValueDepth(5),
+	},
+	{
+		Template:     "{jsonvalue:maxdepth:-1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{jsonvalue:maxdepth:9}",
+		ParseFailure: true,
+	},
+}
+
+// jsonValueDepth checks that a result is valid JSON, with arrays and objects nested at
+// most max deep
+func jsonValueDepth(max int) func(string) error {
+	var depth func(v interface{}) int
+	depth = func(v interface{}) int {
+		var children []interface{}
+		switch v := v.(type) {
+		case []interface{}:
+			children = v
+		case map[string]interface{}:
+			for _, e := range v {
+				children = append(children, e)
+			}
+		default:
+			return 0
+		}
+		d := 1
+		for _, e := range children {
+			if n := depth(e) + 1; n > d {
+				d = n
+			}
+		}
+		return d
+	}
+	return func(s string) error {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return fmt.Errorf("Expected a valid JSON value, got %s: %v", s, err)
+		}
+		if d := depth(v); d > max {
+			return fmt.Errorf("Expected a JSON value nested at most %d deep, got %d: %s", max, d, s)
+		}
+		return nil
+	}
+}
+
+func TestWriteJSONValue(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	kinds := make(map[byte]bool)
+	for i := 0; i < 1000; i++ {
+		buf := &bytes.Buffer{}
+		writeJSONValue(r, buf, 2)
+		if err := jsonValueDepth(2)(buf.String()); err != nil {
+			t.Fatal(err)
+		}
+		kinds[buf.Bytes()[0]] = true
+	}
+	// Every kind of value turns up, by the first character of it
+	for _, c := range []byte("ntf\"[{") {
+		if !kinds[c] {
+			t.Errorf("Expected a JSON value starting with %c", c)
+		}
+	}
+}
+
+var WhitespaceCases = []TestCase{
+	{
+		Template:   "[{choice:of:  a \t b  |trim:true}][{choice:of:  a \t b  |collapsews:true}][{choice:of:  a \t b  |trim:true|collapsews:true}]",
+		Comparator: exactly("[a \t b][ a b ][a b]"),
+	},
+	{
+		Template:   "{lorem:paragraphs:3|collapsews:true}",
+		Comparator: matches(`^[a-z]+( [a-z]+){14}$`),
+	},
+	{
+		// The whole of what a {repeat} writes is cleaned up, rather than each time through
+		Template:   "[{repeat:count:3|sep: |of: {int:min:1|max:1} |trim:true|collapsews:true}]",
+		Comparator: exactly("[1 1 1]"),
+	},
+	{
+		Template:   "{choice:of:  Mixed Case  |trim:true|collapsews:true}",
+		Comparator: exactly("Mixed Case"),
+	},
+	{
+		// An ordinal reference writes out the same bytes as the token it refers to
+		Template:   "[{choice:of:  a \t b  |trim:true|collapsews:true}][{choice:ordinal:0}]",
+		Comparator: exactly("[a b][a b]"),
+	},
+	{
+		Template:   "[{choice:of:  {int:min:1|max:1}  |trim:true}][{choice:ordinal:0}]",
+		Comparator: exactly("[1][1]"),
+	},
+	{
+		Template:   "[{time:format:Jan _2|min:1583366400|max:1583366400|collapsews:true}][{time:ordinal:0}]",
+		Comparator: exactly("[Mar 5][Mar 5]"),
+	},
+	{
+		Template:     "{int:trim:yes}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{firstname:collapsews:1}",
+		ParseFailure: true,
+	},
+}
+
+func TestUniqueTrimmed(t *testing.T) {
+	// The values only differ by the whitespace which is trimmed from them, so once one
+	// has been written out, the other isn't unique
+	cs, err := BuildCallstack("{choice:of: x,x |unique:true|trim:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil || result.String() != "x" {
+		t.Fatalf("Expected x, got %q and %v", result, err)
+	}
+	if err := cs.Write(result); err == nil {
+		t.Error("Expected an error once every trimmed value had been used, got ", result)
+	} else if _, ok := err.(ExhaustedRetriesError); !ok {
+		t.Error("Expected an ExhaustedRetriesError, got ", err)
+	}
+}
+
+func TestSpellEnglish(t *testing.T) {
+	cases := map[int]string{
+		0:             "zero",
+		7:             "seven",
+		13:            "thirteen",
+		40:            "forty",
+		99:            "ninety-nine",
+		100:           "one hundred",
+		101:           "one hundred one",
+		1000:          "one thousand",
+		1001000:       "one million one thousand",
+		-2500:         "minus two thousand five hundred",
+		math.MaxInt32: "two billion one hundred forty-seven million four hundred eighty-three thousand six hundred forty-seven",
+		math.MinInt32: "minus two billion one hundred forty-seven million four hundred eighty-three thousand six hundred forty-eight",
+	}
+	// The widest ints only exist where int is 64 bits
+	if strconv.IntSize == 64 {
+		max, min := int64(math.MaxInt64), int64(math.MinInt64)
+		cases[int(max)] = "nine quintillion two hundred twenty-three quadrillion three hundred seventy-two trillion thirty-six billion eight hundred fifty-four million seven hundred seventy-five thousand eight hundred seven"
+		cases[int(min)] = "minus nine quintillion two hundred twenty-three quadrillion three hundred seventy-two trillion thirty-six billion eight hundred fifty-four million seven hundred seventy-five thousand eight hundred eight"
+	}
+	for n, want := range cases {
+		if got := spellEnglish(n); got != want {
+			t.Errorf("Expected %d to be spelled %q, got %q", n, want, got)
+		}
+	}
+}
+
+func TestCountryCapitals(t *testing.T) {
+	for code := range CountryCapitals {
+		if !inStrings(code, CountryCodes) {
+			t.Errorf("Expected the capital of %s to belong to a known country code", code)
+		}
+	}
+}
+
+func TestPhoneWithoutCallingCode(t *testing.T) {
+	// A {country} can't be made to generate AQ, so the cache is filled in by hand
+	oc := newObjectCache(time.Now())
+	oc["country"] = []countryValue{{code: "AQ", formatted: "aq"}}
+	_, err := phone(oc, cmdOptions{"ordinal": "-1", "country": "@country:0", "format": "e164"})
+	if _, ok := err.(InvalidArgumentError); !ok {
+		t.Errorf("Expected an InvalidArgumentError for a country without a calling code, got %v", err)
+	}
+	if len(oc["phone"].([]phoneValue)) != 0 {
+		t.Error("Expected no phone to be cached for a country without a calling code")
+	}
+}
+
+var BytesCases = []TestCase{
+	{
+		Template:   "{bytes}",
+		Comparator: matches(`^(\d+ B|\d+\.\d (KiB|MiB|GiB))$`),
+	},
+	{
+		Template:   "{bytes:min:1536|max:1536}@{bytes:min:1536|max:1536|base:1000}@{bytes:min:512|max:512}",
+		Comparator: exactly("1.5 KiB@1.5 KB@512 B"),
+	},
+	{
+		Template:   "{bytes:min:1048576|max:1048576|precision:0}@{bytes:min:1000000|max:1000000|base:1000|precision:2}",
+		Comparator: exactly("1 MiB@1.00 MB"),
+	},
+	{
+		// An ordinal keeps the original's units, unless it gives a base or precision of it's own
+		Template:   "{bytes:min:2500000|max:2500000}@{bytes:ordinal:0}@{bytes:ordinal:0|base:1000}@{bytes:ordinal:0|precision:0}",
+		Comparator: exactly("2.4 MiB@2.4 MiB@2.5 MB@2 MiB"),
+	},
+	{
+		Template:     "{bytes:base:1001}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bytes:min:10|max:5}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bytes:min:-1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bytes:precision:-1}",
+		ParseFailure: true,
+	},
+}
+
+var BoundRefCases = []TestCase{
+	{
+		Template: "{int:min:0|max:100|as:low}@{int:min:@low|max:100}@{float:min:@0|max:@1|precision:0}",
 		Comparator: func(s string) error {
 			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			low, _ := strconv.Atoi(p[0])
+			high, _ := strconv.Atoi(p[1])
+			mid, _ := strconv.Atoi(p[2])
+			if high < low || mid < low || mid > high {
+				return errors.New("Bounds referring to earlier values were not kept to: " + s)
 			}
-			return errors.New("ASCII at position 1 not equal to ASCII at position 0: " + p[0] + " " + p[1])
+			return nil
 		},
 	},
 	{
-		Template:     "{ascii}@{ascii:ordinal:1}",
+		Template:   "{int:min:5|max:5}@{int:min:@0|max:@0}@{float:min:@0|max:5|precision:1}",
+		Comparator: exactly("5@5@5.0"),
+	},
+	{
+		Template:   "{float:min:2.5|max:2.5|as:price}@{float:min:@price|max:@price|precision:2}",
+		Comparator: exactly("2.500000@2.50"),
+	},
+	{
+		// A named reference inside of a {repeat} only sees the tokens of the same time
+		// through
+		Template:   "{repeat:count:3|sep:,|of:{int:min:3|max:3|as:n}-{int:min:@n|max:3}}",
+		Comparator: exactly("3-3,3-3,3-3"),
+	},
+	{
+		// Names work for every option which takes a reference
+		Template:   "{time:min:0|max:0|zone:UTC|format:2006-01-02 15:04|as:created}@{time:after:@created|min:1h|max:1h|zone:UTC|format:2006-01-02 15:04}",
+		Comparator: exactly("1970-01-01 00:00@1970-01-01 01:00"),
+	},
+	{
+		Template:     "{int:min:50|max:50}@{int:min:@0|max:10}",
 		WriteFailure: true,
 	},
+	{
+		Template:     "{ascii}@{int:min:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float}@{int:max:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:as:low}@{int:min:@high}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:min:@0}",
+		ParseFailure: true,
+	},
+}
+
+var SnapCases = []TestCase{
+	{
+		Template:   "{float:min:0|max:1|snap:0.05|precision:2}",
+		Comparator: matches(`^(0\.[0-9][05]|1\.00)$`),
+	},
+	{
+		// The precision is used as it is, even though the grid needs fewer digits
+		Template:   "{float:min:0.35|max:0.35|snap:0.05}@{float:min:7.4|max:7.6|snap:0.5|precision:1}",
+		Comparator: exactly("0.350000@7.5"),
+	},
+	{
+		// Values which would snap outside of the range are rolled again
+		Template:   "{float:min:0.01|max:0.09|snap:0.05|precision:2}@{int:min:1|max:6|snap:5}",
+		Comparator: exactly("0.05@5"),
+	},
+	{
+		Template:   "{int:min:-100|max:100|snap:25}",
+		Comparator: matches(`^(-?(0|25|50|75|100))$`),
+	},
+	{
+		Template:   "{int:min:96|max:104|snap:10}@{int:min:-104|max:-96|snap:10}",
+		Comparator: exactly("100@-100"),
+	},
+	{
+		Template:     "{float:snap:0.05|precision:1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:min:0.01|max:0.04|snap:0.05}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:snap:-1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:snap:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:min:1|max:5|snap:5|maxInclusive:false}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:min:1|max:3|snap:5}",
+		ParseFailure: true,
+	},
+}
+
+var TraceparentCases = []TestCase{
+	{
+		Template:   "{traceparent}@{traceparent:sampled:false}",
+		Comparator: matches(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01@00-[0-9a-f]{32}-[0-9a-f]{16}-00$`),
+	},
+	{
+		Template: "{traceparent}@{traceparent:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Traceparent ordinal did not match the original: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{traceparent:sampled:yes}",
+		ParseFailure: true,
+	},
+}
+
+var JSONPathCases = []TestCase{
+	{
+		Template:   "{jsonpath}",
+		Comparator: matches(`^[a-z]{3,8}\.[a-z]{3,8}\.[a-z]{3,8}$`),
+	},
+	{
+		Template:   "{jsonpath:depth:1}@{jsonpath:depth:2-4}",
+		Comparator: matches(`^[a-z]{3,8}@[a-z]{3,8}(\.[a-z]{3,8}){1,3}$`),
+	},
+	{
+		Template: "{jsonpath:depth:5}@{jsonpath:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Jsonpath ordinal did not match the original: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{jsonpath:depth:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{jsonpath:depth:4-2}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{jsonpath:depth:deep}",
+		ParseFailure: true,
+	},
+}
+
+var RequireCases = []TestCase{
+	{
+		Template:   "{ascii:length:2|require:digit,upper}",
+		Comparator: matches(`^([0-9][A-Z]|[A-Z][0-9])$`),
+	},
+	{
+		Template:   "{ascii:length:3|case:up|require:upper,digit}",
+		Comparator: matches(`^[0-9A-Z]*([0-9][0-9A-Z]*[A-Z]|[A-Z][0-9A-Z]*[0-9])[0-9A-Z]*$`),
+	},
+	{
+		Template:   "{unicode:length:1|require:digit|exclude:0,1,2,3,4,5,6,7,8}",
+		Comparator: exactly("9"),
+	},
+	{
+		Template:     "{ascii:length:2|require:digit,upper,lower}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{ascii:require:symbol}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{ascii:require:digit,digit}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{ascii:case:up|require:lower}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{unicode:require:digit|exclude:0,1,2,3,4,5,6,7,8,9}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{ascii}@{ascii:ordinal:0|require:digit}",
+		ParseFailure: true,
+	},
+}
+
+var WordCases = []TestCase{
+	{
+		Template:   "{word}",
+		Comparator: matches(`^[a-z]{6}$`),
+	},
+	{
+		Template:   "{word:style:pronounceable|length:1}@{word:length:11|case:up}",
+		Comparator: matches(`^[a-z]@[A-Z]{11}$`),
+	},
+	{
+		Template: "{word:length:9}@{word:ordinal:0}@{word:ordinal:0|case:up}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] || strings.ToUpper(p[0]) != p[2] {
+				return errors.New("Word ordinals did not match the original: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{word:length:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{word:style:random}",
+		ParseFailure: true,
+	},
+}
+
+var BoolCases = []TestCase{
+	{
+		Template:   "{bool}",
+		Comparator: matches(`^(true|false)$`),
+	},
+	{
+		Template:   "{bool:chance:1}@{bool:chance:0|true:Y|false:N}",
+		Comparator: exactly("true@N"),
+	},
+	{
+		// The inverse keeps the strings of the original, unless it gives it's own
+		Template:   "{bool:chance:1|true:yes|false:no}@{bool:not:@0}@{bool:not:@0|false:0}@{bool:not:@2}",
+		Comparator: exactly("yes@no@0@yes"),
+	},
+	{
+		Template: "{bool:true:1|false:0}@{bool:not:@0}@{bool:ordinal:0}@{bool:ordinal:1|true:T|false:F}",
+		Comparator: func(s string) error {
+			if s != "1@0@1@F" && s != "0@1@0@T" {
+				return errors.New("Bool inverse or ordinal did not match the original: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{int}@{bool:not:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bool:not:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bool}@{bool:not:@0|ordinal:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bool:chance:1.5}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{bool:true:x|false:x}",
+		ParseFailure: true,
+	},
+}
+
+var ChoiceCases = []TestCase{
+	{
+		Template: "{choice:of:red,green,blue}",
+		Comparator: func(s string) error {
+			if s != "red" && s != "green" && s != "blue" {
+				return errors.New("Choice is not one of the values given: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{choice}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{choice:of:a,b|file:values.txt}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{choice:of:user-{int:min:1|max:99},guest}",
+		Comparator: matches(`^(user-[1-9][0-9]?|guest)$`),
+	},
+	{
+		// values is another name for of
+		Template:   "{choice:values:user-{int:min:1|max:99},guest}",
+		Comparator: matches(`^(user-[1-9][0-9]?|guest)$`),
+	},
+	{
+		Template:   "{choice:values:a,b}@{choice:ordinal:0}",
+		Comparator: matches(`^(a@a|b@b)$`),
+	},
+	{
+		Template:     "{choice:values:a,b|of:c}",
+		ParseFailure: true,
+	},
+	{
+		// Commas inside of a token's braces don't split the values
+		Template:   "{choice:of:{int:min:1|max:4|exclude:1,2},none}",
+		Comparator: matches(`^(3|none)$`),
+	},
+	{
+		Template:   "{choice:of:a\\,b,c}",
+		Comparator: matches(`^(a,b|c)$`),
+	},
+	{
+		Template: "{choice:of:{guid},id-{guid:format:n}}@{choice:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Choice at position 1 not equal to choice at position 0: " + p[0] + " " + p[1])
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{choice:of:{int:mim:3},guest}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{choice:of:A,B|weights:30,20|other:C}",
+		Comparator: matches(`^[ABC]$`),
+	},
+	{
+		Template:   "{choice:of:A,B|weights:60,40|other:C}",
+		Comparator: matches(`^[AB]$`),
+	},
+	{
+		Template:   "{choice:of:A,B,C|weights:33.3,33.3,33.4}",
+		Comparator: matches(`^[ABC]$`),
+	},
+	{
+		Template:   "{choice:of:admin,mod|weights:0,0|other:user-{int:min:1|max:9}}@{choice:ordinal:0}",
+		Comparator: matches(`^(user-[1-9])@user-[1-9]$`),
+	},
+	{
+		Template:     "{choice:of:A,B|weights:60,50|other:C}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{choice:of:A,B|weights:30,20}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{choice:of:A,B|weights:30|other:C}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{choice:of:A,B|weights:30,-5|other:C}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{choice:of:A,B|other:C}",
+		ParseFailure: true,
+	},
+}
+
+var SnowflakeCases = []TestCase{
+	{
+		Template: "{snowflake:epoch:2020-01-01|machine:513}",
+		Comparator: func(s string) error {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			if machine := id >> 12 & 1023; machine != 513 {
+				return fmt.Errorf("Snowflake %s has machine %d, not 513", s, machine)
+			}
+			created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(id>>22) * time.Millisecond)
+			if d := time.Since(created); d < -time.Second || d > time.Minute {
+				return errors.New("Snowflake time is not the time since the epoch: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{snowflake}@{snowflake:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Snowflake at position 1 not equal to snowflake at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{snowflake}@{snowflake:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{snowflake:machine:1024}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{snowflake:epoch:2999-01-01}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{snowflake:epoch:last tuesday}",
+		ParseFailure: true,
+	},
+}
+
+func TestSnowflakeMonotonic(t *testing.T) {
+	// Two tokens for the same machine in each row, so that they share a sequence
+	cs, err := BuildCallstack("{snowflake:machine:7} {repeat:count:2|of:{snowflake:machine:7} }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 3000); err != nil {
+		t.Fatal(err)
+	}
+	ids := strings.Fields(result.String())
+	if len(ids) != 9000 {
+		t.Fatalf("Expected 9000 snowflakes, got %d", len(ids))
+	}
+	last := int64(-1)
+	for i, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("Expected snowflake %d to be greater than the one before it: %d %d", i, last, id)
+		}
+		last = id
+	}
+
+	// The time of an id is the time of the result it's in, and Reset starts the
+	// generators over
+	cs, err = BuildCallstack("{snowflake:epoch:1970-01-01}@{now:format:unixms}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Reset()
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	p := strings.Split(result.String(), "@")
+	id, err := strconv.ParseInt(p[0], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms := strconv.FormatInt(id>>22, 10); ms != p[1] {
+		t.Errorf("Expected the snowflake to be made at %s, the time of it's result, got %s", p[1], ms)
+	}
+	cs.Reset()
+	if n := len(cs.run.snowflakes.clocks); n != 0 {
+		t.Errorf("Expected Reset to forget every snowflake generator, %d are left", n)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	cs, err := BuildCallstack("{cycle:of:a,b,c}{cycle:of:x,y}{cycle:ordinal:0}|{repeat:count:4|of:{cycle:of:1,2\\,5,3}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func(n int) string {
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, n); err != nil {
+			t.Fatal(err)
+		}
+		return result.String()
+	}
+	// Each cycle goes round on it's own, carrying on across calls to WriteN, as does the
+	// one inside of the repeat, across each time through it
+	expected := "axa|12,531\nbyb|2,5312,5\n"
+	if s := batch(2); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+	expected = "cxc|312,53\n"
+	if s := batch(1); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+	cs.Reset()
+	expected = "axa|12,531\n"
+	if s := batch(1); s != expected {
+		t.Errorf("Expected the cycles to start over after a Reset, got %q", s)
+	}
+
+	if _, err := BuildCallstack("{cycle}"); err == nil {
+		t.Error("Expected an error for a cycle without any values")
+	}
+
+	// values is another name for of
+	cs, err = BuildCallstack("{cycle:values:a,b,c}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "a\nb\nc\na\n"
+	if s := batch(4); s != expected {
+		t.Errorf("Expected %q, got %q", expected, s)
+	}
+}
+
+func TestReset(t *testing.T) {
+	cs, err := BuildCallstack("{semver:sequence:patch}@{int:min:1|max:4|unique:true}@{repeat:of:{int:min:1|max:4|unique:true}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func() []string {
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, 3); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n")
+	}
+	first := batch()
+	// Every unique value has been used up, so there is nothing left for another row
+	if err := cs.Write(&bytes.Buffer{}); err == nil {
+		t.Error("Expected unique values to carry on across calls to WriteN without a Reset")
+	}
+
+	cs.Reset()
+	second := batch()
+	for i := range first {
+		if a, b := strings.Split(first[i], "@")[0], strings.Split(second[i], "@")[0]; a != b {
+			t.Errorf("Expected the sequence to start over after a Reset, got %s then %s", a, b)
+		}
+	}
+	if !strings.HasPrefix(second[0], "1.0.0@") {
+		t.Errorf("Expected the sequence to start from it's base after a Reset, got %s", second[0])
+	}
+
+	// Reset can be called while other goroutines are writing
+	cs.Reset()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			cs.Reset()
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		cs.Write(&bytes.Buffer{})
+	}
+	<-done
+}
+
+func TestULIDMonotonic(t *testing.T) {
+	// Pinned ids all share the same millisecond, so they must count up from each other
+	for _, template := range []string{"{ulid} ", "{ulid:time:2020-01-01T00:00:00Z} "} {
+		cs, err := BuildCallstack(strings.Repeat(template, 200) + "{repeat:count:200|of:" + template + "}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.Write(result); err != nil {
+			t.Fatal(err)
+		}
+		ids := strings.Fields(result.String())
+		if len(ids) != 400 {
+			t.Fatalf("Expected 400 ULIDs, got %d", len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("Expected ULID %d to sort after the one before it: %s %s", i, ids[i-1], ids[i])
+			}
+		}
+	}
+}
+
+func TestGUIDv7Monotonic(t *testing.T) {
+	cs, err := BuildCallstack(strings.Repeat("{guid:version:7} ", 200) + "{repeat:count:200|of:{guid:version:7} }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	guids := strings.Fields(result.String())
+	if len(guids) != 400 {
+		t.Fatalf("Expected 400 guids, got %d", len(guids))
+	}
+	for i := 1; i < len(guids); i++ {
+		if guids[i] <= guids[i-1] {
+			t.Fatalf("Expected guid %d to sort after the one before it: %s %s", i, guids[i-1], guids[i])
+		}
+	}
+}
+
+func TestLayoutGUID(t *testing.T) {
+	h := "0ab4cc336689404fa8014fd431ca3f30"
+	cases := []struct {
+		opts     cmdOptions
+		expected string
+	}{
+		{cmdOptions{}, "0ab4cc33-6689-404f-a801-4fd431ca3f30"},
+		{cmdOptions{"dashes": "true"}, "0ab4cc33-6689-404f-a801-4fd431ca3f30"},
+		{cmdOptions{"dashes": "false"}, "0ab4cc336689404fa8014fd431ca3f30"},
+		{cmdOptions{"case": "up"}, "0AB4CC33-6689-404F-A801-4FD431CA3F30"},
+		{cmdOptions{"case": "down"}, "0ab4cc33-6689-404f-a801-4fd431ca3f30"},
+		{cmdOptions{"dashes": "false", "case": "up"}, "0AB4CC336689404FA8014FD431CA3F30"},
+		{cmdOptions{"format": "n", "dashes": "true"}, "0ab4cc336689404fa8014fd431ca3f30"},
+		{cmdOptions{"format": "b", "dashes": "false", "case": "up"}, "{0AB4CC336689404FA8014FD431CA3F30}"},
+		{cmdOptions{"format": "p", "case": "up"}, "(0AB4CC33-6689-404F-A801-4FD431CA3F30)"},
+		{cmdOptions{"format": "x", "case": "up"}, "{0x0AB4CC33,0x6689,0x404F,{0xA8,0x01,0x4F,0xD4,0x31,0xCA,0x3F,0x30}}"},
+		{cmdOptions{"format": "urn", "dashes": "false", "case": "up"}, "urn:uuid:0AB4CC336689404FA8014FD431CA3F30"},
+	}
+	for _, c := range cases {
+		if g := layoutGUID(h, c.opts); g != c.expected {
+			t.Errorf("Expected %v to lay out as %s, got %s", c.opts, c.expected, g)
+		}
+		// The case of the digits going in makes no difference
+		if g := layoutGUID(strings.ToUpper(h), c.opts); g != c.expected {
+			t.Errorf("Expected %v to lay out upper case digits as %s, got %s", c.opts, c.expected, g)
+		}
+	}
+}
+
+func TestGUIDOrdinalLayout(t *testing.T) {
+	// Every combination of layout options, including none at all
+	combos := []cmdOptions{{}}
+	for _, format := range []string{"", "n", "d", "b", "p", "x", "urn"} {
+		for _, dashes := range []string{"", "true", "false"} {
+			for _, cCase := range []string{"", "up", "down"} {
+				o := cmdOptions{}
+				if format != "" {
+					o["format"] = format
+				}
+				if dashes != "" {
+					o["dashes"] = dashes
+				}
+				if cCase != "" {
+					o["case"] = cCase
+				}
+				if len(o) > 0 {
+					combos = append(combos, o)
+				}
+			}
+		}
+	}
+	template := func(opts cmdOptions, ordinal bool) string {
+		p := make([]string, 0)
+		if ordinal {
+			p = append(p, "ordinal:0")
+		}
+		for _, o := range []string{"format", "dashes", "case"} {
+			if v, ok := opts[o]; ok {
+				p = append(p, o+":"+v)
+			}
+		}
+		if len(p) == 0 {
+			return "{guid}"
+		}
+		return "{guid:" + strings.Join(p, "|") + "}"
+	}
+
+	for _, original := range combos {
+		for _, reference := range combos {
+			tpl := template(original, false) + "@" + template(reference, true)
+			cs, err := BuildCallstack(tpl)
+			if err != nil {
+				t.Fatalf("%s: %v", tpl, err)
+			}
+			result := &bytes.Buffer{}
+			if err := cs.Write(result); err != nil {
+				t.Fatalf("%s: %v", tpl, err)
+			}
+			p := strings.Split(result.String(), "@")
+			if p[0] != layoutGUID(guidDigits(p[0]), original) {
+				t.Errorf("%s: Guid was not laid out using it's own options: %s", tpl, p[0])
+			}
+			// A reference without options keeps the original as it was written, any
+			// options at all lay it out again using only those of the reference
+			expected := p[0]
+			if len(reference) > 0 {
+				expected = layoutGUID(guidDigits(p[0]), reference)
+			}
+			if p[1] != expected {
+				t.Errorf("%s: Expected the reference to be %s, got %s", tpl, expected, p[1])
+			}
+		}
+	}
+}
+
+func TestGUIDOrdinalsInRandomTemplates(t *testing.T) {
+	seed := time.Now().UnixNano()
+	r := rand.New(rand.NewSource(seed))
+	layouts := map[string][]string{
+		"format": {"n", "d", "b", "p", "x", "urn"},
+		"dashes": {"true", "false"},
+		"case":   {"up", "down"},
+	}
+	// randomLayout picks some, all or none of the layout options at random
+	randomLayout := func() cmdOptions {
+		o := cmdOptions{}
+		for k, values := range layouts {
+			if r.Intn(2) == 0 {
+				o[k] = values[r.Intn(len(values))]
+			}
+		}
+		return o
+	}
+	type piece struct {
+		opts cmdOptions
+		// target is the index of the piece an ordinal refers to, or -1
+		target int
+	}
+
+	for i := 0; i < 500; i++ {
+		pieces := make([]piece, 0)
+		parts := make([]string, 0)
+		// Which pieces were new guids, in the order the cache holds them
+		guids := make([]int, 0)
+		for n := 1 + r.Intn(8); len(pieces) < n; {
+			// Other tokens between the guids mustn't throw the ordinals off
+			if r.Intn(3) == 0 {
+				parts = append(parts, "{int}")
+				pieces = append(pieces, piece{target: -2})
+			}
+			opts := randomLayout()
+			p := piece{opts: opts, target: -1}
+			o := make([]string, 0)
+			if len(guids) > 0 && r.Intn(2) == 0 {
+				ord := r.Intn(len(guids))
+				p.target = guids[ord]
+				o = append(o, "ordinal:"+strconv.Itoa(ord))
+			} else {
+				if r.Intn(3) == 0 {
+					o = append(o, "version:7")
+				}
+				guids = append(guids, len(pieces))
+			}
+			for k, v := range opts {
+				o = append(o, k+":"+v)
+			}
+			parts = append(parts, "{guid:"+strings.Join(o, "|")+"}")
+			pieces = append(pieces, p)
+		}
+		tpl := strings.Replace(strings.Join(parts, "@"), "{guid:}", "{guid}", -1)
+		cs, err := BuildCallstack(tpl)
+		if err != nil {
+			t.Fatalf("seed %d, %s: %v", seed, tpl, err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.Write(result); err != nil {
+			t.Fatalf("seed %d, %s: %v", seed, tpl, err)
+		}
+		out := strings.Split(result.String(), "@")
+		for j, p := range pieces {
+			switch {
+			case p.target == -2:
+				continue
+			case p.target == -1:
+				if d := guidDigits(out[j]); len(d) != 32 || out[j] != layoutGUID(d, p.opts) {
+					t.Errorf("seed %d, %s: Guid %d was not laid out using it's own options: %s", seed, tpl, j, out[j])
+				}
+			default:
+				// A reference without options repeats the original exactly, any options
+				// at all lay the same digits out again using only those of the reference
+				expected := out[p.target]
+				if len(p.opts) > 0 {
+					expected = layoutGUID(guidDigits(expected), p.opts)
+				}
+				if out[j] != expected {
+					t.Errorf("seed %d, %s: Expected ordinal %d to be %s, got %s", seed, tpl, j, expected, out[j])
+				}
+			}
+		}
+	}
+}
+
+func TestIncrementUUIDv7(t *testing.T) {
+	b := []byte{0, 0, 0, 0, 0, 0, 0x7F, 0xFF, 0xBF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	if incrementUUIDv7(b) {
+		t.Error("Expected incrementing every random bit to overflow")
+	}
+	if b[6] != 0x70 || b[8] != 0x80 || b[7] != 0 || b[15] != 0 {
+		t.Errorf("Expected overflow to keep only the version and variant bits, got %x", b)
+	}
+	if !incrementUUIDv7(b) || b[15] != 1 {
+		t.Errorf("Expected increment to add one to the last byte, got %x", b)
+	}
+}
+
+var NowCases = []TestCase{
+	{
+		// There is no proper deterministic way to test what the value of now is, without
+		// something like rubys timecop (but the go-equivalent is not viable) or relying
+		// on luck, which will run out if tests are run at just the wrong moment.
+		// Therefore, for the basic test, i'm just asserting nothing went wrong for now.
+		Template: "{now}",
+		Comparator: func(s string) error {
+			if len(s) > 0 {
+				return nil
+			}
+			return errors.New("Now not in correct format: " + s)
+		},
+	},
+	{
+		Template: "{now}@{now:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Now at position 1 not equal to now at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{now}@{now:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		// Every {now} in a single Write uses the same instant
+		Template: "{now:format:2006-01-02T15:04:05.999999999Z07:00}@{now:format:2006-01-02T15:04:05.999999999Z07:00}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Now at position 1 not equal to now at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		// The ordinal is written out in a different format and zone, but must still be
+		// the very same instant
+		Template: "{now:format:simpletz|zone:EST}@{now:ordinal:0|format:2006-01-02T15:04:05Z07:00|zone:Asia/Tokyo}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			a, err := time.Parse("2006-01-02 15:04:05 -0700", p[0])
+			if err != nil {
+				return err
+			}
+			b, err := time.Parse(time.RFC3339, p[1])
+			if err != nil {
+				return err
+			}
+			if p[0] != p[1] && a.Equal(b) {
+				return nil
+			}
+			return errors.New("Now at position 1 is not the same instant as now at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:   "{now:format:rfc3339nano|zone:UTC}",
+		Comparator: matches(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,9})?Z$`),
+	},
+	{
+		Template:   "{now:format:kitchen}",
+		Comparator: matches(`^\d{1,2}:\d{2}(AM|PM)$`),
+	},
+	{
+		Template:     "{now:format:dd/mm/yyyy}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{now}@{now:ordinal:0|format:HH:mm}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{now:format:simpletz|zone:EST}@{now:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Now at position 1 did not keep the format of now at position 0: " + p[0] + " " + p[1])
+		},
+	},
+}
+
+var TimeCases = []TestCase{
+	{
+		Template: "{time:min:1|max:1|format:simple|zone:EST}",
+		Comparator: func(s string) error {
+			if s == "1969-12-31 19:00:01" {
+				return nil
+			}
+			return errors.New("Time value was not the expected value")
+		},
+	},
+	{
+		Template: "{time:min:1|max:1|format:simpletz|zone:EST}",
+		Comparator: func(s string) error {
+			if s == "1969-12-31 19:00:01 -0500" {
+				return nil
+			}
+			return errors.New("Time value was not the expected value")
+		},
+	},
+	{
+		Template: "{time:min:1|max:1|format:2006//01//02@@15_04_05|zone:EST}",
+		Comparator: func(s string) error {
+			if s == "1969//12//31@@19_00_01" {
+				return nil
+			}
+			return errors.New("Time value was not the expected value")
+		},
+	},
+	{
+		Template: "{time}@{time:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Time at position 1 not equal to time at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{time}@{time:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:   "{time:min:1577880000|max:1577880000|format:rfc3339|zone:EST}",
+		Comparator: exactly("2020-01-01T07:00:00-05:00"),
+	},
+	{
+		Template:   "{time:min:1577880000|max:1577880000|format:iso8601|zone:UTC}",
+		Comparator: exactly("2020-01-01T12:00:00Z"),
+	},
+	{
+		Template:   "{time:min:1577880000|max:1577880000|format:kitchen|zone:EST}@{time:ordinal:0|format:rfc3339nano}",
+		Comparator: exactly("7:00AM@2020-01-01T07:00:00-05:00"),
+	},
+	{
+		// Layouts in another convention are caught when the template is parsed
+		Template:     "{time:format:YYYY-MM-DD}",
+		ParseFailure: true,
+	},
+	{
+		// As are those which can't read back what they write out, here as 02 is the day
+		Template:     "{time:format:2006-02-30}",
+		ParseFailure: true,
+	},
+	{
+		// Only the names themselves are shortcuts, anything else is a golang layout
+		Template:   "{time:min:1577880000|max:1577880000|format:kitchen 2006|zone:UTC}",
+		Comparator: exactly("kitchen 2020"),
+	},
+}
+
+var CountryCases = []TestCase{
+	{
+		Template: "{country}",
+		Comparator: func(s string) error {
+			// TODO better check here in case we ever support different types of country codes
+			if len(s) == 2 {
+				return nil
+			}
+			return errors.New("Invalid country code generated somehow")
+		},
+	},
+	{
+		Template: "{country:case:up}",
+		Comparator: func(s string) error {
+			// Since I can't know which country comes out, i'll invert the result
+			// If the ToLowered result is not the same as the original result, we know
+			// that the original was successfully output in upper case
+			if strings.ToLower(s) != s {
+				return nil
+			}
+			return errors.New("Country was returned in lowercase, but was requested in uppercase")
+		},
+	},
+	{
+		Template: "{country:case:down}",
+		Comparator: func(s string) error {
+			// Since I can't know which country comes out, i'll invert the result
+			// If the ToLowered result is not the same as the original result, we know
+			// that the original was successfully output in upper case
+			if strings.ToUpper(s) != s {
+				return nil
+			}
+			return errors.New("Country was returned in uppercase, but was requested in lowercase")
+		},
+	},
+	{
+		Template: "{country}@{country:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Country at position 1 not equal to country at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template: "{country:exclude:KP,IR,cu}",
+		Comparator: func(s string) error {
+			if s == "KP" || s == "IR" || s == "CU" {
+				return errors.New("Country was excluded: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{country:weight:population|exclude:CN,IN}",
+		Comparator: func(s string) error {
+			if s == "CN" || s == "IN" || CountryPopulations[s] == 0 {
+				return errors.New("Population weighted country was excluded: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{country:exclude:XX}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{country}@{country:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		// The case only applies to the value as it's written, so the ordinal gets the
+		// country as it was generated
+		Template: "{country:case:down}@{country:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == strings.ToLower(p[1]) && p[1] == strings.ToUpper(p[1]) {
+				return nil
+			}
+			return errors.New("Country at position 1 not the country at position 0 as it was generated: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template: "{country}@{country:ordinal:0|case:down}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if strings.ToLower(p[0]) == p[1] {
+				return nil
+			}
+			return errors.New("Country at position 1 not the lowercased country at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:   "{country:format:flag|exclude:" + strings.Join(otherCountries("JP"), ",") + "}@{country:ordinal:0}@{country:ordinal:0|format:alpha2}@{capital:of:@0}",
+		Comparator: exactly("🇯🇵@🇯🇵@JP@Tokyo"),
+	},
+	{
+		Template:   "{country:case:down|exclude:" + strings.Join(otherCountries("GB"), ",") + "}@{country:ordinal:0|format:flag}",
+		Comparator: exactly("gb@🇬🇧"),
+	},
+	{
+		Template:     "{country:format:alpha3}",
+		ParseFailure: true,
+	},
+}
+
+// otherCountries returns every country code other than the given one, to exclude them
+func otherCountries(code string) []string {
+	codes := make([]string, 0, len(CountryCodes))
+	for _, c := range CountryCodes {
+		if c != code {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+// Placeholders
+var FloatCases = []TestCase{
+	{
+		Template: "{float}",
+		Comparator: func(s string) error {
+			i, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			if i >= 0.0 && i <= 100.0 {
+				return nil
+			}
+			return errors.New("Float out of range for default min/max values")
+		},
+	},
+	{
+		Template: "{float:max:5000.0|min:4999.0}",
+		Comparator: func(s string) error {
+			i, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			if i >= 4999.0 && i <= 5000.0 {
+				return nil
+			}
+			return errors.New("Float out of range for custom min/max values")
+		},
+	},
+	{
+		Template: "{float:max:-5000.0|min:-5001.0}",
+		Comparator: func(s string) error {
+			i, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			if i >= -5001.0 && i <= -5000.0 {
+				return nil
+			}
+			return errors.New("Float out of range for custom min/max values")
+		},
+	},
+	{
+		Template: "{float}@{float:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Float at position 1 not equal to Float at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{float}@{float:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{float:min:1000.0|max:100000.0|format:scientific|precision:3}",
+		Comparator: func(s string) error {
+			// 3 digits after the point, and an exponent of +03 or +04
+			if len(s) != 9 || s[1] != '.' || !(strings.HasSuffix(s, "e+03") || strings.HasSuffix(s, "e+04")) {
+				return errors.New("Float not in scientific notation: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{float:precision:2}",
+		Comparator: func(s string) error {
+			if p := strings.Split(s, "."); len(p) != 2 || len(p[1]) != 2 {
+				return errors.New("Float not formatted to 2 decimal places: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{float:format:scientific}@{float:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			a, err := strconv.ParseFloat(p[0], 64)
+			if err != nil {
+				return err
+			}
+			b, err := strconv.ParseFloat(p[1], 64)
+			if err != nil {
+				return err
+			}
+			// Both representations are rounded, so only compare them loosely
+			if a-b < 0.001 && b-a < 0.001 {
+				return nil
+			}
+			return errors.New("Float at position 1 not the same value as Float at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{float:format:hex}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:precision:-1}",
+		ParseFailure: true,
+	},
+}
+
+var IntegerCases = []TestCase{
+	{
+		Template: "{int}",
+		Comparator: func(s string) error {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if i >= 0 && i <= 100 {
+				return nil
+			}
+			return errors.New("Int out of range for default min/max values")
+		},
+	},
+	{
+		Template: "{int:max:5000|min:4999}",
+		Comparator: func(s string) error {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if i >= 4999 && i <= 5000 {
+				return nil
+			}
+			return errors.New("Int out of range for custom min/max values")
+		},
+	},
+	{
+		Template: "{int:max:-5000|min:-5001}",
+		Comparator: func(s string) error {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if i >= -5001 && i <= -5000 {
+				return nil
+			}
+			return errors.New("Int out of range for custom min/max values")
+		},
+	},
+	{
+		Template: "{int}@{int:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Int at position 1 not equal to int at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{int}@{int:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{int:min:1|max:4|exclude:1,2}",
+		Comparator: func(s string) error {
+			if s == "3" {
+				return nil
+			}
+			return errors.New("Int was generated from the exclusion list: " + s)
+		},
+	},
+	{
+		// Every value in the range is excluded, so this must give up rather than hang
+		Template:     "{int:min:1|max:3|exclude:1,2}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{int:exclude:one}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{int:type:int8}",
+		Comparator: func(s string) error {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if i >= -128 && i <= 127 {
+				return nil
+			}
+			return errors.New("Int out of range for int8: " + s)
+		},
+	},
+	{
+		Template: "{int:type:uint8|min:200}",
+		Comparator: func(s string) error {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if i >= 200 && i <= 255 {
+				return nil
+			}
+			return errors.New("Int out of range for uint8 with a custom min: " + s)
+		},
+	},
+	{
+		// An int is too small for int64 and uint32 on 32 bit platforms
+		Template:     "{int:type:int64}",
+		ParseFailure: strconv.IntSize == 32,
+		Comparator: func(s string) error {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err
+		},
+	},
+	{
+		Template:     "{int:type:uint32}",
+		ParseFailure: strconv.IntSize == 32,
+		Comparator: func(s string) error {
+			_, err := strconv.ParseUint(s, 10, 32)
+			return err
+		},
+	},
+	{
+		// Max is left out of the range by default, unless it's the only value in it
+		Template: "{int:min:7|max:7}",
+		Comparator: func(s string) error {
+			if s == "7" {
+				return nil
+			}
+			return errors.New("Int out of range for a range of one value: " + s)
+		},
+	},
+	{
+		Template: "{int:min:5|max:6}",
+		Comparator: func(s string) error {
+			if s == "5" {
+				return nil
+			}
+			return errors.New("Int out of range for the default exclusive max: " + s)
+		},
+	},
+	{
+		Template: "{int:min:5|max:6|maxInclusive:false}",
+		Comparator: func(s string) error {
+			if s == "5" {
+				return nil
+			}
+			return errors.New("Int out of range for an exclusive max: " + s)
+		},
+	},
+	{
+		Template: "{int:min:-6|max:-5|maxInclusive:false}",
+		Comparator: func(s string) error {
+			if s == "-6" {
+				return nil
+			}
+			return errors.New("Negative int out of range for an exclusive max: " + s)
+		},
+	},
+	{
+		Template:   "{int:min:7|max:7|maxInclusive:false}",
+		Comparator: exactly("7"),
+	},
+	{
+		Template:   "{int:min:6|max:6|maxInclusive:true}",
+		Comparator: exactly("6"),
+	},
+	{
+		Template:     "{int:maxInclusive:maybe}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:type:int128}",
+		ParseFailure: true,
+	},
+}
+
+var UnicodeCases = []TestCase{
+	{
+		Template: "{unicode}",
+		Comparator: func(s string) error {
+			if len([]rune(s)) == 2 {
+				return nil
+			}
+			return errors.New("Unicode string not the correct length")
+		},
+	},
+	{
+		Template: "{unicode:length:10}",
+		Comparator: func(s string) error {
+			if len([]rune(s)) == 10 {
+				return nil
+			}
+			return errors.New("Unicode string not the correct length")
+		},
+	},
+	{
+		Template: "{unicode:length:10|case:up}",
+		Comparator: func(s string) error {
+			if len([]rune(s)) == 10 || strings.ToLower(s) != strings.ToUpper(s) {
+				return nil
+			}
+			return errors.New("Unicode string not the correct length")
+		},
+	},
+	{
+		Template: "{unicode}@{unicode:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Unicode at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{unicode}@{unicode:ordinal:1}",
+		WriteFailure: true,
+	},
+}
+
+var ASCIICases = []TestCase{
+	{
+		Template: "{ascii}",
+		Comparator: func(s string) error {
+			if len(s) == 2 {
+				return nil
+			}
+			return errors.New("Ascii string not the correct length")
+		},
+	},
+	{
+		Template: "{ascii:length:10}",
+		Comparator: func(s string) error {
+			if len(s) == 10 {
+				return nil
+			}
+			return errors.New("ASCII string not the correct length")
+		},
+	},
+	{
+		Template: "{ascii:length:10|case:up}",
+		Comparator: func(s string) error {
+			if len(s) == 10 || strings.ToLower(s) != strings.ToUpper(s) {
+				return nil
+			}
+			return errors.New("ASCII string not the correct length")
+		},
+	},
+	{
+		Template: "{ascii}@{ascii:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("ASCII at position 1 not equal to ASCII at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{ascii}@{ascii:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{ascii:case:up}@{ascii:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == strings.ToUpper(p[1]) {
+				return nil
+			}
+			return errors.New("ASCII at position 1 not the ASCII at position 0 as it was generated: " + p[0] + " " + p[1])
+		},
+	},
+}
+
+var FirstNameCases = []TestCase{
+	{
+		Template: "{firstname}",
+		Comparator: func(s string) error {
+			if len(s) > 0 {
+				return nil
+			}
+			return errors.New("First Name string not the correct length")
+		},
+	},
+	{
+		Template: "{firstname}@{firstname:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("First Name at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{firstname}@{firstname:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{firstname:case:up}@{firstname:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == strings.ToUpper(p[1]) && p[0] != p[1] {
+				return nil
+			}
+			return errors.New("First Name at position 1 not the First Name at position 0 as it was generated: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template: "{firstname}@{firstname:ordinal:0|case:down}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if strings.ToLower(p[0]) == p[1] {
+				return nil
+			}
+			return errors.New("First Name at position 1 not the lowercased First Name at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template: "{firstname:locale:de}",
+		Comparator: func(s string) error {
+			if inStrings(s, NamesByLocale["de"].First) {
+				return nil
+			}
+			return errors.New("First Name is not one of the de names: " + s)
+		},
+	},
+	{
+		Template: "{firstname:locale:xx}",
+		Comparator: func(s string) error {
+			for _, n := range FirstNames {
+				if n.GetSpelling(English) == s {
+					return nil
+				}
+			}
+			return errors.New("First Name for an unknown locale did not fall back to the default names: " + s)
+		},
+	},
+	{
+		// Every country without names of it's own is excluded
+		Template: "{country:exclude:" + strings.Join(localeCountries(false), ",") + "}@{firstname:locale:@0|fallback:error}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if inStrings(p[1], NamesByLocale[CountryLocales[p[0]]].First) {
+				return nil
+			}
+			return errors.New("First Name is not one of the names of the country: " + s)
+		},
+	},
+	{
+		// And here every country with names of it's own is
+		Template:     "{country:exclude:" + strings.Join(localeCountries(true), ",") + "}@{firstname:locale:@0|fallback:error}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{firstname:locale:xx|fallback:error}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{firstname:fallback:maybe}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int}@{firstname:locale:@0}",
+		ParseFailure: true,
+	},
+}
+
+// localeCountries returns the codes of the countries which have names of their own, or
+// of those which don't
+func localeCountries(names bool) []string {
+	codes := make([]string, 0)
+	for _, c := range CountryCodes {
+		if _, ok := CountryLocales[c]; ok == names {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+func TestLocaleNames(t *testing.T) {
+	for locale, names := range NamesByLocale {
+		if len(names.First) == 0 || len(names.Last) == 0 {
+			t.Errorf("Expected the %s locale to have both first and last names", locale)
+		}
+	}
+	for code, locale := range CountryLocales {
+		if !inStrings(code, CountryCodes) {
+			t.Errorf("Expected the locale of %s to belong to a known country code", code)
+		}
+		if _, ok := NamesByLocale[locale]; !ok {
+			t.Errorf("Expected the locale %s of %s to have names", locale, code)
+		}
+	}
+}
+
+var LastNameCases = []TestCase{
+	{
+		Template: "{lastname}",
+		Comparator: func(s string) error {
+			if len(s) > 0 {
+				return nil
+			}
+			return errors.New("First Name string not the correct length")
+		},
+	},
+	{
+		Template: "{lastname}@{lastname:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("First Name at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{lastname}@{lastname:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{lastname:locale:JA|case:up}",
+		Comparator: func(s string) error {
+			if inStrings(s, NamesByLocale["ja"].Last) {
+				return nil
+			}
+			return errors.New("Last Name is not one of the ja names: " + s)
+		},
+	},
+}
+
+var FullNameCases = []TestCase{
+	{
+		Template: "{firstname} {lastname}",
+		Comparator: func(s string) error {
+			if len(s) > 0 {
+				return nil
+			}
+			return errors.New("First / Last Name string not the correct length")
+		},
+	},
+}
+
+var GeoCases = []TestCase{
+	{
+		Template: "{geo}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, ",")
+			if len(p) != 2 {
+				return errors.New("Geo point not in lat,lng format: " + s)
+			}
+			lat, err := strconv.ParseFloat(p[0], 64)
+			if err != nil {
+				return err
+			}
+			lng, err := strconv.ParseFloat(p[1], 64)
+			if err != nil {
+				return err
+			}
+			if lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180 {
+				return nil
+			}
+			return errors.New("Geo point out of range for default bbox: " + s)
+		},
+	},
+	{
+		Template: "{geo:bbox:40.5,-74.25,40.9,-73.7}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, ",")
+			lat, err := strconv.ParseFloat(p[0], 64)
+			if err != nil {
+				return err
+			}
+			lng, err := strconv.ParseFloat(p[1], 64)
+			if err != nil {
+				return err
+			}
+			if lat >= 40.5 && lat <= 40.9 && lng >= -74.25 && lng <= -73.7 {
+				return nil
+			}
+			return errors.New("Geo point outside of the requested bbox: " + s)
+		},
+	},
+	{
+		Template: "{geo}@{geo:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Geo at position 1 not equal to geo at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{geo}@{geo:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:41,-74,40,-73}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:40,-74,41}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:-91,-74,41,-73}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:NaN,-74,41,-73}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:40,-74,nan,NaN}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:-Inf,-74,41,+Inf}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{geo:bbox:a,b,c,d}",
+		ParseFailure: true,
+	},
+}
+
+var SemverCases = []TestCase{
+	{
+		Template: "{semver}",
+		Comparator: func(s string) error {
+			if _, err := parseSemver(s); err != nil {
+				return errors.New("Semver not in correct format: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{semver:sequence:minor|base:2.3.4}",
+		Comparator: func(s string) error {
+			if s == "2.3.4" {
+				return nil
+			}
+			return errors.New("Semver sequence did not start at it's base: " + s)
+		},
+	},
+	{
+		Template: "{semver}@{semver:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Semver at position 1 not equal to semver at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{semver}@{semver:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{semver:sequence:build}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{semver:sequence:patch|base:1.0}",
+		ParseFailure: true,
+	},
+}
+
+// validGTIN checks the check digit of an EAN or UPC code, by weighting every digit from
+// the first 3 or 1 as is appropriate for the length of the code
+func validGTIN(s string, length int) error {
+	if len(s) != length {
+		return fmt.Errorf("Barcode %s is not %d digits long", s, length)
+	}
+	sum := 0
+	for i, r := range s {
+		d := int(r - '0')
+		if d < 0 || d > 9 {
+			return errors.New("Barcode contains a non-digit: " + s)
+		}
+		if (length-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	if sum%10 != 0 {
+		return errors.New("Barcode has an invalid check digit: " + s)
+	}
+	return nil
+}
+
+var BarcodeCases = []TestCase{
+	{
+		Template: "{barcode}",
+		Comparator: func(s string) error {
+			return validGTIN(s, 13)
+		},
+	},
+	{
+		Template: "{barcode:type:upca}",
+		Comparator: func(s string) error {
+			return validGTIN(s, 12)
+		},
+	},
+	{
+		Template: "{barcode}@{barcode:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Barcode at position 1 not equal to barcode at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{barcode}@{barcode:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{barcode:type:qr}",
+		ParseFailure: true,
+	},
+}
+
+// validISBN10 checks the check character of an ISBN-10
+func validISBN10(s string) error {
+	if len(s) != 10 {
+		return errors.New("ISBN-10 is not 10 characters long: " + s)
+	}
+	sum := 0
+	for i, r := range s {
+		d := int(r - '0')
+		if i == 9 && r == 'X' {
+			d = 10
+		} else if d < 0 || d > 9 {
+			return errors.New("ISBN-10 contains an invalid character: " + s)
+		}
+		sum += (10 - i) * d
+	}
+	if sum%11 != 0 {
+		return errors.New("ISBN-10 has an invalid check character: " + s)
+	}
+	return nil
+}
+
+var ISBNCases = []TestCase{
+	{
+		Template: "{isbn}",
+		Comparator: func(s string) error {
+			if !strings.HasPrefix(s, "978") {
+				return errors.New("ISBN-13 does not begin with 978: " + s)
+			}
+			return validGTIN(s, 13)
+		},
+	},
+	{
+		Template: "{isbn:version:10}",
+		Comparator: func(s string) error {
+			return validISBN10(s)
+		},
+	},
+	{
+		Template: "{isbn:hyphenated:true}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "-")
+			if len(p) != 5 || p[0] != "978" || p[1] != "0" || len(p[4]) != 1 {
+				return errors.New("ISBN-13 not hyphenated correctly: " + s)
+			}
+			return validGTIN(strings.Join(p, ""), 13)
+		},
+	},
+	{
+		Template: "{isbn:version:10|hyphenated:true}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "-")
+			if len(p) != 4 || p[0] != "0" || len(p[3]) != 1 {
+				return errors.New("ISBN-10 not hyphenated correctly: " + s)
+			}
+			return validISBN10(strings.Join(p, ""))
+		},
+	},
+	{
+		Template: "{isbn}@{isbn:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("ISBN at position 1 not equal to ISBN at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{isbn}@{isbn:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{isbn:version:12}",
+		ParseFailure: true,
+	},
+}
+
+var RepeatCases = []TestCase{
+	{
+		Template: "{repeat:count:3|sep:,|of:{int:min:1|max:10}}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, ",")
+			if len(p) != 3 {
+				return errors.New("Repeat did not produce 3 values: " + s)
+			}
+			for _, v := range p {
+				i, err := strconv.Atoi(v)
+				if err != nil {
+					return err
+				}
+				if i < 1 || i > 10 {
+					return errors.New("Repeated int out of range: " + s)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Each time through gets it's own ordinal scope, so the reference always points at
+		// the guid from the same time through
+		Template: "{repeat:count:3|sep:,|of:{guid}={guid:ordinal:0}}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, ",")
+			if len(p) != 3 {
+				return errors.New("Repeat did not produce 3 pairs: " + s)
+			}
+			seen := make(map[string]bool)
+			for _, pair := range p {
+				g := strings.Split(pair, "=")
+				if g[0] != g[1] {
+					return errors.New("Repeated guid ordinal did not refer to the guid from it's own iteration: " + s)
+				}
+				if seen[g[0]] {
+					return errors.New("Repeated guid ordinal refered to a guid from another iteration: " + s)
+				}
+				seen[g[0]] = true
+			}
+			return nil
+		},
+	},
+	{
+		// The guids generated inside of the repeat are not visible to the outer template
+		Template:     "{guid}@{repeat:count:2|of:{guid}}@{guid:ordinal:0}@{guid:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{guid}@{repeat:count:2|of:{guid}}@{guid:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[2] && p[0] != p[1][:36] {
+				return nil
+			}
+			return errors.New("Outer guid ordinal did not refer to the outer guid: " + s)
+		},
+	},
+	{
+		// Nor are the outer guids visible inside of the repeat
+		Template:     "{guid}{repeat:of:{guid:ordinal:0}}",
+		WriteFailure: true,
+	},
+	{
+		Template: "{repeat:count:0|of:{guid}}",
+		Comparator: func(s string) error {
+			if s == "" {
+				return nil
+			}
+			return errors.New("Repeat with a count of 0 produced output: " + s)
+		},
+	},
+	{
+		Template:     "{repeat:count:-1|of:{guid}}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{repeat:count:1-3|sep:,|of:{guid}}",
+		Comparator: func(s string) error {
+			if n := len(strings.Split(s, ",")); n < 1 || n > 3 {
+				return errors.New("Repeat count out of range: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{repeat:count:2|of:{geo:bbox:1,2,3}}",
+		ParseFailure: true,
+	},
+}
+
+var MimeTypeCases = []TestCase{
+	{
+		Template: "{mimetype}",
+		Comparator: func(s string) error {
+			if strings.Count(s, "/") == 1 {
+				return nil
+			}
+			return errors.New("MIME type not in type/subtype format: " + s)
+		},
+	},
+	{
+		Template: "{mimetype:category:image}",
+		Comparator: func(s string) error {
+			if strings.HasPrefix(s, "image/") {
+				return nil
+			}
+			return errors.New("MIME type not in the image category: " + s)
+		},
+	},
+	{
+		Template: "{mimetype}@{mimetype:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("MIME type at position 1 not equal to MIME type at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template:     "{mimetype}@{mimetype:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{mimetype:category:smell}",
+		ParseFailure: true,
+	},
+}
+
+var MoneyCases = []TestCase{
+	{
+		Template: "{currencycode}",
+		Comparator: func(s string) error {
+			if _, ok := FindCurrency(s); ok {
+				return nil
+			}
+			return errors.New("Currency code is not a known currency: " + s)
+		},
+	},
+	{
+		Template: "{currencycode}@{currencycode:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] == p[1] {
+				return nil
+			}
+			return errors.New("Currency code at position 1 not equal to currency code at position 0: " + p[0] + " " + p[1])
+		},
+	},
+	{
+		Template: "{money:min:1|max:2}",
+		Comparator: func(s string) error {
+			// Rounding to the minor unit can carry the amount up to the max
+			if len(s) == 5 && (strings.HasPrefix(s, "$1.") || s == "$2.00") {
+				return nil
+			}
+			return errors.New("Money not formatted in USD: " + s)
+		},
+	},
+	{
+		Template: "{money:min:10|max:11|currency:JPY}",
+		Comparator: func(s string) error {
+			if s == "¥10" || s == "¥11" {
+				return nil
+			}
+			return errors.New("Money not formatted in JPY: " + s)
+		},
+	},
+	{
+		// The money always uses the conventions of the referenced currency
+		Template: "{currencycode}@{int}@{money:min:1|max:2|currency:@0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			cur, _ := FindCurrency(p[0])
+			amount := strings.TrimPrefix(p[2], cur.Symbol)
+			if amount == p[2] {
+				return errors.New("Money does not use the symbol of it's referenced currency: " + s)
+			}
+			decimals := 0
+			if i := strings.Index(amount, "."); i >= 0 {
+				decimals = len(amount) - i - 1
+			}
+			if decimals != cur.Decimals {
+				return errors.New("Money does not use the decimals of it's referenced currency: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{money:currency:XYZ}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{money:currency:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int}{money:currency:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{money:currency:@1}{currencycode}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{money:min:5|max:1}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{cents:min:12.34|max:12.34}@{cents:ordinal:0}",
+		Comparator: exactly("1234@1234"),
+	},
+	{
+		Template:   "{cents:min:0.015|max:0.025}@{cents:min:10|max:11|currency:JPY}@{cents:min:1.2345|max:1.2355|currency:KWD}",
+		Comparator: matches(`^2@1[01]@1235$`),
+	},
+	{
+		Template: "{cents}",
+		Comparator: func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n > 100000 {
+				return errors.New("Cents is not a whole number of cents from $0 to $1000: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:   "{currencycode}@{cents:min:1|max:1|currency:@0}",
+		Comparator: matches(`^[A-Z]{3}@(1|10|100|1000)$`),
+	},
+	{
+		Template:     "{cents:min:0.011|max:0.019}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{cents:min:0.005|max:0.009}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{cents:min:1e300|max:1e301}",
+		ParseFailure: true,
+	},
+
+	{
+		Template:     "{cents:min:5|max:1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int}{cents:currency:@0}",
+		ParseFailure: true,
+	},
+}
+
+var MaskCases = []TestCase{
+	{
+		Template:   "{mask:of:{regex:pattern:[0-9]{4}-[0-9]{4}-[0-9]{4}-[0-9]{4}}}",
+		Comparator: matches(`^\*{4}-\*{4}-\*{4}-[0-9]{4}$`),
+	},
+	{
+		Template:   "{mask:of:email|show:first1,domain}",
+		Comparator: matches(`^[A-Za-z0-9][^A-Za-z0-9@]*@example\.com$`),
+	},
+	{
+		Template:   "{mask:of:ssn|show:none|char:#}",
+		Comparator: exactly("###-##-####"),
+	},
+	{
+		Template:   "{mask:of:int:min:123456|max:123456|show:first2}",
+		Comparator: exactly("12****"),
+	},
+	{
+		Template:     "{mask}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{mask:of:ssn|show:middle2}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{mask:of:ssn|show:lastx}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{mask:of:ssn|char:**}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{mask:of:{int:mim:3}}",
+		ParseFailure: true,
+	},
+}
+
+func TestAppendTo(t *testing.T) {
+	cs, err := BuildCallstack("{int:min:7|max:7},{guid:format:n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 0, 1024)
+	buf = append(buf, "rows:"...)
+	for i := 0; i < 3; i++ {
+		if buf, err = cs.AppendTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		buf = append(buf, ';')
+	}
+	if cap(buf) != 1024 {
+		t.Errorf("Expected the results to be written into the spare capacity of the buffer")
+	}
+	if err := matches(`^rows:(7,[0-9a-f]{32};){3}$`)(string(buf)); err != nil {
+		t.Error(err)
+	}
+
+	// A static template, and a nil dst
+	cs, err = BuildCallstack("static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := cs.AppendTo(nil); err != nil || string(b) != "static" {
+		t.Errorf("Expected static, got %q %v", b, err)
+	}
+
+	// A failure leaves dst as it was given
+	cs, err = BuildCallstack("{int:min:1|max:3|exclude:1,2,3}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := cs.AppendTo([]byte("before")); err == nil || string(b) != "before" {
+		t.Errorf("Expected an error and the buffer as it was given, got %q %v", b, err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	cs, err := BuildCallstack("INSERT {#: the id} ({int:min:5|as:n}, '{guid}', {int:min:@n})", WithDefault("int", "max", "50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := cs.Tokens()
+	if len(tokens) != 3 || tokens[0].Name != "int" || tokens[1].Name != "guid" || tokens[2].Name != "int" {
+		t.Fatalf("Expected an int, a guid and an int, got %+v", tokens)
+	}
+	// Defaults are filled in, including those given to BuildCallstack
+	first := tokens[0].Options
+	if first["min"] != "5" || first["max"] != "50" || first["as"] != "n" || first["maxInclusive"] != "false" {
+		t.Errorf("Expected the options given along with every default, got %v", first)
+	}
+	if tokens[1].Options["version"] != "4" {
+		t.Errorf("Expected the default version of a guid, got %v", tokens[1].Options)
+	}
+	// References are as they were written
+	if tokens[2].Options["min"] != "@n" {
+		t.Errorf("Expected the reference as it was written, got %v", tokens[2].Options)
+	}
+	// Changing what was returned doesn't change the Callstack
+	first["min"] = "6"
+	if cs.Tokens()[0].Options["min"] != "5" {
+		t.Error("Expected the options returned to be a copy")
+	}
+}
+
+func TestCallstackString(t *testing.T) {
+	cs, err := BuildCallstack("{#: the id}{int:5-10} and {guid:format:n}", WithDefault("guid", "case", "up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{int:edge:false|max:10|maxInclusive:true|min:5|ordinal:-1} and {guid:case:up|format:n|ordinal:-1|version:4}"
+	if s := cs.String(); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+
+	// Parsing the normal form again gives the same tokens, and the same normal form
+	templates := []string{
+		"static text",
+		"{regex:pattern:[a-z]{3}|[0-9]}",
+		"{repeat:count:2-3|sep:,|of:({int:max:5},'{ascii:case:up}')}",
+		`{choice:of:Smith\, John,{int:exclude:1,2}}`,
+		"{int:preset:id}@{int:type:int8|min:5}@{float:dist:exponential}",
+		"{time:format:2006-01-02 15:04|as:t}@{time:after:@t|min:1h|max:2h}",
+		"{jsonarray:count:2|of:int:min:1|max:1}@{mask:of:{ascii:length:8}|show:last2}",
+		"{country:format:flag}'{bool}{bool:not:@1}",
+	}
+	for _, tpl := range templates {
+		cs, err := BuildCallstack(tpl)
+		if err != nil {
+			t.Fatalf("%s: %v", tpl, err)
+		}
+		again, err := BuildCallstack(cs.String())
+		if err != nil {
+			t.Fatalf("%s: The normal form %s could not be parsed: %v", tpl, cs.String(), err)
+		}
+		if again.String() != cs.String() || !reflect.DeepEqual(again.Tokens(), cs.Tokens()) {
+			t.Errorf("%s: Expected the normal form %s to parse the same, got %s", tpl, cs.String(), again.String())
+		}
+	}
+}
+
+func TestLintSQL(t *testing.T) {
+	cases := []struct {
+		template string
+		// segments are what each warning is expected to be about, in order
+		segments []string
+	}{
+		{"INSERT INTO t VALUES ({int}, '{country}', {float}, {bool}, '{guid}');", nil},
+		{"INSERT INTO t VALUES ({int}, {country}, '{guid}');", []string{"the token {country} at position 29"}},
+		// Quotes are doubled to escape them in SQL, which toggles twice
+		{"INSERT INTO t VALUES ('it''s {firstname}', {lastname});", []string{"the token {lastname} at position 43"}},
+		{"INSERT INTO t VALUES ({int}, 'NULL', 'null-ish');", []string{"the text at position 27"}},
+		{"INSERT INTO t VALUES ('{choice:of:a,NULL}', {choice:of:1,2,NULL}, {bool:true:yes|false:no});", []string{
+			"the token {choice:of:a,NULL} at position 23",
+			"the token {bool:true:yes|false:no} at position 66",
+		}},
+		// The body of a {repeat} carries on from the quotes around it
+		{"INSERT INTO t VALUES {repeat:count:2|sep:,|of:({int},'{ascii}',{ascii})};", []string{"the token {ascii} at position 17"}},
+		{"INSERT INTO t VALUES ('{ascii});", []string{"the text at position 0"}},
+		{"INSERT INTO t VALUES ({int:mim:3});", []string{"the template"}},
+		// Tokens which write numbers or hex literals, with the formats that do
+		{"INSERT INTO t VALUES ({snowflake}, {cents}, {time:format:unix}, {now:format:unixms}, {blob:format:mysqlhex});", nil},
+		{"INSERT INTO t VALUES ({bytes}, {time}, {blob}, '{blob:format:pghex}');", []string{
+			"the token {bytes} at position 22",
+			"the token {time} at position 31",
+			"the token {blob} at position 39",
+		}},
+	}
+	for _, c := range cases {
+		warnings := LintSQL(c.template)
+		if len(warnings) != len(c.segments) {
+			t.Errorf("Expected %d warnings for %s, got %+v", len(c.segments), c.template, warnings)
+			continue
+		}
+		for i, w := range warnings {
+			if w.Segment != c.segments[i] || w.Message == "" {
+				t.Errorf("Expected a warning about %s for %s, got %+v", c.segments[i], c.template, w)
+			}
+		}
+	}
+}
+
+func TestSupportedTokens(t *testing.T) {
+	specs := SupportedTokens()
+	if len(specs) != len(defaultOptions) {
+		t.Fatalf("Expected %d tokens, got %d", len(defaultOptions), len(specs))
+	}
+	for i, spec := range specs {
+		if i > 0 && specs[i-1].Name >= spec.Name {
+			t.Errorf("Expected tokens sorted by name, got %s before %s", specs[i-1].Name, spec.Name)
+		}
+		// Every option needs a type, so that a new one can't be added without it
+		for _, o := range spec.Options {
+			if o.Type == "" {
+				t.Errorf("Expected a type for option %s of {%s}, please add it to optionTypes", o.Name, spec.Name)
+			}
+		}
+		if spec.Name != "int" {
+			continue
+		}
+		found := make(map[string]OptionSpec)
+		for _, o := range spec.Options {
+			found[o.Name] = o
+		}
+		if o := found["max"]; o.Type != "ref" || o.Default != "100" {
+			t.Errorf("Expected {int} to take a max which can be a ref, defaulting to 100, got %+v", o)
+		}
+		if o := found["ordinal"]; o.Default != "" {
+			t.Errorf("Expected ordinal to have no default, got %+v", o)
+		}
+		for _, name := range []string{"preset", "unique", "as"} {
+			if _, ok := found[name]; !ok {
+				t.Errorf("Expected {int} to take %s", name)
+			}
+		}
+	}
+
+	// Every option a spec lists is one BuildCallstack accepts, and it's a ref exactly
+	// when it can refer to an earlier token
+	for _, spec := range specs {
+		for _, o := range spec.Options {
+			if err := checkOptions(spec.Name, map[string]string{o.Name: ""}); err != nil {
+				t.Errorf("Expected {%s} to take %s: %v", spec.Name, o.Name, err)
+			}
+			_, ref := tokenRefs[spec.Name][o.Name]
+			if ref != (o.Type == "ref") {
+				t.Errorf("Expected option %s of {%s} to be a ref only if it's in tokenRefs, got %s", o.Name, spec.Name, o.Type)
+			}
+		}
+	}
+	for name, refs := range tokenRefs {
+		for option := range refs {
+			if err := checkOptions(name, map[string]string{option: ""}); err != nil {
+				t.Errorf("Expected {%s} to take the ref %s: %v", name, option, err)
+			}
+		}
+	}
+}
+
+func TestWriteEncoded(t *testing.T) {
+	cs, err := BuildCallstack("café {choice:of:é}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteEncoded(result, charmap.ISO8859_1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result.Bytes(), []byte("caf\xe9 \xe9")) {
+		t.Errorf("Expected the result in Latin-1, got %q", result.Bytes())
+	}
+
+	// No encoding is the same as Write
+	result.Reset()
+	if err := cs.WriteEncoded(result, nil); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "café é" {
+		t.Errorf("Expected the result in UTF-8, got %q", result.String())
+	}
+
+	// Characters that can't be encoded name where they came from, and whether another
+	// call could succeed
+	for template, temporary := range map[string]bool{"a {choice:of:Ж} b": true, "Ж {int}": false} {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.Reset()
+		err = cs.WriteEncoded(result, charmap.ISO8859_1)
+		ee, ok := err.(*EncodeError)
+		if !ok {
+			t.Fatalf("Expected an EncodeError for %s, got %v", template, err)
+		}
+		if ee.Temporary() != temporary {
+			t.Errorf("Expected Temporary to be %v for %s, in %s", temporary, template, ee.Segment)
+		}
+		if result.Len() != 0 {
+			t.Errorf("Expected nothing to be written for %s, got %q", template, result.String())
+		}
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	cs, err := BuildCallstack(`INSERT {guid:as:id}, {int:min:5|max:5|as:n}, {choice:of:a"b\c<d>}, {int:min:1000|max:1000|groupsep:,}, {float:min:1.5|max:1.5|precision:1};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteNDJSON(result, 3); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", result.String())
+	}
+	for _, l := range lines {
+		// Keys are in the order of the template, and numbers are written as numbers
+		if err := matches(`^\{"id":"[0-9a-f-]{36}","n":5,"2":"a\\"b\\\\c\\u003cd\\u003e","3":"1,000","4":1\.5\}$`)(l); err != nil {
+			t.Error(err)
+		}
+		m := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("Line is not valid JSON: %s %v", l, err)
+		}
+		if m["2"] != `a"b\c<d>` {
+			t.Errorf("Expected the string to survive escaping, got %v", m["2"])
+		}
+	}
+
+	// A template without tokens has nothing to put in it's objects
+	cs, err = BuildCallstack("static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Reset()
+	if err := cs.WriteNDJSON(result, 2); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "{}\n{}\n" {
+		t.Errorf("Expected empty objects, got %q", result.String())
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	cases := []struct {
+		value    string
+		show     string
+		expected string
+	}{
+		{"4111-1111-1111-1234", "last4", "****-****-****-1234"},
+		{"4111-1111-1111-1234", "first4,last4", "4111-****-****-1234"},
+		{"john.smith@example.com", "first1,domain", "j***.*****@example.com"},
+		{"john.smith@example.com", "domain", "****.*****@example.com"},
+		// Without domain, the domain is masked like anything else
+		{"jo@example.com", "first1", "j*@*******.***"},
+		{"Zoë", "last1", "**ë"},
+		{"123", "first2,last2", "123"},
+		{"123", "none", "***"},
+		{"", "last4", ""},
+	}
+	for _, c := range cases {
+		show, err := parseMaskShow(c.show)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := maskValue(c.value, show, '*'); m != c.expected {
+			t.Errorf("Expected %s masked with %s to be %s, got %s", c.value, c.show, c.expected, m)
+		}
+	}
+}
+
+var JSONArrayCases = []TestCase{
+	{
+		Template: "{jsonarray:count:3|of:int:min:1|max:9}",
+		Comparator: func(s string) error {
+			var a []int
+			if err := json.Unmarshal([]byte(s), &a); err != nil {
+				return errors.New("JSON array of ints is not valid JSON: " + s)
+			}
+			if len(a) != 3 {
+				return errors.New("JSON array does not have 3 values: " + s)
+			}
+			for _, i := range a {
+				if i < 1 || i > 9 {
+					return errors.New("JSON array int out of range: " + s)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{jsonarray:count:2-4|of:{unicode:length:5}}",
+		Comparator: func(s string) error {
+			var a []string
+			if err := json.Unmarshal([]byte(s), &a); err != nil {
+				return errors.New("JSON array of strings is not valid JSON: " + s)
+			}
+			if len(a) < 2 || len(a) > 4 {
+				return errors.New("JSON array length is out of range: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// A template that is more than one token is always written as strings
+		Template: "{jsonarray:count:2|of:{int} apples}",
+		Comparator: func(s string) error {
+			var a []string
+			if err := json.Unmarshal([]byte(s), &a); err != nil {
+				return errors.New("JSON array of strings is not valid JSON: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{jsonarray:count:0|of:guid}",
+		Comparator: func(s string) error {
+			if s == "[]" {
+				return nil
+			}
+			return errors.New("JSON array with a count of 0 is not empty: " + s)
+		},
+	},
+	{
+		Template:     "{jsonarray:count:5-1|of:guid}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{jsonarray:count:x|of:guid}",
+		ParseFailure: true,
+	},
+}
+
+var TimeAfterCases = []TestCase{
+	{
+		Template: "{time:format:2006-01-02T15:04:05Z07:00}|{time:after:@0|max:24h|format:2006-01-02T15:04:05Z07:00}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			created, _ := time.Parse(time.RFC3339, p[0])
+			updated, err := time.Parse(time.RFC3339, p[1])
+			if err != nil {
+				return err
+			}
+			if d := updated.Sub(created); d < 0 || d > 24*time.Hour {
+				return errors.New("Time is not within 24h after the referenced time: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// Each time can follow the one before it, and can follow a {now}
+		Template: "{now:format:2006-01-02T15:04:05Z07:00}|{time:after:@0|min:1h|max:2h|format:2006-01-02T15:04:05Z07:00}|{time:after:@1|min:1h|max:1h|format:2006-01-02T15:04:05Z07:00}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			t := make([]time.Time, 3)
+			for i := range p {
+				var err error
+				if t[i], err = time.Parse(time.RFC3339, p[i]); err != nil {
+					return err
+				}
+			}
+			if d := t[1].Sub(t[0]); d < time.Hour || d > 2*time.Hour {
+				return errors.New("Time is not 1h to 2h after the referenced now: " + s)
+			}
+			if t[2].Sub(t[1]) != time.Hour {
+				return errors.New("Time is not exactly 1h after the referenced time: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{int}|{time:after:@0|max:24h}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{time}|{time:after:@0|max:-24h}",
+		ParseFailure: true,
+	},
+	{
+		// max has to be given as a duration
+		Template:     "{time}|{time:after:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{time:after:@0|max:24h}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{time}|{time:after:0|max:24h}",
+		ParseFailure: true,
+	},
+}
+
+var TimeSortedCases = []TestCase{
+	{
+		Template:     "{time:sorted:asc}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{repeat:of:{jsonarray:of:{time:sorted:asc}}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{repeat:of:{time:sorted:up}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{repeat:of:{time}{time:sorted:asc|after:@0|max:1h}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{repeat:of:{time:sorted:asc|unique:true}}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{repeat:count:0|of:{time:sorted:asc}}",
+		Comparator: exactly(""),
+	},
+}
+
+func TestSortedTime(t *testing.T) {
+	// Two sorted times in the same body are sorted independently of each other, and a
+	// nested repeat sorts across it's own iterations each time through
+	cs, err := BuildCallstack("{repeat:count:5-50|sep:,|of:{time:sorted:asc|min:1000|max:2000|format:unix}/{time:sorted:desc|format:unix}/{repeat:count:3|sep:;|of:{time:sorted:asc|format:unix}}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		result := &bytes.Buffer{}
+		if err := cs.Write(result); err != nil {
+			t.Fatal(err)
+		}
+		var asc, desc []int64
+		inner := make([][]int64, 0)
+		for _, iteration := range strings.Split(result.String(), ",") {
+			p := strings.Split(iteration, "/")
+			a, _ := strconv.ParseInt(p[0], 10, 64)
+			d, _ := strconv.ParseInt(p[1], 10, 64)
+			if a < 1000 || a > 2000 {
+				t.Fatalf("Expected a sorted time within it's bounds, got %d", a)
+			}
+			asc, desc = append(asc, a), append(desc, d)
+			in := make([]int64, 0)
+			for _, s := range strings.Split(p[2], ";") {
+				n, _ := strconv.ParseInt(s, 10, 64)
+				in = append(in, n)
+			}
+			inner = append(inner, in)
+		}
+		for j := 1; j < len(asc); j++ {
+			if asc[j] < asc[j-1] || desc[j] > desc[j-1] {
+				t.Fatalf("Expected the times to be sorted across every iteration: %s", result.String())
+			}
+		}
+		for _, in := range inner {
+			if len(in) != 3 || in[1] < in[0] || in[2] < in[1] {
+				t.Fatalf("Expected the nested times to be sorted across their own iterations: %s", result.String())
+			}
+		}
+	}
+}
+
+var TimeSkipCases = []TestCase{
+	{
+		Template: "{time:skip:weekends|format:Monday}",
+		Comparator: func(s string) error {
+			if s == "Saturday" || s == "Sunday" {
+				return errors.New("Time falls on a weekend: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// From Sunday the 6th to Wednesday the 9th of January 2019 in Tokyo, skipping the
+		// weekend and two holidays leaves only the 9th
+		Template: "{time:min:1546700400|max:1547045999|zone:Asia/Tokyo|skip:weekends,holidays|holidays:2019-01-07,2019-01-08|format:2006-01-02}",
+		Comparator: func(s string) error {
+			if s != "2019-01-09" {
+				return errors.New("Time falls on a skipped day: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// This is Monday morning in Tokyo, but still Sunday in UTC, so weekends are
+		// skipped in the zone of the time
+		Template: "{time:min:1546786800|max:1546819199|zone:Asia/Tokyo|skip:weekends|format:Monday}",
+		Comparator: func(s string) error {
+			if s != "Monday" {
+				return errors.New("Time was not Monday in Tokyo: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{time:skip:mondays}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{time:skip:holidays}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{time:skip:holidays|holidays:christmas}",
+		ParseFailure: true,
+	},
+	{
+		// There are no weekdays between Saturday and Sunday
+		Template:     "{time:min:1547251200|max:1547337600|zone:UTC|skip:weekends}",
+		WriteFailure: true,
+	},
+}
+
+// floatSign returns a Comparator checking the sign of a float
+func floatSign(ok func(float64) bool) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		if !ok(n) {
+			return errors.New("Float does not have the right sign: " + s)
+		}
+		return nil
+	}
+}
+
+var RangeShorthandCases = []TestCase{
+	{
+		Template:   "{int:7-7}",
+		Comparator: exactly("7"),
+	},
+	{
+		Template:   "{int:-10--10}@{int:-3-3|exclude:-3,-2,-1,0,1,2}",
+		Comparator: exactly("-10@3"),
+	},
+	{
+		Template:   "{float:1.5-1.5|precision:2}",
+		Comparator: exactly("1.50"),
+	},
+	{
+		Template:   "{float:-2--2|precision:1}",
+		Comparator: exactly("-2.0"),
+	},
+	{
+		Template:     "{int:5-10|min:6}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:max:6|5-10}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:10-5}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:1.5-3}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{guid:1-3}",
+		ParseFailure: true,
+	},
+}
+
+func TestParseRangeShorthand(t *testing.T) {
+	cases := []struct {
+		s        string
+		decimal  bool
+		min, max string
+		ok       bool
+	}{
+		{"5-10", false, "5", "10", true},
+		{"-10--5", false, "-10", "-5", true},
+		{"-10-5", false, "-10", "5", true},
+		{"0-0", false, "0", "0", true},
+		{"-1.5-2.25", true, "-1.5", "2.25", true},
+		{"1.5-2", false, "", "", false},
+		{"5", false, "", "", false},
+		{"-5", false, "", "", false},
+		{"5-", false, "", "", false},
+		{"-", false, "", "", false},
+		{"1-2-3", false, "", "", false},
+		{"--1-2", false, "", "", false},
+		{"1--", false, "", "", false},
+		{"1e3-2", true, "", "", false},
+		{".5-1", true, "", "", false},
+		{"1.-2", true, "", "", false},
+	}
+	for _, c := range cases {
+		min, max, ok := parseRangeShorthand(c.s, c.decimal)
+		if ok != c.ok || (ok && (min != c.min || max != c.max)) {
+			t.Errorf("Expected %s to be %s to %s (%v), got %s to %s (%v)", c.s, c.min, c.max, c.ok, min, max, ok)
+		}
+	}
+}
+
+var IntPresetCases = []TestCase{
+	{
+		Template: "{int:preset:id}",
+		Comparator: func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			if n < 1000 || n > 9999999 {
+				return errors.New("Id preset out of range: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:   "{int:preset:id|max:1000}",
+		Comparator: exactly("1000"),
+	},
+	{
+		Template:     "{int:preset:nope}",
+		ParseFailure: true,
+	},
+}
+
+var FloatInclusiveCases = []TestCase{
+	{
+		Template: "{float:min:2.5|max:2.5}",
+		Comparator: func(s string) error {
+			if n, _ := strconv.ParseFloat(s, 64); n == 2.5 {
+				return nil
+			}
+			return errors.New("Float out of range for a range of one value: " + s)
+		},
+	},
+	{
+		Template: "{float:min:-1|max:1|maxInclusive:false}",
+		Comparator: func(s string) error {
+			if n, _ := strconv.ParseFloat(s, 64); n >= -1 && n <= 1 {
+				return nil
+			}
+			return errors.New("Float out of range for an exclusive max: " + s)
+		},
+	},
+	{
+		Template:   "{float:min:2.5|max:2.5|maxInclusive:false}",
+		Comparator: exactly("2.500000"),
+	},
+	{
+		Template: "{float:min:-1|max:1|maxInclusive:true}",
+		Comparator: func(s string) error {
+			if n, _ := strconv.ParseFloat(s, 64); n >= -1 && n <= 1 {
+				return nil
+			}
+			return errors.New("Float out of range for an inclusive max: " + s)
+		},
+	},
+}
+
+// exactly returns a comparator which expects the result to be exactly s
+func exactly(s string) TestComparator {
+	return func(result string) error {
+		if result != s {
+			return fmt.Errorf("Expected %s, got %s", s, result)
+		}
+		return nil
+	}
+}
+
+func TestRoundFloat(t *testing.T) {
+	for _, c := range []struct {
+		n         float64
+		format    byte
+		precision int
+		mode      string
+		expected  string
+	}{
+		{2.675, 'f', 2, "halfup", "2.68"},
+		{2.665, 'f', 2, "halfeven", "2.66"},
+		{2.675, 'f', 2, "halfeven", "2.68"},
+		{2.6651, 'f', 2, "halfeven", "2.67"},
+		{2.679, 'f', 2, "truncate", "2.67"},
+		{-2.679, 'f', 2, "truncate", "-2.67"},
+		{-2.675, 'f', 2, "halfup", "-2.68"},
+		{9.995, 'f', 2, "halfup", "10.00"},
+		{0.5, 'f', 0, "halfeven", "0"},
+		{1.5, 'f', 0, "halfeven", "2"},
+		{3, 'f', 2, "halfup", "3.00"},
+		{12345, 'e', 2, "halfup", "1.23e+04"},
+		{12355, 'e', 2, "halfeven", "1.24e+04"},
+		{99950, 'e', 2, "halfup", "1.00e+05"},
+		{0.00012345, 'e', 3, "truncate", "1.234e-04"},
+	} {
+		if s := roundFloat(c.n, c.format, c.precision, c.mode); s != c.expected {
+			t.Errorf("Expected %v rounded %s to %d digits to be %s, got %s", c.n, c.mode, c.precision, c.expected, s)
+		}
+	}
+}
+
+func TestExponentialFloat(t *testing.T) {
+	cs, err := BuildCallstack("{float:dist:exponential|rate:0.5}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 5000); err != nil {
+		t.Fatal(err)
+	}
+	sum, over := 0.0, 0
+	for _, row := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+		n, err := strconv.ParseFloat(row, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 0 {
+			t.Fatalf("Expected an exponential float to never be negative, got %s", row)
+		}
+		if n > 6 {
+			over++
+		}
+		sum += n
+	}
+	// The mean is 1 / rate, and about 5% of values are over 3 / rate
+	if mean := sum / 5000; mean < 1.8 || mean > 2.2 {
+		t.Errorf("Expected a mean of about 2 for a rate of 0.5, got %f", mean)
+	}
+	if over < 150 || over > 350 {
+		t.Errorf("Expected about 250 values over 6 for a rate of 0.5, got %d", over)
+	}
+}
+
+var SeparatorCases = []TestCase{
+	{
+		Template:   "{float:min:0.42|max:0.42|precision:2|decimalsep:,}",
+		Comparator: exactly("0,42"),
+	},
+	{
+		Template:   "{float:min:1234.56|max:1234.56|precision:2|decimalsep:,|groupsep:.}",
+		Comparator: exactly("1.234,56"),
+	},
+	{
+		Template:   "{float:min:-1234567.5|max:-1234567.5|precision:1|groupsep:'}",
+		Comparator: exactly("-1'234'567.5"),
+	},
+	{
+		Template:   "{float:min:12345|max:12345|format:scientific|precision:2|decimalsep:,}",
+		Comparator: exactly("1,23e+04"),
+	},
+	{
+		Template:   "{float:min:0.5|max:0.5|precision:1}@{float:ordinal:0|precision:1|decimalsep:,}",
+		Comparator: exactly("0.5@0,5"),
+	},
+	{
+		Template: "{float:min:-1|max:1|precision:2|decimalsep:,|sign:positive}",
+		Comparator: func(s string) error {
+			if !regexp.MustCompile(`^[01],\d\d$`).MatchString(s) || s == "0,00" {
+				return errors.New("Float is not positive with a decimal comma: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:   "{int:min:1234567|max:1234567|groupsep:,}@{int:ordinal:0}",
+		Comparator: exactly("1,234,567@1234567"),
+	},
+	{
+		Template:   "{int:min:-123|max:-123|groupsep:,}",
+		Comparator: exactly("-123"),
+	},
+	{
+		Template:   "{jsonarray:count:2|of:{float:min:1.5|max:1.5|precision:1|decimalsep:,}}",
+		Comparator: exactly(`["1,5","1,5"]`),
+	},
+	{
+		Template:   "{jsonarray:count:2|of:int:min:1000|max:1000|groupsep:,}",
+		Comparator: exactly(`["1,000","1,000"]`),
+	},
+	{
+		Template:   "{float:min:2.675|max:2.675|precision:2|round:halfup|decimalsep:,}",
+		Comparator: exactly("2,68"),
+	},
+	{
+		Template:     "{float:round:bankers}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{float:dist:exponential|rate:100|min:1|max:2}",
+		Comparator: func(s string) error {
+			if n, _ := strconv.ParseFloat(s, 64); n != 1 {
+				return errors.New("Exponential float was not clamped to min: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{float:dist:exponential|rate:0.0001|max:3}",
+		Comparator: func(s string) error {
+			if n, _ := strconv.ParseFloat(s, 64); n < 0 || n > 3 {
+				return errors.New("Exponential float was not clamped to max: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{float:dist:exponential|rate:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:dist:exponential|rate:-1}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:dist:poisson}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:groupsep:.}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:decimalsep:,|groupsep:,}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:decimalsep:}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:decimalsep:1}",
+		ParseFailure: true,
+	},
+	{
+		// Only a number with a decimal part can confuse it's separators
+		Template:   "{int:min:1234567|max:1234567|groupsep:.}",
+		Comparator: exactly("1.234.567"),
+	},
+	{
+		Template:   "{float:min:1234567|max:1234567|precision:0|groupsep:.}",
+		Comparator: exactly("1.234.567"),
+	},
+}
+
+var FloatSignCases = []TestCase{
+	{
+		Template:   "{float:min:-100|max:100|sign:positive}",
+		Comparator: floatSign(func(n float64) bool { return n > 0 }),
+	},
+	{
+		Template:   "{float:min:-100|max:100|sign:negative}",
+		Comparator: floatSign(func(n float64) bool { return n < 0 }),
+	},
+	{
+		// Rounding to no decimal places makes 0 likely, which nonzero must reroll
+		Template:   "{float:min:-1|max:1|precision:0|sign:nonzero}",
+		Comparator: floatSign(func(n float64) bool { return n != 0 }),
+	},
+	{
+		Template:     "{float:min:-100|max:0|sign:positive}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:min:0|max:100|sign:negative}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:min:0|max:0|sign:nonzero}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{float:sign:up}",
+		ParseFailure: true,
+	},
+}
+
+var BlobCases = []TestCase{
+	{
+		Template: "{blob}",
+		Comparator: func(s string) error {
+			if !regexp.MustCompile(`^\\x[0-9a-f]{32}$`).MatchString(s) {
+				return errors.New("Blob is not 16 bytes in Postgres hex format: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{blob:length:4|format:mysqlhex}",
+		Comparator: func(s string) error {
+			if !regexp.MustCompile(`^0x[0-9A-F]{8}$`).MatchString(s) {
+				return errors.New("Blob is not 4 bytes in MySQL hex format: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{blob:length:8}@{blob:ordinal:0}@{blob:ordinal:0|format:mysqlhex}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] {
+				return errors.New("Blob at position 1 not equal to blob at position 0: " + s)
+			}
+			if !strings.EqualFold(strings.TrimPrefix(p[0], "\\x"), strings.TrimPrefix(p[2], "0x")) {
+				return errors.New("Blob at position 2 is not blob 0 in MySQL hex format: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{blob}@{blob:ordinal:1}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{blob:format:base64}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{blob:length:0}",
+		ParseFailure: true,
+	},
+}
+
+var LoremCases = []TestCase{
+	{
+		Template: "{lorem}",
+		Comparator: func(s string) error {
+			words := strings.Split(s, " ")
+			if len(words) != 5 {
+				return errors.New("Lorem does not have 5 words: " + s)
+			}
+			for _, w := range words {
+				if !inStrings(w, LoremWords) {
+					return errors.New("Lorem word is not from the latin list: " + s)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{lorem:words:2}@{lorem:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "@")
+			if p[0] != p[1] || len(strings.Split(p[0], " ")) != 2 {
+				return errors.New("Lorem at position 1 not equal to 2 words of lorem at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{lorem:words:3|paragraphs:2|markup:html}",
+		Comparator: func(s string) error {
+			if !regexp.MustCompile(`^<p>\w+ \w+ \w+</p><p>\w+ \w+ \w+</p>$`).MatchString(s) {
+				return errors.New("Lorem is not 2 HTML paragraphs of 3 words: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{lorem:words:3|paragraphs:3|markup:markdown}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "\n\n")
+			if len(p) != 3 {
+				return errors.New("Lorem is not 3 markdown paragraphs: " + s)
+			}
+			for _, w := range p {
+				if len(strings.Fields(w)) != 3 {
+					return errors.New("Lorem markdown paragraph does not have 3 words: " + s)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{lorem:markup:rtf}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:paragraphs:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:list:klingon}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:words:0}",
+		ParseFailure: true,
+	},
+	{
+		Template: "{lorem:model:markov|sentences:3}",
+		Comparator: func(s string) error {
+			if strings.Count(s, ".") != 3 || !strings.HasSuffix(s, ".") {
+				return errors.New("Lorem does not have 3 sentences: " + s)
+			}
+			return markovWords(s)
+		},
+	},
+	{
+		Template: "{lorem:model:markov|words:12|paragraphs:2}",
+		Comparator: func(s string) error {
+			for _, p := range strings.Split(s, "\n") {
+				if len(strings.Fields(p)) != 12 || !strings.HasSuffix(p, ".") {
+					return errors.New("Lorem markov paragraph does not have 12 words ending in a full stop: " + s)
+				}
+				if err := markovWords(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{lorem:sentences:3}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:model:markov|sentences:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:model:markov|list:tech}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{lorem:model:gpt}",
+		ParseFailure: true,
+	},
+}
+
+// markovWords checks that every word of markov lorem comes from the text it's trained on
+func markovWords(s string) error {
+	for _, w := range strings.Fields(s) {
+		if !strings.Contains(LoremText, strings.TrimRight(w, ",.")) {
+			return errors.New("Lorem markov word is not from the latin text: " + s)
+		}
+	}
+	return nil
+}
+
+func TestMarkovChain(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		words := loremChain.sentence(r)
+		// Every three words in a row were three words in a row of the text, other than
+		// the full stop an overlong sentence is given
+		for j := 0; j+2 < len(words); j++ {
+			triple := strings.TrimSuffix(strings.Join(words[j:j+3], " "), ".")
+			if !strings.Contains(LoremText, triple) {
+				t.Errorf("Expected %q to be in the text the chain was trained on", triple)
+			}
+		}
+		if len(words) > maxSentenceWords {
+			t.Errorf("Expected a sentence of at most %d words, got %d", maxSentenceWords, len(words))
+		}
+	}
+}
+
+func TestRegisterIntPreset(t *testing.T) {
+	if err := RegisterIntPreset("empty", nil); err == nil {
+		t.Error("Expected an error registering an empty preset")
+	}
+	if err := RegisterIntPreset("typo", map[string]string{"mim": "5"}); err == nil {
+		t.Error("Expected an error registering a preset with an unknown option")
+	}
+	if err := RegisterIntPreset("nested", map[string]string{"preset": "id"}); err == nil {
+		t.Error("Expected an error registering a preset which refers to another")
+	}
+	if err := RegisterIntPreset("port", map[string]string{"min": "1024", "type": "uint16"}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		template string
+		min      int
+		max      int
+	}{
+		{"{int:preset:port}", 1024, 65535},
+		{"{int:preset:port|max:2048}", 1024, 2048},
+		// A type in the template replaces the whole range of the preset
+		{"{int:preset:port|type:uint8}", 0, 255},
+	}
+	for _, c := range cases {
+		cs, err := BuildCallstack(c.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			result := &bytes.Buffer{}
+			if err := cs.Write(result); err != nil {
+				t.Fatal(err)
+			}
+			n, err := strconv.Atoi(result.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n < c.min || n > c.max {
+				t.Errorf("%s: Expected a value from %d to %d, got %d", c.template, c.min, c.max, n)
+			}
+		}
+	}
+}
+
+func TestRegisterWordList(t *testing.T) {
+	if err := RegisterWordList("empty", nil); err == nil {
+		t.Error("Expected an error registering an empty word list")
+	}
+	tech := []string{"kubernetes", "latency", "sharding"}
+	if err := RegisterWordList("tech", tech); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := BuildCallstack("{lorem:words:20|list:tech}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(result.String(), " ")
+	if len(words) != 20 {
+		t.Errorf("Expected 20 words, got %q", result.String())
+	}
+	for _, w := range words {
+		if !inStrings(w, tech) {
+			t.Errorf("Expected only words from the tech list, got %q", result.String())
+		}
+	}
+
+	// Words are escaped when they're written out as HTML
+	if err := RegisterWordList("markup", []string{"<b>&"}); err != nil {
+		t.Fatal(err)
+	}
+	cs, err = BuildCallstack("{lorem:words:2|list:markup|markup:html}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Reset()
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "<p>&lt;b&gt;&amp; &lt;b&gt;&amp;</p>" {
+		t.Errorf("Expected the words to be HTML escaped, got %q", result.String())
+	}
+}
+
+var PaletteCases = []TestCase{
+	{
+		Template: "{palette}",
+		Comparator: func(s string) error {
+			colors := strings.Split(s, ",")
+			if len(colors) != 5 {
+				return errors.New("Palette does not have 5 colors: " + s)
+			}
+			seen := make(map[string]bool)
+			for _, c := range colors {
+				if !regexp.MustCompile(`^#[0-9a-f]{6}$`).MatchString(c) {
+					return errors.New("Palette color is not a hex color: " + s)
+				}
+				if seen[c] {
+					return errors.New("Palette color is repeated: " + s)
+				}
+				seen[c] = true
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{palette:count:10|scheme:colorblind|overflow:cycle}",
+		Comparator: func(s string) error {
+			colors := strings.Split(s, ",")
+			if len(colors) != 10 {
+				return errors.New("Palette does not have 10 colors: " + s)
+			}
+			// The first 8 colors use the whole palette before it cycles
+			seen := make(map[string]bool)
+			for _, c := range colors[:8] {
+				seen[c] = true
+			}
+			if len(seen) != 8 || colors[8] != colors[0] || colors[9] != colors[1] {
+				return errors.New("Palette did not cycle through every color: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{palette}|{palette:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[0] != p[1] {
+				return errors.New("Palette at position 1 not equal to palette at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{palette:count:11}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{palette:scheme:rainbow}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{palette:count:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{palette:overflow:wrap}",
+		ParseFailure: true,
+	},
+}
+
+// MalformedCases are templates which once caused BuildCallstack to panic, or to quietly
+// make something up, rather than return an error
+var MalformedCases = []TestCase{
+	{
+		Template:     "{",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{:}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int:min:}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{:x}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "id: {guid",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{repeat:count:2|of:{guid}",
+		ParseFailure: true,
+	},
+	{
+		Template:   "{repeat:count:2|of:{guid}}}",
+		Comparator: matches(`^[-0-9a-f]{72}}$`),
+	},
+	{
+		Template:     "{int:ordinal:x}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{int}{int:ordinal:99999999999999999999}",
+		ParseFailure: true,
+	},
+}
+
+var InvalidTokenCases = []TestCase{
+	{
+		Template:     "{firstname} {plastname}",
+		WriteFailure: true,
+	},
+	{
+		Template:     "{firstname:language:onglish}",
+		WriteFailure: true,
+	},
+}
+
+var EmailCases = []TestCase{
+	{
+		Template: "{firstname}|{lastname}|{email:from:@0,@1}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[2] != emailSafe(p[0])+"."+emailSafe(p[1])+"@example.com" {
+				return errors.New("Email was not built from the referenced names: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{lastname}|{firstname}|{email:from:@1,0|domain:test.org}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[2] != emailSafe(p[1])+"."+emailSafe(p[0])+"@test.org" {
+				return errors.New("Email was not built from the referenced names: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{email}|{email:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[0] != p[1] {
+				return errors.New("Email at position 1 not equal to email at position 0: " + s)
+			}
+			if !regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9]+)*@example\.com$`).MatchString(p[0]) {
+				return errors.New("Email is not a valid address: " + p[0])
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{guid}{email:from:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{email:from:@0}{firstname}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{firstname}{lastname}{firstname}{email:from:@0,@1,@2}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{firstname}{email:from:0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{email:domain:}",
+		ParseFailure: true,
+	},
+}
+
+// addressPattern matches a street, followed by a city, state and ZIP code
+var addressPattern = regexp.MustCompile(`^(\d+ \w+ \w+)(\n|, )([A-Za-z ]+), ([A-Z]{2}) (\d{5})$`)
+
+// validAddress checks that an address is well formed, and that it's city, state and
+// ZIP code all agree with each other
+func validAddress(s string, sep string) error {
+	m := addressPattern.FindStringSubmatch(s)
+	if m == nil || m[2] != sep {
+		return errors.New("Address is not formatted correctly: " + s)
+	}
+	for _, c := range Cities {
+		if c.Name == m[3] && c.State == m[4] && strings.HasPrefix(m[5], c.ZipPrefix) {
+			return nil
+		}
+	}
+	return errors.New("Address city, state and ZIP code do not agree: " + s)
+}
+
+var AddressCases = []TestCase{
+	{
+		Template: "{address}",
+		Comparator: func(s string) error {
+			return validAddress(s, "\n")
+		},
+	},
+	{
+		Template: "{address:format:oneline}",
+		Comparator: func(s string) error {
+			return validAddress(s, ", ")
+		},
+	},
+	{
+		Template: "{address}|{address:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[0] != p[1] {
+				return errors.New("Address at position 1 not equal to address at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{address}|{address:ordinal:0|format:oneline}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if strings.Replace(p[0], "\n", ", ", 1) != p[1] {
+				return errors.New("Address at position 1 is not address at position 0 on one line: " + s)
+			}
+			return validAddress(p[1], ", ")
+		},
+	},
+	{
+		// Once the whitespace is collapsed, the street can't be told apart by it's
+		// separator, so the ordinal has to reformat it from the parts it was made of
+		Template: "{address:collapsews:true}|{address:ordinal:0|format:oneline}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if strings.Replace(p[1], ", ", " ", 1) != p[0] {
+				return errors.New("Address at position 1 is not address at position 0 on one line: " + s)
+			}
+			return validAddress(p[1], ", ")
+		},
+	},
+	{
+		Template:     "{address:format:sideways}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{address:ordinal:0}",
+		WriteFailure: true,
+	},
+}
+
+// zipInState reports whether the ZIP code is served by one of the cities in the state
+func zipInState(zip string, state string) bool {
+	for _, c := range CitiesIn(state) {
+		if strings.HasPrefix(zip, c.ZipPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var ZipCases = []TestCase{
+	{
+		Template: "{state}|{state:format:name}|{state:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if _, ok := FindState(p[0]); !ok || len(p[0]) != 2 {
+				return errors.New("State is not a known state code: " + s)
+			}
+			if _, ok := FindState(p[1]); !ok || len(p[1]) <= 2 {
+				return errors.New("State is not a known state name: " + s)
+			}
+			if p[0] != p[2] {
+				return errors.New("State at position 2 not equal to state at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{zip}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^\d{5}$`).MatchString(s) {
+				return nil
+			}
+			return errors.New("ZIP code is not 5 digits: " + s)
+		},
+	},
+	{
+		Template: "{zip:plus4:true}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^\d{5}-\d{4}$`).MatchString(s) {
+				return nil
+			}
+			return errors.New("ZIP+4 code is not formatted correctly: " + s)
+		},
+	},
+	{
+		Template: "{state}|{zip:state:@0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if !zipInState(p[1], p[0]) {
+				return errors.New("ZIP code is not in the referenced state: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{state:format:name}|{zip:state:@0|plus4:true}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			st, _ := FindState(p[0])
+			if !zipInState(p[1], st.Code) {
+				return errors.New("ZIP code is not in the referenced state: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{zip:state:TX}",
+		Comparator: func(s string) error {
+			if !zipInState(s, "TX") {
+				return errors.New("ZIP code is not in TX: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{country}{zip:state:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{zip:state:@0}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{zip:country:CA}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{zip:state:Narnia}",
+		ParseFailure: true,
+	},
+}
+
+var SSNCases = []TestCase{
+	{
+		Template: "{ssn}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^9\d\d-(0[1-9]|[1-9]\d)-(000[1-9]|00[1-9]\d|0[1-9]\d\d|[1-9]\d{3})$`).MatchString(s) {
+				return nil
+			}
+			return errors.New("SSN is not a dashed SSN in the 900 series: " + s)
+		},
+	},
+	{
+		Template: "{ssn:format:plain}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^9\d{8}$`).MatchString(s) {
+				return nil
+			}
+			return errors.New("SSN is not 9 digits in the 900 series: " + s)
+		},
+	},
+	{
+		Template: "{ssn}|{ssn:ordinal:0}|{ssn:ordinal:0|format:plain}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[0] != p[1] {
+				return errors.New("SSN at position 1 not equal to SSN at position 0: " + s)
+			}
+			if strings.Replace(p[0], "-", "", -1) != p[2] {
+				return errors.New("SSN at position 2 is not SSN at position 0 without dashes: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{ssn:format:dotted}",
+		ParseFailure: true,
+	},
+}
+
+// matchesPattern returns a Comparator checking that the result is a whole match of the
+// given regular expression
+func matchesPattern(pattern string) func(string) error {
+	re := regexp.MustCompile("^(?:" + pattern + ")$")
+	return func(s string) error {
+		if re.MatchString(s) {
+			return nil
+		}
+		return errors.New("Regex result " + strconv.Quote(s) + " does not match " + pattern)
+	}
+}
+
+var RegexCases = []TestCase{
+	{
+		Template:   "{regex:pattern:[A-Z]{3}[0-9]{4}}",
+		Comparator: matchesPattern("[A-Z]{3}[0-9]{4}"),
+	},
+	{
+		Template:   "{regex:pattern:(cat|dog|bird)s?}",
+		Comparator: matchesPattern("(cat|dog|bird)s?"),
+	},
+	{
+		// Alternation doesn't need a group, as the pattern takes the rest of the options
+		Template:   "{regex:ordinal:-1|pattern:yes|no|maybe}",
+		Comparator: matchesPattern("yes|no|maybe"),
+	},
+	{
+		Template:   "{regex:pattern:^\\d{2,4}-[^a-z]{5}\\.\\w?$}",
+		Comparator: matchesPattern("\\d{2,4}-[^a-z]{5}\\.\\w?"),
+	},
+	{
+		Template:   "{regex:pattern:(?:[a-c-]{2}|x{0,3}):.}",
+		Comparator: matchesPattern("(?:[a-c-]{2}|x{0,3}):."),
+	},
+	{
+		Template: "{regex:pattern:[a-z]{10}}|{regex:ordinal:0}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if p[0] != p[1] {
+				return errors.New("Regex at position 1 not equal to regex at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{regex:pattern:a*}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:[0-9]+}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:a{2,}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:a{1,100000}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:(ab}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:ab)}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:[abc}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:[z-a]}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:\\bword}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:a??}",
+		ParseFailure: true,
+	},
+	{
+		// Each quantifier is within bounds, but nested they multiply
+		Template:     "{regex:pattern:((a{1000}){1000}){1000}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{regex:pattern:(ab|c{101}){100}}",
+		ParseFailure: true,
+	},
+	{
+		// Exactly as long as is allowed
+		Template: "{regex:pattern:((ab){50}){100}}",
+		Comparator: func(s string) error {
+			if s != strings.Repeat("ab", 5000) {
+				return errors.New("Expected ab repeated 5000 times, got " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{regex}",
+		ParseFailure: true,
+	},
+}
+
+var StringCases = []TestCase{
+	{
+		Template:   "{string:match:[A-Z]{3}-(cat|dog)}@{string:ordinal:0}",
+		Comparator: matches(`^([A-Z]{3}-(cat|dog))@([A-Z]{3}-(cat|dog))$`),
+	},
+	{
+		// Every value is invalid, and so never matches the pattern
+		Template: "{string:invalid:1|match:[A-Z]{3}}@{string:ordinal:0}",
+		Comparator: func(s string) error {
+			// An invalid string can have an @ in it, so split it in the middle
+			half := len(s) / 2
+			p := []string{s[:half], s[half+1:]}
+			if regexp.MustCompile(`^[A-Z]{3}$`).MatchString(p[0]) {
+				return errors.New("Invalid string matches it's pattern: " + s)
+			}
+			if p[1] != p[0] {
+				return errors.New("String at position 1 not equal to string at position 0: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{string:invalid:1|match:\\d{2}|[a-c]?}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^(?:\d{2}|[a-c]?)$`).MatchString(s) {
+				return errors.New("Invalid string matches it's pattern: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// An option the token takes can follow the pattern
+		Template: "{string:match:[A-Z]{3}|invalid:1}",
+		Comparator: func(s string) error {
+			if regexp.MustCompile(`^[A-Z]{3}$`).MatchString(s) {
+				return errors.New("Invalid string matches it's pattern: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		// But not inside of a group, or escaped
+		Template:   "{string:match:(a|invalid:1)}",
+		Comparator: matches(`^(a|invalid:1)$`),
+	},
+	{
+		Template:   "{string:match:a\\|invalid:1}",
+		Comparator: matches(`^a\|invalid:1$`),
+	},
+	{
+		// No single change stops the pattern matching
+		Template:     "{string:invalid:0.5|match:.{0,1000}}",
+		ParseFailure: true,
+	},
+	{
+		// Go's regexp package rejects the nested repeats, so invalid values can't be
+		// checked against it
+		Template:     "{string:invalid:0.5|match:((ab){50}){100}}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{string:invalid:1.5|match:a}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{string:match:a*}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{string}",
+		ParseFailure: true,
+	},
+}
+
+func TestStringInvalidRate(t *testing.T) {
+	cs, err := BuildCallstack("{string:invalid:0.1|match:[A-Z]{3}[0-9]{2}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 1000); err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^[A-Z]{3}[0-9]{2}$`)
+	invalid := 0
+	for _, s := range strings.Split(result.String(), "\n") {
+		if !re.MatchString(s) {
+			invalid++
+		}
+	}
+	// 1 in 10 should be invalid, with plenty of room for chance
+	if invalid < 50 || invalid > 150 {
+		t.Errorf("Expected around 100 of 1000 strings to be invalid, got %d", invalid)
+	}
+}
+
+var UnicodeExcludeCases = []TestCase{
+	{
+		// Exclude the whole of Phoenician, one code point at a time
+		Template: "{unicode:length:200|exclude:" + phoenicianExcludes() + "}",
+		Comparator: func(s string) error {
+			for _, r := range s {
+				if r >= 0x10900 && r <= 0x1091f {
+					return errors.New("Unicode string contains an excluded character: " + s)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Template: "{unicode:exclude:\\u0000,\\U0000200b,\\x41,Ж}",
+		Comparator: func(s string) error {
+			if strings.ContainsAny(s, "\u0000\u200bAЖ") {
+				return errors.New("Unicode string contains an excluded character: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{unicode:exclude:\\u12}",
+		ParseFailure: true,
+	},
+	{
+		Template:     "{unicode:exclude:ab}",
+		ParseFailure: true,
+	},
+}
+
+// phoenicianExcludes lists every code point in the Phoenician range, escaped for the
+// exclude option of {unicode}
+func phoenicianExcludes() string {
+	p := make([]string, 0)
+	for r := 0x10900; r <= 0x1091f; r++ {
+		p = append(p, fmt.Sprintf("\\U%08x", r))
+	}
+	return strings.Join(p, ",")
+}
+
+var NormalizeCases = []TestCase{
+	{
+		Template: "{unicode:length:20|normalize:NFC}",
+		Comparator: func(s string) error {
+			if norm.NFC.IsNormalString(s) {
+				return nil
+			}
+			return errors.New("Unicode string is not in NFC: " + s)
+		},
+	},
+	{
+		Template: "{unicode:length:20|normalize:NFKD}",
+		Comparator: func(s string) error {
+			if norm.NFKD.IsNormalString(s) {
+				return nil
+			}
+			return errors.New("Unicode string is not in NFKD: " + s)
+		},
+	},
+	{
+		Template: "{unicode:length:20}|{unicode:ordinal:0|normalize:NFD}",
+		Comparator: func(s string) error {
+			p := strings.Split(s, "|")
+			if norm.NFD.String(p[0]) != p[1] {
+				return errors.New("Unicode string at position 1 is not string at position 0 in NFD: " + s)
+			}
+			return nil
+		},
+	},
+	{
+		Template:     "{unicode:normalize:NFX}",
+		ParseFailure: true,
+	},
+}
+
+var AllCases = [][]TestCase{
+	GUIDCases,
+	CommentCases,
+	NowCases,
+	TimeCases,
+	CountryCases,
+	FloatCases,
+	IntegerCases,
+	UnicodeCases,
+	ASCIICases,
+	FirstNameCases,
+	LastNameCases,
+	FullNameCases,
+	GeoCases,
+	SemverCases,
+	BarcodeCases,
+	ISBNCases,
+	RepeatCases,
+	MimeTypeCases,
+	MoneyCases,
+	JSONArrayCases,
+	EmailCases,
+	AddressCases,
+	ZipCases,
+	SSNCases,
+	RegexCases,
+	StringCases,
+	NormalizeCases,
+	UnicodeExcludeCases,
+	TimeAfterCases,
+	TimeSkipCases,
+	TimeSortedCases,
+	FloatSignCases,
+	RangeShorthandCases,
+	IntPresetCases,
+	MaskCases,
+	FloatInclusiveCases,
+	SeparatorCases,
+	PaletteCases,
+	BlobCases,
+	LoremCases,
+	ULIDCases,
+	SnowflakeCases,
+	PhoneCases,
+	CapitalCases,
+	SubdivisionCases,
+	NumberWordsCases,
+	PhoneticCases,
+	EmojiCases,
+	JSONValueCases,
+	WhitespaceCases,
+	ChoiceCases,
+	BoolCases,
+	BytesCases,
+	WordCases,
+	RequireCases,
+	JSONPathCases,
+	TraceparentCases,
+	SnapCases,
+	BoundRefCases,
+	MalformedCases,
+	InvalidTokenCases,
+}
+
+// TODO Test each random function individually, under a number of inputs to make supported
+// all the options behave as expected.
+
+func TestMain(m *testing.M) {
+	rand.Seed(time.Now().Unix())
+	os.Exit(m.Run())
+}
+
+func TestAllCases(t *testing.T) {
+	// TODO The library should be threadsafe, I should go wide here to run all specs
+	// in parallel, like the natural tests would be. Channel + waitgroup to collect
+	// and report on errors once they all finish
+	for _, cs := range AllCases {
+		for _, c := range cs {
+			cs, err := BuildCallstack(c.Template)
+			// If we get an error and weren't expecting it
+			// Or, if we didn't get one but were expecting it
+			if err != nil && !c.ParseFailure {
+				t.Error(err)
+			} else if err == nil && c.ParseFailure {
+				t.Error("Expected to encounter Parse Failure, but did not for Test Case ", c.Template)
+			}
+			// There is nothing to write if the template could not be parsed
+			if err != nil {
+				continue
+			}
+
+			result := &bytes.Buffer{}
+			err = cs.Write(result)
+
+			// If we get an error and weren't expecting it
+			// Or, if we didn't get one but were expecting it
+			if err != nil && !c.WriteFailure {
+				t.Error(err)
+			} else if err == nil && c.WriteFailure {
+				t.Error("Expected to encounter Write Failure, but did not for Test Case ", c.Template)
+			}
+
+			if c.Comparator != nil {
+				if err := c.Comparator(result.String()); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+}
+
+// FuzzBuildCallstack feeds arbitrary templates to BuildCallstack, which must return
+// either a Callstack or one of the errors it returns for a template it can't parse,
+// and never panic. Whatever it parses must then write without panicking, although it
+// may well return an error doing so.
+func FuzzBuildCallstack(f *testing.F) {
+	for _, cs := range AllCases {
+		for _, c := range cs {
+			f.Add(c.Template)
+		}
+	}
+	f.Fuzz(func(t *testing.T, template string) {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			var perr *ParseError
+			var invalid InvalidArgumentError
+			var unsupported UnsupportedTokenError
+			if !errors.As(err, &perr) || !errors.As(err, &invalid) && !errors.As(err, &unsupported) {
+				t.Fatalf("Expected a parse error for %q, got %T: %v", template, err, err)
+			}
+			return
+		}
+		cs.WriteLimited(ioutil.Discard, 1, 1<<16)
+	})
+}
+
+func TestGeneratedStringLength(t *testing.T) {
+	template := "Hey I'm {int:min:1|max:9} years old"
+	sampleresult := "Hey I'm 1 years old"
+	cs, err := BuildCallstack(template)
+	if err != nil {
+		t.Error(err)
+	}
+	result := &bytes.Buffer{}
+	err = cs.Write(result)
+	if err != nil {
+		t.Error(err)
+	}
+	if len([]rune(sampleresult)) != len([]rune(result.String())) {
+		fmt.Println(result.String())
+		t.Error("Missing parts of the rendered templtate")
+	}
+}
+
+// partialWriter accepts at most max bytes on each call to Write
+type partialWriter struct {
+	max int
+	buf bytes.Buffer
+}
+
+func (p *partialWriter) Write(b []byte) (int, error) {
+	if len(b) > p.max {
+		b = b[:p.max]
+	}
+	return p.buf.Write(b)
+}
+
+func TestPartialWrites(t *testing.T) {
+	cs, err := BuildCallstack("{guid}-{int:min:100|max:999}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := &partialWriter{max: 3}
+	if err := cs.WriteN(pw, 4); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSuffix(pw.buf.String(), "\n"), "\n")
+	if len(rows) != 4 {
+		t.Errorf("Expected 4 rows from the partial writer, got %d", len(rows))
+	}
+	for _, row := range rows {
+		// A guid, a dash and a 3 digit int
+		if len(row) != 36+1+3 {
+			t.Error("Partial writer did not receive the whole result: " + row)
+		}
+	}
+	// A writer that makes no progress at all must not loop forever
+	if err := cs.Write(&partialWriter{max: 0}); !errors.Is(err, io.ErrShortWrite) {
+		t.Error("Expected io.ErrShortWrite from a writer that accepts nothing, got ", err)
+	}
+}
+
+// failingWriter accepts up to limit bytes in total, then fails
+type failingWriter struct {
+	limit int
+	buf   bytes.Buffer
+}
+
+var errWriterFull = errors.New("writer is full")
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	room := f.limit - f.buf.Len()
+	if len(b) <= room {
+		return f.buf.Write(b)
+	}
+	n, _ := f.buf.Write(b[:room])
+	return n, errWriterFull
+}
+
+// countingWriter counts how many times it's written to
+type countingWriter struct {
+	calls int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.calls++
+	return len(b), nil
+}
+
+func TestWriteErrors(t *testing.T) {
+	cs, err := BuildCallstack("0123456789{guid}!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &failingWriter{limit: 12}
+	err = cs.Write(fw)
+	werr, ok := err.(*WriteError)
+	if !ok {
+		t.Fatal("Expected a WriteError from a writer that fails, got ", err)
+	}
+	if !errors.Is(err, errWriterFull) {
+		t.Error("WriteError does not wrap the error from the writer: ", err)
+	}
+	if !strings.Contains(werr.Segment, "{guid}") {
+		t.Error("WriteError does not name the token being written: " + werr.Segment)
+	}
+	if werr.Written != 12 || fw.buf.Len() != 12 {
+		t.Errorf("Expected 12 bytes written before the failure, got %d", werr.Written)
+	}
+	// The part failed in is the one the first byte which wasn't written belongs to
+	for limit, segment := range map[int]string{0: "the text at position 0", 10: "{guid}", 46: "the text at position 16"} {
+		if err, ok := cs.Write(&failingWriter{limit: limit}).(*WriteError); !ok || !strings.Contains(err.Segment, segment) {
+			t.Errorf("Expected a WriteError naming %s after %d bytes, got %v", segment, limit, err)
+		}
+	}
+	// Each result goes to the writer in one call, and it's newline in another
+	cw := &countingWriter{}
+	if err := cs.WriteN(cw, 3); err != nil {
+		t.Fatal(err)
+	}
+	if cw.calls != 6 {
+		t.Errorf("Expected 6 calls to Write for 3 results, got %d", cw.calls)
+	}
+	// A static template fails the same way
+	cs, err = BuildCallstack("nothing to see here")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.WriteN(&failingWriter{limit: 3}, 2); !errors.Is(err, errWriterFull) {
+		t.Error("Expected the writer's error from a static template, got ", err)
+	}
+	// Running out of room for the newline is an error too
+	if err := cs.WriteN(&failingWriter{limit: 19}, 2); !errors.Is(err, errWriterFull) {
+		t.Error("Expected the writer's error when writing the newline, got ", err)
+	}
+}
+
+func TestCountryPopulationWeight(t *testing.T) {
+	for c := range CountryPopulations {
+		found := false
+		for _, code := range CountryCodes {
+			found = found || c == code
+		}
+		if !found {
+			t.Error("Population given for a country code which is not in CountryCodes: " + c)
+		}
+	}
+	cs, err := BuildCallstack("{country:weight:population}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 1000); err != nil {
+		t.Fatal(err)
+	}
+	// China and India make up over a third of the population, so should come up often
+	big := 0
+	for _, c := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+		if CountryPopulations[c] == 0 {
+			t.Error("Population weighted country has no population: " + c)
+		}
+		if c == "CN" || c == "IN" {
+			big++
+		}
+	}
+	if big < 200 {
+		t.Errorf("Expected CN and IN to make up at least 200 of 1000 population weighted countries, got %d", big)
+	}
+	// Every code excluded leaves nothing to pick from
+	if _, err := BuildCallstack("{country:exclude:" + strings.Join(CountryCodes, ",") + "}"); err == nil {
+		t.Error("Expected excluding every country to fail to parse")
+	}
+	if _, err := BuildCallstack("{country:weight:area}"); err == nil {
+		t.Error("Expected an unknown weight to fail to parse")
+	}
+}
+
+func TestWriteLimited(t *testing.T) {
+	cs, err := BuildCallstack("{repeat:count:999999999|of:{guid}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	err = cs.WriteLimited(result, 1, 1000)
+	if _, ok := err.(LimitExceededError); !ok {
+		t.Fatal("Expected a LimitExceededError from a result that is too large, got ", err)
+	}
+	if result.Len() != 0 {
+		t.Error("A result over the limit should not be written at all, got " + result.String())
+	}
+
+	// A guid and it's newline is 37 bytes, so only 2 fit
+	cs, err = BuildCallstack("{guid}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cs.WriteLimited(result, 5, 100)
+	if _, ok := err.(LimitExceededError); !ok {
+		t.Error("Expected a LimitExceededError once the limit is reached, got ", err)
+	}
+	if result.Len() != 74 {
+		t.Errorf("Expected 2 results written before the limit, got %d bytes", result.Len())
+	}
+
+	result.Reset()
+	if err := cs.WriteLimited(result, 5, 185); err != nil {
+		t.Error("Expected 5 results to fit exactly in the limit, got ", err)
+	}
+
+	// The limit also applies inside of a {jsonarray}, and to static templates
+	cs, err = BuildCallstack("{jsonarray:count:999999999|of:guid}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cs.WriteLimited(result, 1, 1000).(LimitExceededError); !ok {
+		t.Error("Expected a LimitExceededError from a {jsonarray} that is too large")
+	}
+	cs, err = BuildCallstack("too long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cs.WriteLimited(result, 1, 4).(LimitExceededError); !ok {
+		t.Error("Expected a LimitExceededError from a static template that is too large")
+	}
+
+	// Tokens too large for the limit fail before generating anything, and a {repeat}
+	// whose body writes nothing still runs out of iterations, even when nested
+	for _, template := range []string{
+		"{ascii:length:200000000}",
+		"{lorem:words:20000000}",
+		"{repeat:count:3000000|of:}",
+		"{repeat:count:100|of:{repeat:count:100|of:}}",
+		"{jsonarray:count:3000000|of:ascii}",
+	} {
+		cs, err = BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if _, ok := cs.WriteLimited(result, 1, 1000).(LimitExceededError); !ok {
+			t.Error("Expected a LimitExceededError from " + template)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("Took too long to reach the limit with " + template)
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+	cs, err := BuildCallstack("{guid}-{int}-{repeat:count:3|of:{float}}-{jsonarray:count:2|of:{bool}}-{mask:of:{ascii:length:6}}-{choice:of:{guid},{guid}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	cs.SetObserver(func(token string, value string, dur time.Duration) {
+		if value == "" {
+			t.Error("Observer was given an empty value for " + token)
+		}
+		if dur < 0 {
+			t.Error("Observer was given a negative duration for " + token)
+		}
+		counts[token]++
+	})
+	if err := cs.WriteN(&bytes.Buffer{}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if counts["guid"] != 4 || counts["int"] != 2 || counts["float"] != 6 || counts["bool"] != 4 || counts["ascii"] != 2 {
+		t.Error("Observer was not told about every token: ", counts)
+	}
+	// Tokens with templates of their own are told about as a whole, as well
+	if counts["repeat"] != 2 || counts["jsonarray"] != 2 || counts["mask"] != 2 || counts["choice"] != 2 {
+		t.Error("Observer was not told about every token with a template of it's own: ", counts)
+	}
+	// A nil observer turns it back off
+	cs.SetObserver(nil)
+	if err := cs.Write(&bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if counts["guid"] != 4 {
+		t.Error("Observer was told about a token after being removed: ", counts)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	cs, err := BuildCallstack("INSERT INTO users VALUES ('{guid:as:id}', '{firstname:as:name}', {int:min:1|max:9})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := cs.Records(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	for _, r := range records {
+		if len(r) != 3 {
+			t.Error("Expected a value for each of 3 tokens, got ", r)
+		}
+		if len(r["id"]) != 36 || r["name"] == "" {
+			t.Error("Named tokens are missing from the record: ", r)
+		}
+		// The int is the third token, so it's keyed by the index 2
+		if i, err := strconv.Atoi(r["2"]); err != nil || i < 1 || i > 9 {
+			t.Error("Unnamed token is missing from the record: ", r)
+		}
+	}
+	if records[0]["id"] == records[1]["id"] {
+		t.Error("Each record should have it's own values: ", records)
+	}
+
+	for _, template := range []string{"{guid:as:id}{guid:as:id}", "{guid:as:1}", "{guid:as:}"} {
+		if _, err := BuildCallstack(template); err == nil {
+			t.Error("Expected an invalid as option to fail to parse: " + template)
+		}
+	}
+}
+
+func TestUniqueAcrossRows(t *testing.T) {
+	cs, err := BuildCallstack("{int:min:0|max:5|unique:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 5); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, row := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+		if seen[row] {
+			t.Error("Unique int was repeated across rows: " + row)
+		}
+		seen[row] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected 5 unique rows, got %d", len(seen))
+	}
+	// The space of values has been exhausted, so the next row must fail
+	err = cs.Write(result)
+	if _, ok := err.(ExhaustedRetriesError); !ok {
+		t.Error("Expected an ExhaustedRetriesError once all unique values were used, got ", err)
+	}
+}
+
+func TestUniqueOrdinal(t *testing.T) {
+	cs, err := BuildCallstack("{guid:unique:true}@{guid:ordinal:0|unique:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	p := strings.Split(result.String(), "@")
+	if p[0] != p[1] {
+		t.Error("Unique guid at position 1 not equal to guid at position 0: " + p[0] + " " + p[1])
+	}
+}
+
+func TestUniqueRerolls(t *testing.T) {
+	// Each template has few enough values that unique tokens are rerolled, and the
+	// values they reject must not be seen by the tokens which refer back to them
+	pairs := func(p []string) bool {
+		for i := 0; i+1 < len(p); i += 2 {
+			if p[i] != p[i+1] {
+				return false
+			}
+		}
+		return true
+	}
+	cases := []struct {
+		template string
+		rows     int
+		// ok checks the values of a row, split around each =
+		ok func(p []string) bool
+	}{
+		{"{country:unique:true|format:alpha2}={country:ordinal:0|format:alpha2}", 100, pairs},
+		{"{country:unique:true}={country:ordinal:0}={capital:of:@0}={capital:of:@1}", 100, pairs},
+		{"{bool:unique:true}={bool:not:@0}", 2, func(p []string) bool { return p[0] != p[1] }},
+		{"{bytes:unique:true|min:0|max:3}={bytes:ordinal:0}", 4, pairs},
+		{"{blob:unique:true|length:1}={blob:ordinal:0}", 100, pairs},
+		{"{subdivision:unique:true}={subdivision:ordinal:0}", 100, pairs},
+	}
+	for _, c := range cases {
+		cs, err := BuildCallstack(c.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, c.rows); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+			if !c.ok(strings.Split(row, "=")) {
+				t.Errorf("Expected the tokens of %s to see the value they refer to, got %s", c.template, row)
+			}
+		}
+	}
+}
+
+func TestDiscardLast(t *testing.T) {
+	// Values which can't be made to collide, such as guids, are checked on the cache
+	// itself, for every type of token that caches it's values
+	for word, cache := range newObjectCache(time.Now()) {
+		v := reflect.ValueOf(cache)
+		if v.Kind() != reflect.Slice {
+			continue
+		}
+		oc := newObjectCache(time.Now())
+		oc[word] = reflect.Append(v, reflect.Zero(v.Type().Elem())).Interface()
+		oc.discardLast(word)
+		if n := reflect.ValueOf(oc[word]).Len(); n != 0 {
+			t.Errorf("Expected discardLast to remove the value cached for %s, %d are left", word, n)
+		}
+	}
+}
+
+func TestTemporaryErrors(t *testing.T) {
+	type temporary interface {
+		Temporary() bool
+	}
+	cases := map[string]bool{
+		"{int:min:1|max:3|exclude:1,2}": true,
+		"{int}@{int:ordinal:1}":         false,
+		"{plastname}":                   false,
+	}
+	for template, expected := range cases {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cs.Write(&bytes.Buffer{})
+		te, ok := err.(temporary)
+		if !ok {
+			t.Errorf("Expected the error for %s to implement Temporary, got %v", template, err)
+		} else if te.Temporary() != expected {
+			t.Errorf("Expected Temporary to be %t for %s", expected, template)
+		}
+	}
+}
+
+func TestTimeOrdinalFormats(t *testing.T) {
+	// One instant, written out as a date and then as it's epoch
+	cs, err := BuildCallstack("{time:min:1577880000|max:1577880000|format:2006-01-02}@{time:ordinal:0|format:unix}@{time:ordinal:0|format:unixms}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "2020-01-01@1577880000@1577880000000"; result.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, result.String())
+	}
+
+	// The epoch is always of the same instant as the date, wherever it is picked
+	cs, err = BuildCallstack("{time:format:2006-01-02}@{time:ordinal:0|format:unix}@{now:format:simple}@{now:ordinal:0|format:unix}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		result.Reset()
+		if err := cs.Write(result); err != nil {
+			t.Fatal(err)
+		}
+		p := strings.Split(result.String(), "@")
+		epoch, err := strconv.ParseInt(p[1], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if date := time.Unix(epoch, 0).UTC().Format("2006-01-02"); date != p[0] {
+			t.Fatalf("Expected the epoch %s to fall on %s, got %s", p[1], p[0], date)
+		}
+		now, err := strconv.ParseInt(p[3], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := time.Unix(now, 0).UTC().Format("2006-01-02 15:04:05"); s != p[2] {
+			t.Fatalf("Expected the epoch %s to be %s, got %s", p[3], p[2], s)
+		}
+	}
+}
+
+func TestWithDefault(t *testing.T) {
+	cs, err := BuildCallstack("{time:min:1|max:1}@{time:min:1|max:1|format:simple}@{repeat:of:{time:min:1|max:1}}",
+		WithDefault("time", "format", time.RFC3339),
+		WithDefault("time", "zone", "EST"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	expected := "1969-12-31T19:00:01-05:00@1969-12-31 19:00:01@1969-12-31T19:00:01-05:00"
+	if result.String() != expected {
+		t.Errorf("Expected defaults to apply unless overridden, wanted %s got %s", expected, result.String())
+	}
+
+	if _, err := BuildCallstack("{int}", WithDefault("plastname", "case", "up")); err == nil {
+		t.Error("Expected an error giving a default for an unknown token")
+	}
+	if _, err := BuildCallstack("{repeat:of:{time}}", WithDefault("time", "format", "YYYY-MM-DD")); err == nil {
+		t.Error("Expected an error giving a default format which isn't a golang layout")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		template string
+		token    string
+		position int
+	}{
+		{"{guid}{int:mim:5}", "int:mim:5", 6},
+		{"ab{}", "", 2},
+		{"{guid} {int:min:1", "int:min:1", 7},
+	}
+	for _, c := range cases {
+		_, err := BuildCallstack(c.template)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Expected a ParseError for %s, got %T: %v", c.template, err, err)
+			continue
+		}
+		if perr.Token != c.token || perr.Position != c.position {
+			t.Errorf("Expected %q at %d for %s, got %q at %d", c.token, c.position, c.template, perr.Token, perr.Position)
+		}
+		if perr.Temporary() {
+			t.Error("Expected a ParseError to be permanent")
+		}
+	}
+	// A token in a nested template is wrapped in the token it's inside of as well
+	_, err := BuildCallstack("x{repeat:count:2|of:a{int:mim:5}}")
+	var outer, inner *ParseError
+	if !errors.As(err, &outer) || outer.Position != 1 || !strings.HasPrefix(outer.Token, "repeat:") {
+		t.Fatalf("Expected a ParseError for the repeat, got %v", err)
+	}
+	if !errors.As(outer.Err, &inner) || inner.Token != "int:mim:5" || inner.Position != 1 {
+		t.Errorf("Expected a ParseError for the int inside the repeat, got %v", outer.Err)
+	}
+	var invalid InvalidArgumentError
+	if !errors.As(err, &invalid) {
+		t.Errorf("Expected an InvalidArgumentError inside both, got %v", err)
+	}
+}
+
+func TestUnknownOptions(t *testing.T) {
+	_, err := BuildCallstack("{guid}{int:mim:5|maz:9|max:10}")
+	if err == nil || !strings.Contains(err.Error(), "maz, mim") {
+		t.Errorf("Expected an error listing the unknown options, got %v", err)
+	}
+	var invalid InvalidArgumentError
+	if !errors.As(err, &invalid) {
+		t.Errorf("Expected an InvalidArgumentError, got %T", err)
+	}
+	if _, err := BuildCallstack("{repeat:count:2|of:{float:precison:2}}"); err == nil {
+		t.Error("Expected an error for an unknown option inside of a repeat")
+	}
+	if _, err := BuildCallstack("{jsonarray:count:2|of:int:min:1|mx:9}"); err == nil {
+		t.Error("Expected an error for an unknown option of the token in a jsonarray")
+	}
+
+	// Options without a default, and those every token takes, are still fine
+	if _, err := BuildCallstack("{guid:format:n|as:id}{time:zone:UTC|format:simple}{int:type:int8|unique:true}{ascii:case:up}"); err != nil {
+		t.Error(err)
+	}
+
+	cs, err := BuildCallstack("{int:mim:5|min:1|max:1}", LenientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "1" {
+		t.Errorf("Expected unknown options to be ignored in lenient mode, got %s", result.String())
+	}
+}
+
+func TestCompile(t *testing.T) {
+	defer SetCompileCacheSize(DefaultCompileCacheSize)
+	ClearCompileCache()
+	a, err := Compile("{int}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Compile("{int}"); again != a {
+		t.Error("Expected compiling the same template twice to return the same Callstack")
+	}
+	if other, _ := Compile("{float}"); other == a {
+		t.Error("Expected a different template to have a Callstack of it's own")
+	}
+	if _, err := Compile("{int:mim:1}"); err == nil {
+		t.Error("Expected Compile to return a parse error just as BuildCallstack does")
+	}
+
+	// {float} was used after {int}, so {int} is the one to go
+	SetCompileCacheSize(1)
+	if again, _ := Compile("{int}"); again == a {
+		t.Error("Expected the least recently used template to be forgotten when the cache shrinks")
+	}
+	b, _ := Compile("{int}")
+	ClearCompileCache()
+	if again, _ := Compile("{int}"); again == b {
+		t.Error("Expected ClearCompileCache to forget every template")
+	}
+	SetCompileCacheSize(0)
+	c, _ := Compile("{int}")
+	if again, _ := Compile("{int}"); again == c {
+		t.Error("Expected a cache size of 0 to turn the cache off")
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	cs, err := Compile("{guid}@{int:min:1|max:1000000|unique:true}@{repeat:count:3|of:{float}{semver:sequence:patch}}@{now}{ulid}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan string, 400)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				result := &bytes.Buffer{}
+				if err := cs.Write(result); err != nil {
+					t.Error(err)
+					return
+				}
+				results <- result.String()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	// Every row draws from the same unique set, no matter which goroutine wrote it
+	seen := make(map[string]bool)
+	for r := range results {
+		id := strings.Split(r, "@")[1]
+		if seen[id] {
+			t.Errorf("Expected unique values to stay unique across goroutines, got %s twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 400 {
+		t.Errorf("Expected 400 results, got %d", len(seen))
+	}
+}
+
+// seededTemplate draws on as many kinds of randomness as possible, leaving out tokens
+// which depend on the time they're generated at
+const seededTemplate = "{guid}|{int:min:1|max:1000000}|{float}|{ascii:length:8|require:digit}|{unicode:length:4}|{firstname}|{country}|{repeat:count:1-3|of:{word}}|{blob:length:6}|{traceparent}|{lorem:model:markov|sentences:2}|{guid:ordinal:0}"
+
+func TestWriteSQLValues(t *testing.T) {
+	cs, err := BuildCallstack("({int:min:1|max:1000}, {int:ordinal:0}, '{firstname}')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteSQLValues(result, 50); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(result.String(), ",\n")
+	if len(rows) != 50 {
+		t.Fatalf("Expected 50 rows, got %d: %s", len(rows), result)
+	}
+	row := regexp.MustCompile(`^\((\d+), (\d+), '[^']+'\)$`)
+	for _, r := range rows {
+		m := row.FindStringSubmatch(r)
+		if m == nil {
+			t.Fatal("Row is not a tuple: ", r)
+		}
+		if m[1] != m[2] {
+			t.Error("Expected the ordinal to refer to the int in it's own row, got ", r)
+		}
+	}
+	if err := cs.WriteSQLValues(result, 0); err == nil {
+		t.Error("Expected an error for 0 rows")
+	}
+	if err := cs.WriteSQLValues(&failingWriter{limit: 30}, 10); !errors.Is(err, errWriterFull) {
+		t.Error("Expected the writer's error, got ", err)
+	}
+}
+
+func TestWriteNOptions(t *testing.T) {
+	cs, err := BuildCallstack("({int:min:7|max:7}, 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statement := []WriteOption{WithPrefix("INSERT INTO t (a, b) VALUES\n"), WithSeparator(",\n"), WithSuffix(";\n")}
+	cases := []struct {
+		n       int
+		options []WriteOption
+		want    string
+	}{
+		{2, nil, "(7, 'x')\n(7, 'x')\n"},
+		{3, statement, "INSERT INTO t (a, b) VALUES\n(7, 'x'),\n(7, 'x'),\n(7, 'x');\n"},
+		{1, statement, "INSERT INTO t (a, b) VALUES\n(7, 'x');\n"},
+		// The prefix and suffix are written even without any results
+		{0, statement, "INSERT INTO t (a, b) VALUES\n;\n"},
+		// Without a separator, the newline after each result stays
+		{2, []WriteOption{WithPrefix("a,b\n")}, "a,b\n(7, 'x')\n(7, 'x')\n"},
+		{2, []WriteOption{WithSeparator("")}, "(7, 'x')(7, 'x')"},
+	}
+	for _, c := range cases {
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, c.n, c.options...); err != nil {
+			t.Fatal(err)
+		}
+		if result.String() != c.want {
+			t.Errorf("Expected %q, got %q", c.want, result.String())
+		}
+	}
+	for _, limit := range []int{10, 30, 37, 47} {
+		err := cs.WriteN(&failingWriter{limit: limit}, 2, statement...)
+		if _, ok := err.(*WriteError); !ok || !errors.Is(err, errWriterFull) {
+			t.Errorf("Expected a WriteError wrapping the writer's error after %d bytes, got %v", limit, err)
+		}
+	}
+}
+
+func TestBuildCallstackWithSeed(t *testing.T) {
+	generate := func(seed int64) string {
+		cs, err := BuildCallstackWithSeed(seededTemplate, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, 20); err != nil {
+			t.Fatal(err)
+		}
+		return result.String()
+	}
+	first := generate(42)
+	if again := generate(42); again != first {
+		t.Errorf("Expected the same seed to generate the same results, got\n%s\nthen\n%s", first, again)
+	}
+	if other := generate(43); other == first {
+		t.Error("Expected a different seed to generate different results")
+	}
+	lines := strings.Split(first, "\n")
+	if lines[0] == lines[1] {
+		t.Error("Expected each result of a seeded Callstack to differ, got ", lines[0], " twice")
+	}
+	// Reset starts over from the first result
+	cs, err := BuildCallstackWithSeed(seededTemplate, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	cs.Reset()
+	again := &bytes.Buffer{}
+	if err := cs.Write(again); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != again.String() || result.String() != lines[0] {
+		t.Errorf("Expected Reset to generate the first result again, got %s then %s", result, again)
+	}
+}
+
+func TestPerTokenStreams(t *testing.T) {
+	records := func(template string) []map[string]string {
+		cs, err := BuildCallstackWithSeed(template, 99, PerTokenStreams())
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := cs.Records(20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+	before := records("{int:as:id},{guid:as:ref},{firstname:as:name},{repeat:count:1-4|of:{int}-{word}|as:tags}")
+	// Adding a token, and changing the options of another, leaves the rest alone
+	after := records("{int:min:5|max:10|as:id},{ascii:length:20},{guid:as:ref},{firstname:as:name},{repeat:count:1-4|of:{int}-{word}|as:tags}")
+	for i := range before {
+		for _, k := range []string{"ref", "name", "tags"} {
+			if before[i][k] != after[i][k] {
+				t.Errorf("Expected %s of result %d to stay %s, got %s", k, i, before[i][k], after[i][k])
+			}
+		}
+	}
+	// Unnamed tokens are known by their index, which changes for those after a new token
+	before = records("{int},{guid}")
+	after = records("{int:min:5|max:10},{guid}")
+	if before[0]["1"] != after[0]["1"] {
+		t.Errorf("Expected the guid to stay %s, got %s", before[0]["1"], after[0]["1"])
+	}
+	// Each time through a repeat still differs
+	repeated := records("{repeat:count:5|of:{guid}|sep:,}")
+	if ids := strings.Split(repeated[0]["0"], ","); ids[0] == ids[1] {
+		t.Error("Expected each time through a repeat to generate a different guid, got ", ids[0], " twice")
+	}
+	// Every result still differs, and WriteNParallel writes the same results as WriteN
+	if before[0]["1"] == before[1]["1"] {
+		t.Error("Expected each result to have a different guid, got ", before[0]["1"], " twice")
+	}
+	cs, err := BuildCallstackWithSeed(seededTemplate, 3, PerTokenStreams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequential, parallel := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := cs.WriteN(sequential, 100); err != nil {
+		t.Fatal(err)
+	}
+	cs.Reset()
+	if err := cs.WriteNParallel(parallel, 100, 4); err != nil {
+		t.Fatal(err)
+	}
+	if sequential.String() != parallel.String() {
+		t.Error("Expected WriteNParallel to write the same results as WriteN with a stream per token")
+	}
+	if _, err := BuildCallstack("{int}", PerTokenStreams()); err == nil {
+		t.Error("Expected an error for PerTokenStreams without a seed")
+	}
+}
+
+func TestWriteNParallel(t *testing.T) {
+	cs, err := BuildCallstackWithSeed(seededTemplate, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequential := &bytes.Buffer{}
+	if err := cs.WriteN(sequential, 500); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 3, 16} {
+		cs.Reset()
+		parallel := &bytes.Buffer{}
+		if err := cs.WriteNParallel(parallel, 500, workers); err != nil {
+			t.Fatal(err)
+		}
+		if parallel.String() != sequential.String() {
+			t.Errorf("Expected %d workers to write the same results as WriteN", workers)
+		}
+		cs.Reset()
+		unordered := &bytes.Buffer{}
+		if err := cs.WriteNParallelUnordered(unordered, 500, workers); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Split(unordered.String(), "\n")
+		want := strings.Split(sequential.String(), "\n")
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %d unordered workers to write the same results as WriteN, in any order", workers)
+		}
+	}
+	// Without a seed, every result is still written, and unique values stay unique
+	cs, err = BuildCallstack("{int:min:1|max:1000000|unique:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteNParallel(result, 1000, 8); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+		if seen[line] {
+			t.Error("Expected unique values to stay unique across workers, got ", line, " twice")
+		}
+		seen[line] = true
+	}
+	if len(seen) != 1000 {
+		t.Errorf("Expected 1000 results, got %d", len(seen))
+	}
+	// Once the unique values run out, whichever result claims a value last fails, after
+	// every result before it has been written
+	cs, err = BuildCallstack("{int:min:1|max:3|unique:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Reset()
+	if err := cs.WriteNParallel(result, 4, 2); err == nil {
+		t.Error("Expected an error once the unique values run out")
+	}
+	if n := strings.Count(result.String(), "\n"); n > 3 {
+		t.Errorf("Expected at most the 3 results before the error to be written, got %d", n)
+	}
+	// As do writes which fail
+	cs, err = BuildCallstack("{guid}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.WriteNParallel(&failingWriter{limit: 3}, 10, 4); !errors.Is(err, errWriterFull) {
+		t.Error("Expected the writer's error, got ", err)
+	} else if _, ok := err.(*WriteError); !ok {
+		t.Error("Expected a WriteError from a writer that fails, got ", err)
+	}
+	if err := cs.WriteNParallel(result, 10, 0); err == nil {
+		t.Error("Expected an error for 0 workers")
+	} else if _, ok := err.(InvalidArgumentError); !ok {
+		t.Error("Expected an InvalidArgumentError for 0 workers, got ", err)
+	}
 }
 
-var FirstNameCases = []TestCase{
-	{
-		Template: "{firstname}",
-		Comparator: func(s string) error {
-			if len(s) > 0 {
-				return nil
+func TestChoiceWeights(t *testing.T) {
+	for _, template := range []string{"{choice:of:A,B|weights:30,20|other:C}", "{choice:values:A,B|weights:30,20|other:C}"} {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			result := &bytes.Buffer{}
+			if err := cs.Write(result); err != nil {
+				t.Fatal(err)
 			}
-			return errors.New("First Name string not the correct length")
-		},
-	},
-	{
-		Template: "{firstname}@{firstname:ordinal:0}",
-		Comparator: func(s string) error {
-			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
+			counts[result.String()]++
+		}
+		for v, want := range map[string]int{"A": 3000, "B": 2000, "C": 5000} {
+			if got := counts[v]; got < want-300 || got > want+300 {
+				t.Errorf("Expected %s about %d times out of 10000 from %s, got %d", v, want, template, got)
 			}
-			return errors.New("First Name at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
-		},
-	},
-	{
-		Template:     "{firstname}@{firstname:ordinal:1}",
-		WriteFailure: true,
-	},
+		}
+	}
+	for _, template := range []string{"{choice:values:A,B|weights:60,50|other:C}", "{choice:values:A,B|weights:30,20}"} {
+		if _, err := BuildCallstack(template); err == nil {
+			t.Errorf("Expected a parse error for %s", template)
+		}
+	}
 }
 
-var LastNameCases = []TestCase{
-	{
-		Template: "{lastname}",
-		Comparator: func(s string) error {
-			if len(s) > 0 {
-				return nil
-			}
-			return errors.New("First Name string not the correct length")
-		},
-	},
-	{
-		Template: "{lastname}@{lastname:ordinal:0}",
-		Comparator: func(s string) error {
-			p := strings.Split(s, "@")
-			if p[0] == p[1] {
-				return nil
-			}
-			return errors.New("First Name at position 1 not equal to Unicode at position 0: " + p[0] + " " + p[1])
-		},
-	},
-	{
-		Template:     "{lastname}@{lastname:ordinal:1}",
-		WriteFailure: true,
-	},
+func TestChoiceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "moldova")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "airports.txt")
+	if err := ioutil.WriteFile(path, []byte("LHR\r\nJFK\n\nNRT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := WithFiles(os.DirFS(dir))
+	cs, err := BuildCallstack("{choice:file:airports.txt}@{choice:ordinal:0}", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		result := &bytes.Buffer{}
+		if err := cs.Write(result); err != nil {
+			t.Fatal(err)
+		}
+		p := strings.Split(result.String(), "@")
+		if p[0] != p[1] {
+			t.Errorf("Expected the ordinal to repeat the choice, got %s", result.String())
+		}
+		seen[p[0]] = true
+	}
+	if len(seen) != 3 || !seen["LHR"] || !seen["JFK"] || !seen["NRT"] {
+		t.Errorf("Expected every line of the file to be chosen, and nothing else, got %v", seen)
+	}
+
+	// The values are read when the template is parsed, so changing the file doesn't
+	// change what an existing Callstack picks from
+	if err := ioutil.WriteFile(path, []byte("SYD\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(result.String(), "SYD") {
+		t.Error("Expected the file to be read only when the template was parsed, got " + result.String())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.txt"), []byte("\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range []string{"{choice:file:empty.txt}", "{choice:file:missing.txt}", "{choice:file:../airports.txt}", "{choice:file:" + path + "}"} {
+		if _, err := BuildCallstack(template, files); err == nil {
+			t.Errorf("Expected a parse error for %s", template)
+		}
+	}
+	// Files can't be read at all without WithFiles
+	if _, err := BuildCallstack("{choice:file:airports.txt}"); err == nil {
+		t.Error("Expected a parse error reading a file without WithFiles")
+	}
+	if _, err := BuildCallstack("{choice:file:airports.txt}", WithFiles(nil)); err == nil {
+		t.Error("Expected an error from WithFiles given a nil fs.FS")
+	}
 }
 
-var FullNameCases = []TestCase{
-	{
-		Template: "{firstname} {lastname}",
-		Comparator: func(s string) error {
-			if len(s) > 0 {
-				return nil
+func TestPool(t *testing.T) {
+	if err := RegisterPool("empty", nil); err == nil {
+		t.Error("Expected an error registering an empty pool")
+	}
+	if _, err := BuildCallstack("{pool:name:nothing}"); err == nil {
+		t.Error("Expected a parse error for an unregistered pool")
+	}
+
+	// Generate the parent ids, and then use them as the pool for the children
+	users, err := BuildCallstack("{guid}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := users.WriteN(result, 3); err != nil {
+		t.Fatal(err)
+	}
+	ids := strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n")
+	if err := RegisterPool("user_id", ids); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := BuildCallstack("{pool:name:user_id}@{pool:ordinal:0}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		result.Reset()
+		if err := orders.Write(result); err != nil {
+			t.Fatal(err)
+		}
+		p := strings.Split(result.String(), "@")
+		if p[0] != p[1] {
+			t.Error("Pool at position 1 not equal to pool at position 0: " + p[0] + " " + p[1])
+		}
+		found := false
+		for _, id := range ids {
+			if id == p[0] {
+				found = true
 			}
-			return errors.New("First / Last Name string not the correct length")
-		},
-	},
+		}
+		if !found {
+			t.Error("Pool value was not one of the registered values: " + p[0])
+		}
+	}
 }
 
-var InvalidTokenCases = []TestCase{
-	{
-		Template:     "{firstname} {plastname}",
-		WriteFailure: true,
-	},
-	{
-		Template:     "{firstname:language:onglish}",
-		WriteFailure: true,
-	},
+func TestIntInclusive(t *testing.T) {
+	// Only the lower end of a range comes up by default, and both ends of it once
+	// maxInclusive:true is given
+	for template, expected := range map[string]string{
+		"{int:min:1|max:3}":                     "1,2",
+		"{int:min:-3|max:-1}":                   "-3,-2",
+		"{int:min:1|max:3|maxInclusive:false}":  "1,2",
+		"{int:min:1|max:3|maxInclusive:true}":   "1,2,3",
+		"{int:min:-3|max:-1|maxInclusive:true}": "-1,-2,-3",
+	} {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, 300); err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for _, row := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+			seen[row] = true
+		}
+		want := strings.Split(expected, ",")
+		if len(seen) != len(want) {
+			t.Errorf("Expected %s to generate only %s, got %v", template, expected, seen)
+		}
+		for _, w := range want {
+			if !seen[w] {
+				t.Errorf("Expected %s to generate %s at least once, got %v", template, w, seen)
+			}
+		}
+	}
 }
 
-var AllCases = [][]TestCase{
-	GUIDCases,
-	NowCases,
-	TimeCases,
-	CountryCases,
-	FloatCases,
-	IntegerCases,
-	UnicodeCases,
-	ASCIICases,
-	FirstNameCases,
-	LastNameCases,
-	FullNameCases,
-	InvalidTokenCases,
+func TestIntEdges(t *testing.T) {
+	cs, err := BuildCallstack("{int:type:int32|edge:true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 200); err != nil {
+		t.Fatal(err)
+	}
+	foundEdge := false
+	for _, row := range strings.Split(strings.TrimSuffix(result.String(), "\n"), "\n") {
+		if row == "-2147483648" || row == "2147483647" {
+			foundEdge = true
+		}
+	}
+	if !foundEdge {
+		t.Error("Expected edge:true to produce the bounds of an int32 at least once")
+	}
 }
 
-// TODO Test each random function individually, under a number of inputs to make supported
-// all the options behave as expected.
+func TestGTINCheckDigit(t *testing.T) {
+	// Known good codes, with their check digits split off
+	codes := map[string]byte{
+		"400638133393": '1', // EAN-13 4006381333931
+		"03600029145":  '2', // UPC-A 036000291452
+		"590123412345": '7', // EAN-13 5901234123457
+	}
+	for digits, check := range codes {
+		if c := gtinCheckDigit([]byte(digits)); c != check {
+			t.Errorf("Expected check digit %c for %s, got %c", check, digits, c)
+		}
+	}
+}
 
-func TestMain(m *testing.M) {
-	rand.Seed(time.Now().Unix())
-	os.Exit(m.Run())
+func TestISBNCheckDigits(t *testing.T) {
+	// Known good ISBN-10s, with their check characters split off
+	codes := map[string]byte{
+		"030640615": '2', // 0-306-40615-2
+		"080442957": 'X', // 0-8044-2957-X
+	}
+	for digits, check := range codes {
+		if c := isbn10CheckDigit([]byte(digits)); c != check {
+			t.Errorf("Expected check character %c for %s, got %c", check, digits, c)
+		}
+	}
+	if c := gtinCheckDigit([]byte("978030640615")); c != '7' {
+		t.Errorf("Expected check digit 7 for 978-0-306-40615, got %c", c)
+	}
 }
 
-func TestAllCases(t *testing.T) {
-	// TODO The library should be threadsafe, I should go wide here to run all specs
-	// in parallel, like the natural tests would be. Channel + waitgroup to collect
-	// and report on errors once they all finish
-	for _, cs := range AllCases {
-		for _, c := range cs {
-			cs, err := BuildCallstack(c.Template)
-			// If we get an error and weren't expecting it
-			// Or, if we didn't get one but were expecting it
-			if err != nil && !c.ParseFailure {
-				t.Error(err)
-			} else if err == nil && c.ParseFailure {
-				t.Error("Expected to encounter Parse Failure, but did not for Test Case ", c.Template)
-			}
+func TestSemverSequence(t *testing.T) {
+	sequences := map[string]string{
+		"{semver:sequence:patch}":            "1.0.0\n1.0.1\n1.0.2\n",
+		"{semver:sequence:minor|base:0.9.3}": "0.9.3\n0.10.0\n0.11.0\n",
+		"{semver:sequence:major|base:1.2.3}": "1.2.3\n2.0.0\n3.0.0\n",
+	}
+	for template, expected := range sequences {
+		cs, err := BuildCallstack(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &bytes.Buffer{}
+		if err := cs.WriteN(result, 3); err != nil {
+			t.Fatal(err)
+		}
+		if result.String() != expected {
+			t.Errorf("Expected %s to produce %q, got %q", template, expected, result.String())
+		}
+	}
+}
 
-			result := &bytes.Buffer{}
-			err = cs.Write(result)
+func TestStaticTemplate(t *testing.T) {
+	template := "INSERT INTO floof VALUES ('static', 1, NULL)"
+	cs, err := BuildCallstack(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cs.static {
+		t.Error("Expected a template without tokens to be marked static")
+	}
+	result := &bytes.Buffer{}
+	if err := cs.WriteN(result, 2); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != template+"\n"+template+"\n" {
+		t.Error("Static template was not written out as is: " + result.String())
+	}
 
-			// If we get an error and weren't expecting it
-			// Or, if we didn't get one but were expecting it
-			if err != nil && !c.WriteFailure {
-				t.Error(err)
-			} else if err == nil && c.WriteFailure {
-				t.Error("Expected to encounter Write Failure, but did not for Test Case ", c.Template)
-			}
+	if cs, err = BuildCallstack("{int}"); err != nil {
+		t.Fatal(err)
+	}
+	if cs.static {
+		t.Error("Expected a template with tokens not to be marked static")
+	}
+}
 
-			if c.Comparator != nil {
-				if err := c.Comparator(result.String()); err != nil {
-					t.Error(err)
-				}
-			}
+func TestEmptyTemplate(t *testing.T) {
+	cs, err := BuildCallstack("")
+	if err != nil {
+		t.Fatal("Expected an empty template to parse, got ", err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil || result.Len() != 0 {
+		t.Errorf("Expected an empty template to write nothing, got %q and %v", result, err)
+	}
+	if err := cs.WriteN(result, 3); err != nil || result.String() != "\n\n\n" {
+		t.Errorf("Expected WriteN to write only newlines for an empty template, got %q and %v", result, err)
+	}
+	records, err := cs.Records(2)
+	if err != nil || len(records) != 2 || len(records[0]) != 0 {
+		t.Errorf("Expected empty records for an empty template, got %v and %v", records, err)
+	}
+	if len(cs.Tokens()) != 0 || cs.String() != "" {
+		t.Errorf("Expected no tokens in an empty template, got %v", cs.Tokens())
+	}
+	// An empty token is an error wherever it is, rather than failing during Write
+	for _, template := range []string{"{}", "a{}b", "{guid}{}", "{repeat:of:{}}"} {
+		_, err := BuildCallstack(template)
+		var invalid InvalidArgumentError
+		if !errors.As(err, &invalid) {
+			t.Errorf("Expected an InvalidArgumentError for %s, got %v", template, err)
+		} else if template == "{}" && !strings.Contains(err.Error(), "is empty") {
+			t.Error("Expected the error to say the token is empty, got ", err)
 		}
 	}
 }
 
-func TestGeneratedStringLength(t *testing.T) {
-	template := "Hey I'm {int:min:1|max:9} years old"
-	sampleresult := "Hey I'm 1 years old"
+func TestBuildCallstackFromReader(t *testing.T) {
+	template := "INSERT INTO floof\n\tVALUES ({int:min:1|max:2});\n"
+	cs, err := BuildCallstackFromReader(strings.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := cs.Write(result); err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "INSERT INTO floof\n\tVALUES (1);\n" {
+		t.Errorf("Template read from a reader was not rendered as expected: %q", result.String())
+	}
+
+	if _, err := BuildCallstackFromReader(strings.NewReader("{geo:bbox:1}")); err == nil {
+		t.Error("Expected a parse error from a template read from a reader")
+	}
+}
+
+func BenchmarkStatic(b *testing.B) {
+	template := strings.Repeat("INSERT INTO floof VALUES ('static', 1, NULL);", 20)
 	cs, err := BuildCallstack(template)
 	if err != nil {
-		t.Error(err)
+		b.Error(err)
 	}
 	result := &bytes.Buffer{}
-	err = cs.Write(result)
+	b.SetBytes(int64(len(template)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		result.Reset()
+		err = cs.Write(result)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkAppendTo(b *testing.B) {
+	cs, err := BuildCallstack("INSERT INTO floof VALUES ('{guid}', {int:min:1|max:9999}, '{now}');")
 	if err != nil {
-		t.Error(err)
+		b.Error(err)
 	}
-	if len([]rune(sampleresult)) != len([]rune(result.String())) {
-		fmt.Println(result.String())
-		t.Error("Missing parts of the rendered templtate")
+	buf := make([]byte, 0, 256)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		buf, err = cs.AppendTo(buf[:0])
+		if err != nil {
+			b.Error(err)
+		}
 	}
 }
 
@@ -714,3 +6446,54 @@ func BenchmarkFullName(b *testing.B) {
 		}
 	}
 }
+
+func TestPronounceableWord(t *testing.T) {
+	vowels := "aeiou"
+	for i := 0; i < 1000; i++ {
+		w := pronounceableWord(sharedRand, 8)
+		if len(w) != 8 {
+			t.Fatalf("Expected a word of 8 letters, got %s", w)
+		}
+		// Sounds alternate, and no sound is longer than 2 letters, so there can never be
+		// more than 2 consonants or 2 vowels in a row
+		run := 0
+		for j := range w {
+			if j > 0 && strings.ContainsRune(vowels, rune(w[j])) == strings.ContainsRune(vowels, rune(w[j-1])) {
+				run++
+			} else {
+				run = 1
+			}
+			if run > 2 {
+				t.Fatalf("Expected alternating consonants and vowels, got %s", w)
+			}
+		}
+	}
+}
+
+func TestRequireClasses(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		s := requireClasses(sharedRand, "abcd", "digit,upper", nil)
+		if !strings.ContainsAny(s, requiredClasses["digit"]) || !strings.ContainsAny(s, requiredClasses["upper"]) {
+			t.Fatalf("Expected at least one digit and one upper case letter, got %s", s)
+		}
+		if len(s) != 4 {
+			t.Fatalf("Expected required characters to replace others, not be added, got %s", s)
+		}
+	}
+}
+
+func TestSnapInt(t *testing.T) {
+	cases := []struct{ n, snap, want int }{
+		{7, 5, 5},
+		{8, 5, 10},
+		{-7, 5, -5},
+		{-8, 5, -10},
+		{math.MaxInt, 10, math.MaxInt - 7},
+		{math.MinInt, 3, math.MinInt + 2},
+	}
+	for _, c := range cases {
+		if got := snapInt(c.n, c.snap); got != c.want {
+			t.Errorf("Expected %d snapped to %d to be %d, got %d", c.n, c.snap, c.want, got)
+		}
+	}
+}